@@ -0,0 +1,447 @@
+package yearcollage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/luceast/yearcollage/internal/bytesize"
+	"github.com/luceast/yearcollage/internal/cache"
+	"github.com/luceast/yearcollage/internal/icc"
+	"github.com/luceast/yearcollage/internal/stripenc"
+)
+
+// Builder renders collages with a fixed set of Options. A Builder may be
+// reused for several renders but not concurrently.
+type Builder struct {
+	opts  Options
+	cache *cache.Cache
+
+	mu   sync.Mutex // serializes Progress calls
+	done int
+}
+
+// New validates opts and prepares a Builder, opening the tile cache when one
+// is configured.
+func New(opts Options) (*Builder, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	b := &Builder{opts: opts}
+	if opts.CacheDir != "" {
+		c, err := cache.Open(opts.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		b.cache = c
+	}
+	return b, nil
+}
+
+// Result is a finished collage.
+type Result struct {
+	// Image is the collage. It is nil when Encode wrote it in strips.
+	Image *image.RGBA
+	// Bounds is the collage's size, also when Image is nil.
+	Bounds image.Rectangle
+	// Columns and Rows span the final layout. Justified layouts count the
+	// most photos in one row as Columns.
+	Columns, Rows int
+	// TileWidth and TileHeight are the cell size of grid and calendar
+	// layouts; justified tiles vary, so they are zero.
+	TileWidth, TileHeight int
+	// Tiles records every photo drawn, in reading order: by row, then by
+	// column. Failed photos are left out.
+	Tiles []Tile
+	// Failures lists the sources that could not be rendered, in layout
+	// order. Under OnErrorSkip they are missing from Image, under
+	// OnErrorPlaceholder they show as neutral tiles.
+	Failures []Failure
+	// Duplicates lists the sources dropped by Options.Dedupe, in input
+	// order.
+	Duplicates []Duplicate
+	// Rejected lists the sources dropped by MinSharpness or BurstWindow, in
+	// input order.
+	Rejected []Rejection
+	// Quality holds the analysis of every decodable source, keyed by name,
+	// when MinSharpness or BurstWindow is set.
+	Quality map[string]Quality
+}
+
+// Failure records a source that could not be rendered and why.
+type Failure struct {
+	Source string // the source's Name
+	Err    error
+}
+
+// Stage names a step of the render pipeline in progress events.
+type Stage string
+
+// Stages.
+const (
+	StageProbe   Stage = "probe"   // reading headers or capture times for the layout and size checks
+	StageDedupe  Stage = "dedupe"  // hashing photos to find duplicates
+	StageAnalyze Stage = "analyze" // measuring sharpness and exposure
+	StageRender  Stage = "render"  // decoding and scaling tiles
+)
+
+// EventKind tells what an Event reports.
+type EventKind int
+
+// Event kinds.
+const (
+	// EventStage marks the start of a stage; Total is the number of images
+	// it will visit.
+	EventStage EventKind = iota
+	// EventItem reports one image finished within the current stage,
+	// successfully or with Err set.
+	EventItem
+)
+
+// Event reports render progress to Options.Progress.
+type Event struct {
+	Kind  EventKind
+	Stage Stage
+	// Source is the Name of the image an EventItem is about.
+	Source string
+	// Err is why the item failed, or nil.
+	Err error
+	// Done and Total count finished and scheduled images in this stage.
+	Done, Total int
+}
+
+// Render lays out and renders the sources into a collage. Cancelling ctx
+// stops outstanding work and returns ctx.Err().
+//
+// When rendering fails, the returned Result is still non-nil if some sources
+// were attempted, so callers can report its Failures.
+func (b *Builder) Render(ctx context.Context, sources []Source) (*Result, error) {
+	srcs, meta, res, err := b.prepare(ctx, sources)
+	if err != nil {
+		return res, err
+	}
+	lay, err := b.buildLayout(srcs, meta)
+	if err != nil {
+		return res, err
+	}
+	if err := b.checkCanvasSize(lay); err != nil {
+		return res, err
+	}
+	if need := canvasBytes(lay); b.opts.MaxMemory > 0 && need > b.opts.MaxMemory {
+		return res, fmt.Errorf("the %d×%d canvas needs %s, more than max-memory %s; encode it as PNG or TIFF to render it in strips",
+			lay.width, lay.height, bytesize.Format(need), bytesize.Format(b.opts.MaxMemory))
+	}
+	return res, b.renderCanvas(ctx, srcs, meta, res, lay)
+}
+
+// Encode renders the sources and writes the collage to w in the given format.
+// When the canvas would exceed Options.MaxMemory, PNG and TIFF collages are
+// rendered and written in strips and Result.Image is nil; other formats fail.
+func (b *Builder) Encode(ctx context.Context, w io.Writer, format Format, sources []Source) (*Result, error) {
+	srcs, meta, res, err := b.prepare(ctx, sources)
+	if err != nil {
+		return res, err
+	}
+	lay, err := b.buildLayout(srcs, meta)
+	if err != nil {
+		return res, err
+	}
+	if err := b.checkCanvasSize(lay); err != nil {
+		return res, err
+	}
+	need := canvasBytes(lay)
+	if b.opts.MaxMemory == 0 || need <= b.opts.MaxMemory {
+		if err := b.renderCanvas(ctx, srcs, meta, res, lay); err != nil {
+			return res, err
+		}
+		return res, format.Encode(w, res.Image)
+	}
+	if !format.streams() {
+		return res, b.errCannotStream(lay, format)
+	}
+	return res, b.stream(ctx, w, format, srcs, meta, res, lay)
+}
+
+// errCannotStream explains why a canvas over MaxMemory cannot be encoded.
+func (b *Builder) errCannotStream(lay layout, format Format) error {
+	return fmt.Errorf("the %d×%d canvas needs %s, more than max-memory %s, and %s output cannot be written in strips; use a .png, .tif or .tiff output or raise max-memory",
+		lay.width, lay.height, bytesize.Format(canvasBytes(lay)), bytesize.Format(b.opts.MaxMemory), strings.ToUpper(string(format)))
+}
+
+// prepare runs everything before layout: it reads capture times, filters,
+// checks input sizes, dedupes, sorts and samples the sources and, for justified layouts, probes
+// their aspect ratios.
+func (b *Builder) prepare(ctx context.Context, sources []Source) ([]Source, imageMeta, *Result, error) {
+	var meta imageMeta
+	if len(sources) == 0 {
+		return nil, meta, nil, errors.New("no images to render")
+	}
+	srcs := slices.Clone(sources)
+	res := &Result{}
+
+	// Date filters, bursts, EXIF order, time-based sampling and the calendar
+	// all need capture times; read them once.
+	if b.opts.dateFiltered() || b.opts.BurstWindow > 0 || b.opts.Sort == SortEXIF ||
+		(b.opts.MaxImages > 0 && b.opts.sample().timed()) || b.opts.layout() == LayoutCalendar {
+		times, err := b.captureTimes(ctx, srcs)
+		if err != nil {
+			return nil, meta, res, err
+		}
+		meta.times = times
+	}
+	if b.opts.dateFiltered() {
+		if srcs = b.filterByDate(srcs, meta.times); len(srcs) == 0 {
+			return nil, meta, res, fmt.Errorf("no images captured in %s", b.opts.dateRange())
+		}
+	}
+	if b.opts.MaxInputPixels > 0 {
+		kept, headers, failed, err := b.checkInputSizes(ctx, srcs)
+		res.Failures = append(res.Failures, failed...)
+		if err != nil {
+			return nil, meta, res, err
+		}
+		meta.headers = headers
+		if srcs = kept; len(srcs) == 0 {
+			return nil, meta, res, errors.New("all images are larger than max-input-pixels")
+		}
+	}
+	if b.opts.dedupe() != DedupeOff {
+		deduped, dups, err := b.dedupe(ctx, srcs)
+		if err != nil {
+			return nil, meta, res, err
+		}
+		srcs, res.Duplicates = deduped, dups
+	}
+	if b.opts.analyzed() {
+		kept, rejected, qualities, err := b.filterQuality(ctx, srcs, meta.times)
+		if err != nil {
+			return nil, meta, res, err
+		}
+		srcs, res.Rejected, res.Quality = kept, rejected, qualities
+		if len(srcs) == 0 {
+			return nil, meta, res, errors.New("all images were rejected by the quality filters")
+		}
+	}
+	if err := b.sortSources(ctx, srcs, meta.times); err != nil {
+		return nil, meta, res, err
+	}
+	if srcs = b.sample(srcs, meta.times); len(srcs) == 0 {
+		return nil, meta, res, errors.New("no images with a capture time to sample")
+	}
+
+	b.logf("Rendering %d images", len(srcs))
+	for i, s := range srcs {
+		if i >= 10 {
+			b.logf("... and %d more", len(srcs)-10)
+			break
+		}
+		b.logf("  %s", s.Name())
+	}
+
+	if b.opts.layout() == LayoutJustified {
+		// Justified rows depend on every photo's shape, so read all headers
+		// up front; unreadable ones are handled by the error policy here.
+		aspects, headers, failed, err := b.aspectsFor(ctx, srcs)
+		res.Failures = append(res.Failures, failed...)
+		if err != nil {
+			return nil, meta, res, err
+		}
+		meta.aspects, meta.headers = aspects, headers
+		if b.opts.onError() == OnErrorSkip {
+			srcs = withoutFailed(srcs, failed)
+		}
+	}
+	if len(srcs) == 0 {
+		return nil, meta, res, errors.New("all images failed to render")
+	}
+	return srcs, meta, res, nil
+}
+
+// renderCanvas renders the sources into one in-memory canvas, starting from
+// layout lay, and stores it in res.Image.
+func (b *Builder) renderCanvas(ctx context.Context, srcs []Source, meta imageMeta, res *Result, lay layout) error {
+	for {
+		canvas := image.NewRGBA(image.Rect(0, 0, lay.width, lay.height))
+		if lay.decorate != nil {
+			lay.decorate(canvas)
+		}
+
+		r := b.newRenderer(meta)
+		b.stage(StageRender, len(srcs))
+		tiles, failed, err := b.renderTiles(ctx, canvas, srcs, lay, r, allIndices(len(srcs)))
+		res.Failures = append(res.Failures, failed...)
+		b.logCache(r)
+		if err != nil {
+			return err
+		}
+		if lay.overlay != nil {
+			lay.overlay(canvas)
+		}
+		sortTiles(tiles)
+		res.Image, res.Bounds = canvas, canvas.Rect
+		res.Columns, res.Rows, res.Tiles = lay.columns, lay.rows, tiles
+		res.TileWidth, res.TileHeight = lay.tileWidth, lay.tileHeight
+		if b.opts.onError() != OnErrorSkip || len(failed) == 0 {
+			return nil
+		}
+
+		// Skipped images leave holes; drop them and lay the grid out again so
+		// the remaining photos close ranks. Cached tiles make this pass cheap.
+		srcs = withoutFailed(srcs, failed)
+		b.logf("Skipped %d unreadable images; re-flowing %d images", len(failed), len(srcs))
+		if len(srcs) == 0 {
+			return errors.New("all images failed to render")
+		}
+		if lay, err = b.buildLayout(srcs, meta); err != nil {
+			return err
+		}
+	}
+}
+
+// newRenderer returns a tile renderer configured from the options and the
+// facts gathered before layout.
+func (b *Builder) newRenderer(meta imageMeta) *renderer {
+	return &renderer{
+		cache:    b.cache,
+		crop:     b.opts.crop(),
+		fit:      b.opts.fit(),
+		bg:       b.opts.background(),
+		thumbs:   b.opts.EmbeddedThumbs,
+		resample: b.opts.resample(),
+		linear:   b.opts.LinearLight,
+		sharpen:  b.opts.Sharpen,
+		onError:  b.opts.onError(),
+		times:    meta.times,
+		logf:     b.logf,
+	}
+}
+
+// logCache reports the tile cache hit rate of a finished render pass.
+func (b *Builder) logCache(r *renderer) {
+	if r.cache != nil {
+		b.logf("Tile cache %s: %d hits, %d misses", r.cache.Dir(), r.hits.Load(), r.misses.Load())
+	}
+}
+
+// canvasBytes is the memory an RGBA canvas for lay takes.
+func canvasBytes(lay layout) int64 {
+	return 4 * int64(lay.width) * int64(lay.height)
+}
+
+// allIndices returns 0…n-1.
+func allIndices(n int) []int {
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+// stage announces the start of a pipeline stage over total images.
+func (b *Builder) stage(s Stage, total int) {
+	if b.opts.Progress == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done = 0
+	b.opts.Progress(Event{Kind: EventStage, Stage: s, Total: total})
+}
+
+// item reports one finished image within the current stage.
+func (b *Builder) item(s Stage, total int, name string, err error) {
+	if b.opts.Progress == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done++
+	b.opts.Progress(Event{Kind: EventItem, Stage: s, Source: name, Err: err, Done: b.done, Total: total})
+}
+
+// logf forwards to Options.Logf when set.
+func (b *Builder) logf(format string, args ...any) {
+	if b.opts.Logf != nil {
+		b.opts.Logf(format, args...)
+	}
+}
+
+// Format is an output image encoding.
+type Format string
+
+// Output formats.
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatTIFF Format = "tiff" // uncompressed; BigTIFF past 4 GiB
+)
+
+// FormatFor picks the format from a file name's extension: PNG for .png,
+// TIFF for .tif and .tiff, JPEG otherwise.
+func FormatFor(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return FormatPNG
+	case ".tif", ".tiff":
+		return FormatTIFF
+	}
+	return FormatJPEG
+}
+
+// Encode writes img to w in this format, tagged with an sRGB ICC profile so
+// colour-managed viewers show the tiles as they were converted.
+func (f Format) Encode(w io.Writer, img image.Image) error {
+	switch f {
+	case FormatPNG:
+		if err := png.Encode(icc.PNGWriter(w, icc.SRGB()), img); err != nil {
+			return fmt.Errorf("encode png: %w", err)
+		}
+	case FormatTIFF:
+		rgba, ok := img.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(img.Bounds())
+			draw.Draw(rgba, rgba.Rect, img, rgba.Rect.Min, draw.Src)
+		}
+		enc, err := f.streamEncoder(w, rgba.Rect.Dx(), rgba.Rect.Dy())
+		if err == nil {
+			err = enc.WriteStrip(rgba)
+		}
+		if err == nil {
+			err = enc.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("encode tiff: %w", err)
+		}
+	default:
+		if err := jpeg.Encode(icc.JPEGWriter(w, icc.SRGB()), img, &jpeg.Options{Quality: 90}); err != nil {
+			return fmt.Errorf("encode jpeg: %w", err)
+		}
+	}
+	return nil
+}
+
+// streams reports whether the format can be written strip by strip.
+func (f Format) streams() bool {
+	return f == FormatPNG || f == FormatTIFF
+}
+
+// streamEncoder starts a strip encoder for a width×height collage, tagged
+// with the sRGB profile like Encode.
+func (f Format) streamEncoder(w io.Writer, width, height int) (stripenc.Encoder, error) {
+	switch f {
+	case FormatPNG:
+		return stripenc.NewPNG(icc.PNGWriter(w, icc.SRGB()), width, height)
+	case FormatTIFF:
+		return stripenc.NewTIFF(w, width, height, icc.SRGB())
+	}
+	return nil, fmt.Errorf("%s output cannot be written in strips", f)
+}
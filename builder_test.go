@@ -0,0 +1,158 @@
+package yearcollage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// pngBytes encodes a solid w×h PNG.
+func pngBytes(t *testing.T, w, h int, c color.RGBA) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	fillRect(img, img.Bounds(), c)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// pngSource wraps a solid w×h PNG in an in-memory source.
+func pngSource(t *testing.T, name string, w, h int, c color.RGBA) Source {
+	t.Helper()
+	return Bytes(name, pngBytes(t, w, h, c), time.Time{})
+}
+
+func TestRenderEmitsProgress(t *testing.T) {
+	var sources []Source
+	for i := 4; i >= 0; i-- {
+		sources = append(sources, pngSource(t, fmt.Sprintf("img-%d.png", i), 30, 20, color.RGBA{uint8(50 * i), 0, 0, 255}))
+	}
+
+	var events []Event
+	b, err := New(Options{TileWidth: 10, Columns: 3, Sort: SortName, Jobs: 2, Progress: func(e Event) { events = append(events, e) }})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := b.Render(context.Background(), sources)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got := res.Image.Bounds(); got != image.Rect(0, 0, 30, 20) {
+		t.Fatalf("canvas = %v, want 30x20", got)
+	}
+	// Sorted by name, img-0 (black) comes first although it was passed last.
+	if got := res.Image.RGBAAt(5, 5); got.R != 0 {
+		t.Fatalf("first tile = %v, want img-0", got)
+	}
+
+	if len(events) != 6 || events[0].Kind != EventStage || events[0].Stage != StageRender || events[0].Total != 5 {
+		t.Fatalf("events = %+v, want a render stage and five items", events)
+	}
+	for i, e := range events[1:] {
+		if e.Kind != EventItem || e.Done != i+1 || e.Total != 5 || e.Err != nil {
+			t.Fatalf("event %d = %+v, want item %d/5", i+1, e, i+1)
+		}
+	}
+}
+
+func TestRenderHonoursCancellation(t *testing.T) {
+	sources := []Source{pngSource(t, "a.png", 10, 10, color.RGBA{A: 255})}
+	b, err := New(Options{TileWidth: 10, Columns: 1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.Render(ctx, sources); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Render error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRenderKeepsFailuresWhenCancelledLater(t *testing.T) {
+	sources := []Source{
+		pngSource(t, "a.png", 10, 10, color.RGBA{A: 255}),
+		pngSource(t, "huge.png", 40, 30, color.RGBA{A: 255}),
+	}
+	// Cancel as each later stage starts, after the size check has already
+	// skipped huge.png.
+	for _, stage := range []Stage{StageDedupe, StageAnalyze} {
+		t.Run(string(stage), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			b, err := New(Options{
+				TileWidth: 10, Columns: 1, OnError: OnErrorSkip, MaxInputPixels: 1000,
+				Dedupe: DedupeExact, MinSharpness: 1,
+				Progress: func(e Event) {
+					if e.Kind == EventStage && e.Stage == stage {
+						cancel()
+					}
+				},
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			res, err := b.Render(ctx, sources)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Render error = %v, want context.Canceled", err)
+			}
+			if res == nil || len(res.Failures) != 1 || res.Failures[0].Source != "huge.png" {
+				t.Fatalf("result = %+v, want huge.png's failure", res)
+			}
+		})
+	}
+}
+
+func TestEncodeWritesFormat(t *testing.T) {
+	sources := []Source{pngSource(t, "a.png", 10, 10, color.RGBA{0, 200, 0, 255})}
+	b, err := New(Options{TileWidth: 8, Columns: 1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := b.Encode(context.Background(), &buf, FormatPNG, sources); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	img, format, err := image.Decode(&buf)
+	if err != nil || format != "png" || img.Bounds().Dx() != 8 {
+		t.Fatalf("decoded %s %v (err %v), want an 8px png", format, img.Bounds(), err)
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	for path, want := range map[string]Format{"out.PNG": FormatPNG, "out.jpg": FormatJPEG, "out.tif": FormatTIFF, "out.TIFF": FormatTIFF, "out": FormatJPEG} {
+		if got := FormatFor(path); got != want {
+			t.Fatalf("FormatFor(%q) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestPickColumnsForCollage(t *testing.T) {
+	// The heuristic aims for balanced grids; spot-check a few shapes.
+	cases := []struct {
+		name   string
+		n      int
+		target float64
+		want   int
+	}{
+		{"ten images 16:9", 10, 16.0 / 9.0, 5},
+		{"ten images 1:1", 10, 1.0, 4},
+		{"single image", 1, 1.0, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := pickColumnsForCollage(tc.n, tc.target)
+			if got != tc.want {
+				t.Fatalf("pickColumnsForCollage(%d, %.3f) = %d, want %d", tc.n, tc.target, got, tc.want)
+			}
+		})
+	}
+}
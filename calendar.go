@@ -0,0 +1,253 @@
+package yearcollage
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// CalendarMode selects the grid of the calendar layout.
+type CalendarMode string
+
+// Calendar modes.
+const (
+	CalendarDays  CalendarMode = "days"  // 12 month rows × 31 day columns
+	CalendarWeeks CalendarMode = "weeks" // 7 weekday rows × 53 week columns
+)
+
+var (
+	calendarBackground = color.RGBA{0x1e, 0x1e, 0x1e, 0xff}
+	calendarEmptyDay   = color.RGBA{0x38, 0x38, 0x38, 0xff}
+	calendarWeekend    = color.RGBA{0x4a, 0x4a, 0x4a, 0xff}
+	calendarBadge      = color.RGBA{0x00, 0x00, 0x00, 0xa0} // translucent black behind weekday labels
+	calendarLabel      = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+)
+
+// calendarLayout places one representative photo per day of year into a
+// calendar grid of cellWidth×cellHeight tiles. The representative is the
+// earliest photo of the day; every other photo gets an empty cell and is not
+// rendered. Valid days without photos are drawn as muted cells, and month and
+// weekday labels are drawn in a margin above and left of the grid. The days
+// grid has no weekday axis, so each of its cells carries its weekday in the
+// corner, over the photo, and empty Saturdays and Sundays are shaded lighter.
+func calendarLayout(paths []string, times []time.Time, year int, mode CalendarMode, cellWidth, cellHeight int, ratio float64) layout {
+	// Pick the earliest photo per day, breaking ties by path for stable output.
+	order := make([]int, len(paths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ta, tb := times[order[a]], times[order[b]]
+		if ta.Equal(tb) {
+			return paths[order[a]] < paths[order[b]]
+		}
+		return ta.Before(tb)
+	})
+	picks := make(map[int]int) // day of year → path index
+	for _, idx := range order {
+		t := times[idx]
+		if t.Year() != year {
+			continue
+		}
+		if _, taken := picks[t.YearDay()]; !taken {
+			picks[t.YearDay()] = idx
+		}
+	}
+
+	jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	days := jan1.AddDate(1, 0, 0).Sub(jan1).Hours() / 24
+	// Monday-based offset of January 1st, so week columns start on Mondays.
+	offset := (int(jan1.Weekday()) + 6) % 7
+
+	// position maps a day of year (1-based) to its grid cell.
+	position := func(yday int) (row, col int) {
+		d := jan1.AddDate(0, 0, yday-1)
+		if mode == CalendarWeeks {
+			return (int(d.Weekday()) + 6) % 7, (yday - 1 + offset) / 7
+		}
+		return int(d.Month()) - 1, d.Day() - 1
+	}
+
+	rows, cols := 12, 31
+	if mode == CalendarWeeks {
+		rows, cols = 7, (int(days)-1+offset)/7+1
+	}
+
+	// Labels scale with the cell size so they stay legible on big tiles.
+	scale := max(1, int(math.Round(float64(cellHeight)/60)))
+	pad := 4 * scale
+	face := basicfont.Face7x13
+	left := 3*face.Advance*scale + 2*pad
+	top := face.Height*scale + 2*pad
+
+	cellRect := func(row, col int) image.Rectangle {
+		min := image.Pt(left+col*cellWidth, top+row*cellHeight)
+		return image.Rectangle{Min: min, Max: min.Add(image.Pt(cellWidth, cellHeight))}
+	}
+
+	lay := layout{
+		width:   left + cols*cellWidth,
+		height:  top + rows*cellHeight,
+		cells:   make([]cell, len(paths)),
+		columns: cols,
+		rows:    rows,
+		slots:   int(days),
+
+		tileWidth:  cellWidth,
+		tileHeight: cellHeight,
+	}
+	for yday, idx := range picks {
+		row, col := position(yday)
+		lay.cells[idx] = cell{rect: cellRect(row, col), ratio: ratio, row: row, col: col}
+	}
+
+	lay.decorate = func(dst draw.Image) {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(calendarBackground), image.Point{}, draw.Src)
+
+		for yday := 1; yday <= int(days); yday++ {
+			if _, ok := picks[yday]; !ok {
+				draw.Draw(dst, cellRect(position(yday)), image.NewUniform(emptyDayColor(jan1.AddDate(0, 0, yday-1), mode)), image.Point{}, draw.Src)
+			}
+		}
+
+		if mode == CalendarWeeks {
+			for wd := 0; wd < 7; wd++ {
+				name := time.Weekday((wd + 1) % 7).String()[:3]
+				drawLabel(dst, name, image.Rect(0, top+wd*cellHeight, left, top+(wd+1)*cellHeight), scale)
+			}
+			for m := time.January; m <= time.December; m++ {
+				_, col := position(time.Date(year, m, 1, 0, 0, 0, 0, time.UTC).YearDay())
+				// Left-align month names over the week that contains the 1st.
+				r := image.Rect(left+col*cellWidth, 0, left+col*cellWidth+3*face.Advance*scale+pad, top)
+				drawLabel(dst, m.String()[:3], r, scale)
+			}
+			return
+		}
+
+		for m := 0; m < 12; m++ {
+			drawLabel(dst, time.Month(m + 1).String()[:3], image.Rect(0, top+m*cellHeight, left, top+(m+1)*cellHeight), scale)
+		}
+		for d := 0; d < 31; d++ {
+			drawLabel(dst, strconv.Itoa(d+1), image.Rect(left+d*cellWidth, 0, left+(d+1)*cellWidth, top), scale)
+		}
+	}
+
+	if mode == CalendarDays {
+		lay.overlay = func(dst draw.Image) {
+			for yday := 1; yday <= int(days); yday++ {
+				name := jan1.AddDate(0, 0, yday-1).Weekday().String()[:2]
+				drawBadge(dst, name, cellRect(position(yday)), badgeScale(cellHeight))
+			}
+		}
+	}
+
+	return lay
+}
+
+// badgeScale is the magnification of weekday labels in day cells, smaller
+// than the margin labels so they cover little of the photo.
+func badgeScale(cellHeight int) int {
+	return max(1, int(math.Round(float64(cellHeight)/150)))
+}
+
+// drawBadge renders a short label on a translucent backing in the top-left
+// corner of cell. Cells too small to keep most of their photo visible get
+// none.
+func drawBadge(dst draw.Image, text string, cell image.Rectangle, scale int) {
+	face := basicfont.Face7x13
+	pad := scale
+	size := image.Pt(len(text)*face.Advance*scale+2*pad, face.Height*scale+2*pad)
+	if 2*size.X > cell.Dx() || 2*size.Y > cell.Dy() {
+		return
+	}
+	r := image.Rectangle{Min: cell.Min.Add(image.Pt(pad, pad))}
+	r.Max = r.Min.Add(size)
+	draw.Draw(dst, r, image.NewUniform(calendarBadge), image.Point{}, draw.Over)
+	drawLabel(dst, text, r, scale)
+}
+
+// emptyDayColor is the fill of a day without a photo. Weekends stand out in
+// the days grid, where no row or column names the weekday.
+func emptyDayColor(d time.Time, mode CalendarMode) color.RGBA {
+	if mode == CalendarDays && (d.Weekday() == time.Saturday || d.Weekday() == time.Sunday) {
+		return calendarWeekend
+	}
+	return calendarEmptyDay
+}
+
+// drawLabel renders text in the built-in 7×13 bitmap font, magnified by an
+// integer scale, centered inside r.
+func drawLabel(dst draw.Image, text string, r image.Rectangle, scale int) {
+	face := basicfont.Face7x13
+	mask := image.NewAlpha(image.Rect(0, 0, len(text)*face.Advance, face.Height))
+	d := font.Drawer{Dst: mask, Src: image.Opaque, Face: face, Dot: fixed.P(0, face.Ascent)}
+	d.DrawString(text)
+
+	size := mask.Bounds().Size().Mul(scale)
+	big := image.NewAlpha(image.Rectangle{Max: size})
+	draw.NearestNeighbor.Scale(big, big.Bounds(), mask, mask.Bounds(), draw.Src, nil)
+
+	at := r.Min.Add(r.Size().Sub(size).Div(2))
+	draw.DrawMask(dst, image.Rectangle{Min: at, Max: at.Add(size)}, image.NewUniform(calendarLabel), image.Point{}, big, image.Point{}, draw.Over)
+}
+
+// busiestYear returns the year with the most photos, preferring the later
+// year on ties. Zero timestamps (unreadable files) are ignored.
+func busiestYear(times []time.Time) int {
+	counts := make(map[int]int)
+	best, bestCount := time.Now().Year(), 0
+	for _, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		y := t.Year()
+		counts[y]++
+		if counts[y] > bestCount || (counts[y] == bestCount && y > best) {
+			best, bestCount = y, counts[y]
+		}
+	}
+	return best
+}
+
+// captureTimes reads the EXIF capture time (falling back to mtime) for every
+// source on `jobs` workers, keyed by name.
+func (b *Builder) captureTimes(ctx context.Context, srcs []Source) (map[string]time.Time, error) {
+	times := make([]time.Time, len(srcs))
+	b.stage(StageProbe, len(srcs))
+
+	sem := make(chan struct{}, b.opts.jobs())
+	var wg sync.WaitGroup
+	for i, s := range srcs {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			times[i] = b.captureTime(s)
+			b.item(StageProbe, len(srcs), s.Name(), nil)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]time.Time, len(srcs))
+	for i, s := range srcs {
+		byName[s.Name()] = times[i]
+	}
+	return byName, nil
+}
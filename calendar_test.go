@@ -0,0 +1,132 @@
+package yearcollage
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestCalendarLayoutPicksOnePhotoPerDay(t *testing.T) {
+	day := func(m time.Month, d, hour int) time.Time {
+		return time.Date(2025, m, d, hour, 0, 0, 0, time.Local)
+	}
+	paths := []string{"late.jpg", "early.jpg", "march.jpg", "other-year.jpg"}
+	times := []time.Time{day(time.January, 2, 18), day(time.January, 2, 8), day(time.March, 5, 12), time.Date(2024, time.June, 1, 0, 0, 0, 0, time.Local)}
+
+	for _, mode := range []CalendarMode{CalendarDays, CalendarWeeks} {
+		t.Run(string(mode), func(t *testing.T) {
+			lay := calendarLayout(paths, times, 2025, mode, 10, 10, 1)
+
+			if !lay.cells[0].rect.Empty() {
+				t.Fatalf("later photo of the same day got a cell")
+			}
+			if lay.cells[1].rect.Empty() || lay.cells[2].rect.Empty() {
+				t.Fatalf("representatives missing: %v, %v", lay.cells[1].rect, lay.cells[2].rect)
+			}
+			if !lay.cells[3].rect.Empty() {
+				t.Fatalf("photo from another year got a cell")
+			}
+			if lay.decorate == nil {
+				t.Fatalf("calendar layout has no decorations")
+			}
+
+			// Jan 2 2025 is a Thursday in the first week; March 5 a Wednesday.
+			origin := lay.cells[1].rect.Min
+			gotMarch := lay.cells[2].rect.Min.Sub(origin).Div(10)
+			want := image.Pt(3, 2) // days: +3 days, +2 months
+			if mode == CalendarWeeks {
+				want = image.Pt(9, -1) // weeks: +9 weeks, one weekday earlier
+			}
+			if gotMarch != want {
+				t.Fatalf("March 5 offset from Jan 2 = %v cells, want %v", gotMarch, want)
+			}
+		})
+	}
+}
+
+func TestCalendarDaysShadesEmptyWeekends(t *testing.T) {
+	lay := calendarLayout(nil, nil, 2025, CalendarDays, 10, 10, 1)
+	canvas := image.NewRGBA(image.Rect(0, 0, lay.width, lay.height))
+	lay.decorate(canvas)
+
+	origin := image.Pt(lay.width-31*10, lay.height-12*10)
+	at := func(day int) color.RGBA { // a January day's cell center
+		return canvas.RGBAAt(origin.X+(day-1)*10+5, origin.Y+5)
+	}
+	// January 3 2025 is a Friday, the 4th and 5th the weekend.
+	if got := at(3); got != calendarEmptyDay {
+		t.Fatalf("Friday = %v, want %v", got, calendarEmptyDay)
+	}
+	for _, d := range []int{4, 5} {
+		if got := at(d); got != calendarWeekend {
+			t.Fatalf("January %d = %v, want weekend %v", d, got, calendarWeekend)
+		}
+	}
+	// The weeks grid names weekdays in its margin and keeps one grey.
+	if got := emptyDayColor(time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC), CalendarWeeks); got != calendarEmptyDay {
+		t.Fatalf("weeks grid Saturday = %v, want %v", got, calendarEmptyDay)
+	}
+}
+
+func TestCalendarDaysLabelsWeekdaysOverPhotos(t *testing.T) {
+	red := color.RGBA{200, 0, 0, 255}
+	// March 1 2023 is a Wednesday.
+	src := Bytes("wed.png", pngBytes(t, 60, 60, red), time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+	b, err := New(Options{TileWidth: 60, Layout: LayoutCalendar})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := b.Render(context.Background(), []Source{src})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	canvas := res.Image
+	rect := res.Tiles[0].Rect
+	if got := canvas.RGBAAt(rect.Max.X-5, rect.Max.Y-5); got != red {
+		t.Fatalf("photo corner = %v, want %v", got, red)
+	}
+
+	// The badge holds "We" in the top-left corner: darkened photo around
+	// light glyph pixels.
+	badge := image.Rect(rect.Min.X+1, rect.Min.Y+1, rect.Min.X+17, rect.Min.Y+16)
+	var dark, light int
+	for y := badge.Min.Y; y < badge.Max.Y; y++ {
+		for x := badge.Min.X; x < badge.Max.X; x++ {
+			switch c := canvas.RGBAAt(x, y); {
+			case c == calendarLabel:
+				light++
+			case c.R < red.R:
+				dark++
+			}
+		}
+	}
+	if light == 0 || dark == 0 {
+		t.Fatalf("weekday badge: %d label and %d shaded pixels, want both", light, dark)
+	}
+}
+
+func TestCalendarLayoutSize(t *testing.T) {
+	days := calendarLayout(nil, nil, 2025, CalendarDays, 10, 10, 1)
+	weeks := calendarLayout(nil, nil, 2025, CalendarWeeks, 10, 10, 1)
+	// Label margins are constant per scale, so compare grid extents.
+	if got := days.width - weeks.width; got != (31-53)*10 {
+		t.Fatalf("days/weeks width difference = %d, want %d", got, (31-53)*10)
+	}
+	if got := days.height - weeks.height; got != (12-7)*10 {
+		t.Fatalf("days/weeks height difference = %d, want %d", got, (12-7)*10)
+	}
+}
+
+func TestBusiestYear(t *testing.T) {
+	times := []time.Time{
+		time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		{},
+	}
+	if got := busiestYear(times); got != 2025 {
+		t.Fatalf("busiestYear = %d, want 2025", got)
+	}
+}
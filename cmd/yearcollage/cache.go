@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/luceast/yearcollage/internal/bytesize"
+	"github.com/luceast/yearcollage/internal/cache"
+)
+
+// runCache implements `yearcollage cache stats|prune`.
+func runCache(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: yearcollage cache stats|prune --cache-dir DIR")
+	}
+	if args[0] != "stats" && args[0] != "prune" {
+		return fmt.Errorf("unknown cache command %q (use \"stats\" or \"prune\")", args[0])
+	}
+
+	fs := flag.NewFlagSet("cache "+args[0], flag.ExitOnError)
+	dir := fs.String("cache-dir", "", "Tile cache directory")
+	maxAge := fs.Duration("max-age", 0, "prune: remove tiles not used within this duration, e.g. 720h")
+	maxSize := fs.String("max-size", "", "prune: evict least recently used tiles until the cache fits, e.g. 2GiB")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("missing required flag: --cache-dir")
+	}
+
+	// Neither command creates the cache; there is nothing to report or
+	// prune in one that does not exist yet.
+	c, err := cache.OpenExisting(*dir)
+	if errors.Is(err, cache.ErrNoCache) {
+		fmt.Printf("No cache at %s\n", *dir)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "stats":
+		s, err := c.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cache:   %s\n", c.Dir())
+		fmt.Printf("Entries: %d\n", s.Entries)
+		fmt.Printf("Size:    %s\n", bytesize.Format(s.Bytes))
+		if s.Entries > 0 {
+			fmt.Printf("Oldest:  %s\n", s.Oldest.Format(time.RFC3339))
+			fmt.Printf("Newest:  %s\n", s.Newest.Format(time.RFC3339))
+		}
+	case "prune":
+		var limit int64
+		if *maxSize != "" {
+			if limit, err = bytesize.Parse(*maxSize); err != nil {
+				return fmt.Errorf("invalid max-size %q: %w", *maxSize, err)
+			}
+		}
+		if *maxAge <= 0 && limit <= 0 {
+			return fmt.Errorf("prune needs --max-age and/or --max-size")
+		}
+		removed, freed, err := c.Prune(*maxAge, limit)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d tiles, freed %s\n", removed, bytesize.Format(freed))
+	}
+	return nil
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	flag "github.com/spf13/pflag"
 
@@ -10,24 +15,88 @@ import (
 
 // main wires CLI flags into a Config and hands control to the app package.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		if err := runCache(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// `yearcollage plan ...` is -dry-run with the same flags.
+	args := os.Args[1:]
+	plan := len(args) > 0 && args[0] == "plan"
+	if plan {
+		args = args[1:]
+	}
 	cfg := app.Config{}
 
 	// CLI flags (lowercase/kebab to match README) with short aliases.
-	flag.StringVarP(&cfg.InputDir, "input", "i", "", "Input directory containing images")
-	flag.StringVarP(&cfg.Output, "output", "o", "collage.jpg", "Output collage file path")
+	flag.StringArrayVarP(&cfg.InputDirs, "input", "i", nil, "Input directory containing images (repeatable)")
+	flag.StringVar(&cfg.FilesFrom, "files-from", "", "Read image paths from this file, one per line (- for stdin)")
+	flag.BoolVar(&cfg.Null, "null", false, "Paths in -files-from are NUL-terminated, e.g. from find -print0")
+	flag.StringArrayVar(&cfg.Include, "include", nil, "Only use files matching this glob (repeatable; ** spans directories, e.g. '2025/**/*.jpg'; a later '!pattern' drops matches again)")
+	flag.StringArrayVar(&cfg.Exclude, "exclude", nil, "Skip files and directories matching this glob (repeatable, e.g. '@eaDir' or '**/Screenshot*'; a later '!pattern' keeps matches again)")
+	flag.StringVarP(&cfg.Output, "output", "o", "collage.jpg", "Output collage file path (.jpg, .png, .tif or .tiff)")
 	flag.StringVarP(&cfg.TileAspect, "tile-aspect", "a", "1:1", "Target tile aspect ratio, e.g. 1:1, 3:2, 4:3")
 	flag.IntVarP(&cfg.TileWidth, "tile-width", "w", 400, "Tile width in pixels")
 	flag.IntVarP(&cfg.Columns, "columns", "c", 20, "Number of columns in the collage grid")
 	flag.StringVarP(&cfg.CollageAspect, "collage-aspect", "r", "", "Target aspect ratio for the final collage (overrides -columns if set)")
 	flag.StringVarP(&cfg.SortMode, "sort", "s", "time", "Sort images by: time (file mod time), name (alphabetical), or exif (DateTimeOriginal/DateTimeDigitized)")
+	flag.StringVar(&cfg.From, "from", "", "Only use photos captured on or after this date (2025-06-01 or 2025-06-01T08:00:00)")
+	flag.StringVar(&cfg.To, "to", "", "Only use photos captured on or before this date (a bare date includes the whole day)")
+	flag.IntVar(&cfg.Year, "year", 0, "Only use photos captured in this year (shorthand for -from/-to)")
+	flag.StringVar(&cfg.Dedupe, "dedupe", "off", "Drop duplicate photos, keeping the best copy: off, exact (identical files), or perceptual (visually near-identical)")
+	flag.IntVar(&cfg.DedupeThreshold, "dedupe-threshold", 6, "Perceptual dedupe: max differing bits of 64 for photos to count as duplicates")
+	flag.Float64Var(&cfg.MinSharpness, "min-sharpness", 0, "Drop photos whose sharpness score (variance of Laplacian) is below this; the log shows the range (0 = keep all)")
+	flag.DurationVar(&cfg.Burst, "burst", 0, "Keep only the sharpest photo of shots taken within this interval of each other, e.g. 2s (0 = off)")
+	flag.IntVar(&cfg.MaxImages, "max-images", 0, "Use at most this many photos, chosen by -sample after sorting (0 = all)")
+	flag.StringVar(&cfg.Sample, "sample", "even-time", "How -max-images picks photos: even-time, per-day, per-week, per-month, or random")
+	flag.Int64Var(&cfg.Seed, "seed", 0, "Seed for -sample=random; the same seed gives the same pick")
 
-	flag.Parse()
+	flag.IntVarP(&cfg.Jobs, "jobs", "j", 0, "Number of images processed in parallel (0 = GOMAXPROCS)")
+	flag.Int64Var(&cfg.MaxInputPixels, "max-input-pixels", 250_000_000, "Reject photos with more pixels than this before decoding them; -on-error decides what happens to them (0 = no limit)")
+	flag.Int64Var(&cfg.MaxOutputPixels, "max-output-pixels", 1_000_000_000, "Refuse to render a collage with more pixels than this, e.g. after a typo in -tile-width (0 = no limit)")
+	flag.StringVar(&cfg.MaxMemory, "max-memory", "", "Memory budget for the canvas, e.g. 4GiB; larger PNG and TIFF collages are written in strips, larger JPEGs are refused (empty = unlimited)")
+	flag.StringVar(&cfg.CacheDir, "cache-dir", "", "Directory for cached tiles; reruns only process changed photos")
+	flag.StringVar(&cfg.OnError, "on-error", "fail", "What to do with unreadable images: fail, skip (re-flow the grid), or placeholder")
+	flag.StringVar(&cfg.ErrorReport, "error-report", "", "Write failed image paths and reasons as JSON to this file")
+	flag.StringVar(&cfg.Manifest, "manifest", "", "Write each tile's source, capture time, grid position, canvas and crop rectangles and orientation as JSON to this file")
+	flag.StringVar(&cfg.Layout, "layout", "grid", "Layout: grid (uniform tiles), justified (rows of equal height keeping each photo's aspect), or calendar (one photo per day)")
+	flag.IntVar(&cfg.RowHeight, "row-height", 0, "Target row height for the justified layout (0 = tile-width / tile-aspect)")
+	flag.Float64Var(&cfg.MaxCrop, "max-crop", 0, "Justified layout: fraction (0-0.5) of a photo that may be cropped to keep rows near the target height")
+	flag.StringVar(&cfg.Calendar, "calendar", "days", "Calendar layout grid: days (12 months × 31 days, each labelled with its weekday) or weeks (53 weeks × 7 weekdays)")
+	flag.StringVar(&cfg.Crop, "crop", "center", "Crop placement: center, entropy (most detail), saliency (edges, skin tones, contrast), or top")
+	flag.StringVar(&cfg.Fit, "fit", "crop", "How photos fill tiles: crop (fill, trimming edges), contain (whole photo on -background), or blur-fill (whole photo over a blurred copy)")
+	flag.StringVar(&cfg.Background, "background", "#000000", "Background colour behind photos with -fit=contain, as #rrggbb")
+	flag.StringVar(&cfg.Resample, "resample", "fast", "Scaling filter: fast (approximate bilinear), nearest, bilinear, catmullrom, or lanczos (sharpest, slowest)")
+	flag.BoolVar(&cfg.LinearLight, "linear-light", false, "Scale in linear light so fine bright detail keeps its brightness (slower)")
+	flag.StringVar(&cfg.Sharpen, "sharpen", "", "Unsharp mask applied to each tile after scaling, as amount,radius, e.g. 0.8,1")
+	flag.BoolVar(&cfg.EmbeddedThumbs, "use-embedded-thumbs", false, "Render small tiles from the JPEG's EXIF thumbnail when it is large enough, skipping the full decode")
+
+	flag.StringVar(&cfg.Progress, "progress", "auto", "Progress display: auto (bar on a terminal, plain lines otherwise), bar, plain, or off")
+	flag.BoolVar(&cfg.DryRun, "dry-run", false, "Print image count, grid, canvas size and memory/file size estimates instead of rendering (same as the plan subcommand)")
+	flag.BoolVar(&cfg.JSON, "json", false, "With plan or -dry-run: print the plan as JSON")
+
+	_ = flag.CommandLine.Parse(args) // exits on error
+	cfg.DryRun = cfg.DryRun || plan
 
 	if err := cfg.Validate(); err != nil {
 		log.Fatal(err)
 	}
 
-	if err := app.Run(cfg); err != nil {
+	// The first Ctrl-C cancels the render cleanly; a second one kills the
+	// process as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := app.Run(ctx, cfg); err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Print("Interrupted; no collage written")
+			os.Exit(130)
+		}
 		log.Fatalf("yearcollage failed: %v", err)
 	}
 }
@@ -0,0 +1,35 @@
+package yearcollage
+
+import (
+	"image"
+	"io"
+
+	"github.com/luceast/yearcollage/internal/icc"
+)
+
+// colorTransform returns the conversion from the ICC profile embedded in rs
+// to sRGB. It returns nil for untagged photos, sRGB profiles and profiles
+// that cannot be converted (CMYK, LUT-based); like browsers, those are shown
+// as sRGB.
+func colorTransform(rs io.ReadSeeker) *icc.Transform {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil
+	}
+	data, err := icc.Extract(rs)
+	if err != nil || data == nil {
+		return nil
+	}
+	p, err := icc.Parse(data)
+	if err != nil || p.IsSRGB() {
+		return nil
+	}
+	return p.ToSRGB()
+}
+
+// inSRGB applies t to img, or returns img unchanged when t is nil.
+func inSRGB(t *icc.Transform, img image.Image) image.Image {
+	if t == nil {
+		return img
+	}
+	return t.Apply(img)
+}
@@ -0,0 +1,82 @@
+package yearcollage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/luceast/yearcollage/internal/icc"
+)
+
+// swappedProfile returns the sRGB profile with the red and green primaries
+// exchanged, so converting it to sRGB turns red pixels green.
+func swappedProfile() []byte {
+	p := bytes.Clone(icc.SRGB())
+	const tagTable = 128 + 4
+	for i := tagTable; i < tagTable+12*9; i += 12 {
+		switch string(p[i : i+4]) {
+		case "rXYZ":
+			copy(p[i:], "gXYZ")
+		case "gXYZ":
+			copy(p[i:], "rXYZ")
+		}
+	}
+	return p
+}
+
+func TestProcessTileAppliesEmbeddedProfile(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	fillRect(red, red.Bounds(), color.RGBA{0xff, 0, 0, 0xff})
+	spec := tileSpec{width: 8, height: 8, ratio: 1, crop: CropCenter, fit: FitCrop}
+
+	cases := []struct {
+		name    string
+		encode  func(*bytes.Buffer) error
+		profile bool
+	}{
+		{"png untagged", func(b *bytes.Buffer) error { return png.Encode(b, red) }, false},
+		{"png iCCP", func(b *bytes.Buffer) error { return png.Encode(icc.PNGWriter(b, swappedProfile()), red) }, true},
+		{"jpeg APP2", func(b *bytes.Buffer) error {
+			return jpeg.Encode(icc.JPEGWriter(b, swappedProfile()), red, &jpeg.Options{Quality: 100})
+		}, true},
+		{"png sRGB", func(b *bytes.Buffer) error { return png.Encode(icc.PNGWriter(b, icc.SRGB()), red) }, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tc.encode(&buf); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			tile, _, err := processTile(Bytes(tc.name, buf.Bytes(), time.Time{}), spec)
+			if err != nil {
+				t.Fatalf("processTile: %v", err)
+			}
+			got := tile.RGBAAt(4, 4)
+			green := got.G > 200 && got.R < 40
+			if green != tc.profile {
+				t.Fatalf("tile pixel = %v, converted = %v, want converted = %v", got, green, tc.profile)
+			}
+		})
+	}
+}
+
+func TestEncodeEmbedsSRGBProfile(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for _, format := range []Format{FormatJPEG, FormatPNG} {
+		var buf bytes.Buffer
+		if err := format.Encode(&buf, img); err != nil {
+			t.Fatalf("%s: Encode: %v", format, err)
+		}
+		profile, err := icc.Extract(bytes.NewReader(buf.Bytes()))
+		if err != nil || !bytes.Equal(profile, icc.SRGB()) {
+			t.Fatalf("%s: embedded profile = %d bytes (err %v), want the sRGB profile", format, len(profile), err)
+		}
+		if _, _, err := image.Decode(&buf); err != nil {
+			t.Fatalf("%s: decode: %v", format, err)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package yearcollage
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// CropMode decides where the crop window sits when a photo is trimmed to its
+// tile aspect.
+type CropMode string
+
+// Crop modes.
+const (
+	CropCenter   CropMode = "center"
+	CropTop      CropMode = "top"      // keep the top of portraits
+	CropEntropy  CropMode = "entropy"  // the most detailed region
+	CropSaliency CropMode = "saliency" // edges, skin tones and colour contrast
+)
+
+// analysisSize bounds the long edge of the downscaled copy that smart crop
+// modes score; detail beyond this does not change which window wins.
+const analysisSize = 128
+
+// cropToAspect returns a view of the image cropped to the target aspect ratio.
+// The window always keeps the full extent of one axis; mode decides where it
+// sits along the other: centered, at the top, or over the most interesting
+// region (entropy, saliency).
+func cropToAspect(img image.Image, target float64, mode CropMode) image.Image {
+	return subImage(img, cropWindow(img, target, mode))
+}
+
+// cropWindow returns the window cropToAspect keeps: the whole image when it
+// is empty or already has the target aspect, cropRect otherwise.
+func cropWindow(img image.Image, target float64, mode CropMode) image.Rectangle {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return b
+	}
+	if srcRatio := float64(w) / float64(h); math.Abs(srcRatio-target) < 1e-9 {
+		return b
+	}
+	return cropRect(img, target, mode)
+}
+
+// subImage returns the rect part of img, sharing its pixels when the image
+// type allows it.
+func subImage(img image.Image, rect image.Rectangle) image.Image {
+	if rect == img.Bounds() {
+		return img
+	}
+	if si, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return si.SubImage(rect)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// cropRect picks the crop window for the target aspect inside img's bounds.
+func cropRect(img image.Image, target float64, mode CropMode) image.Rectangle {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// Too wide: slide a narrower window horizontally. Too tall: vertically.
+	horizontal := float64(w)/float64(h) > target
+	size, extent := h, w
+	if horizontal {
+		size = int(math.Round(float64(h) * target))
+	} else {
+		size = int(math.Round(float64(w) / target))
+		extent = h
+	}
+	slack := extent - size
+
+	offset := slack / 2
+	switch mode {
+	case CropTop:
+		// Keep the top of tall photos (heads, skylines); wide photos have
+		// no "top" to prefer, so they stay centered.
+		if !horizontal {
+			offset = 0
+		}
+	case CropEntropy, CropSaliency:
+		offset = interestingOffset(img, horizontal, size, slack, mode)
+	}
+
+	if horizontal {
+		return image.Rect(b.Min.X+offset, b.Min.Y, b.Min.X+offset+size, b.Max.Y)
+	}
+	return image.Rect(b.Min.X, b.Min.Y+offset, b.Max.X, b.Min.Y+offset+size)
+}
+
+// interestingOffset scores every window position on a small copy of img and
+// returns the best offset (in source pixels) along the sliding axis. Ties go
+// to the window closest to the center so flat images crop like center mode.
+func interestingOffset(img image.Image, horizontal bool, size, slack int, mode CropMode) int {
+	if slack <= 0 {
+		return 0
+	}
+	b := img.Bounds()
+	scale := math.Min(1, float64(analysisSize)/float64(max(b.Dx(), b.Dy())))
+	aw := max(1, int(math.Round(float64(b.Dx())*scale)))
+	ah := max(1, int(math.Round(float64(b.Dy())*scale)))
+	small := image.NewRGBA(image.Rect(0, 0, aw, ah))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, b, draw.Src, nil)
+
+	extent := ah
+	if horizontal {
+		extent = aw
+	}
+	win := min(extent, max(1, int(math.Round(float64(size)*scale))))
+
+	var score func(start int) float64
+	if mode == CropEntropy {
+		score = entropyScorer(small, horizontal, win)
+	} else {
+		score = saliencyScorer(small, horizontal, win)
+	}
+
+	best, bestScore, bestDist := 0, math.Inf(-1), math.Inf(1)
+	center := float64(extent-win) / 2
+	for start := 0; start <= extent-win; start++ {
+		sc := score(start)
+		dist := math.Abs(float64(start) - center)
+		if sc > bestScore+1e-9 || (math.Abs(sc-bestScore) <= 1e-9 && dist < bestDist) {
+			best, bestScore, bestDist = start, sc, dist
+		}
+	}
+
+	// Map back to source pixels, keeping the window inside the image. A
+	// winner at the analysis center maps to the exact source center.
+	if extent == win || bestDist <= 0.5 {
+		return slack / 2
+	}
+	offset := int(math.Round(float64(best) * float64(slack) / float64(extent-win)))
+	return clampInt(offset, 0, slack)
+}
+
+// luma returns Rec. 601 luminance in [0, 255] for an RGBA pixel.
+func luma(p []uint8) float64 {
+	return 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+}
+
+// edgeMap computes the Sobel gradient magnitude of the luminance.
+func edgeMap(img *image.RGBA) []float64 {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	lum := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			lum[y*w+x] = luma(img.Pix[img.PixOffset(x, y):])
+		}
+	}
+	at := func(x, y int) float64 {
+		return lum[clampInt(y, 0, h-1)*w+clampInt(x, 0, w-1)]
+	}
+
+	edges := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			edges[y*w+x] = math.Hypot(gx, gy)
+		}
+	}
+	return edges
+}
+
+// lineSums collapses a per-pixel map onto the sliding axis, so a window score
+// is just the sum of `win` consecutive lines.
+func lineSums(values []float64, w, h int, horizontal bool) []float64 {
+	n := h
+	if horizontal {
+		n = w
+	}
+	sums := make([]float64, n)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if horizontal {
+				sums[x] += values[y*w+x]
+			} else {
+				sums[y] += values[y*w+x]
+			}
+		}
+	}
+	return sums
+}
+
+// windowSum returns the sum of sums[start:start+win] via a prefix array.
+func windowSum(prefix []float64, start, win int) float64 {
+	return prefix[start+win] - prefix[start]
+}
+
+// prefixSums returns running totals of sums with a leading zero.
+func prefixSums(sums []float64) []float64 {
+	prefix := make([]float64, len(sums)+1)
+	for i, v := range sums {
+		prefix[i+1] = prefix[i] + v
+	}
+	return prefix
+}
+
+// entropyScorer rates a window by the Shannon entropy of its luminance
+// histogram plus its mean edge energy: busy, detailed regions beat flat sky
+// or walls.
+func entropyScorer(img *image.RGBA, horizontal bool, win int) func(start int) float64 {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	edges := prefixSums(lineSums(edgeMap(img), w, h, horizontal))
+
+	// Per-line luminance histograms let each window's histogram be summed.
+	const bins = 32
+	n := h
+	if horizontal {
+		n = w
+	}
+	hist := make([][bins]int, n)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			bin := int(luma(img.Pix[img.PixOffset(x, y):])) * bins / 256
+			if horizontal {
+				hist[x][bin]++
+			} else {
+				hist[y][bin]++
+			}
+		}
+	}
+
+	return func(start int) float64 {
+		var counts [bins]int
+		total := 0
+		for i := start; i < start+win; i++ {
+			for k, c := range hist[i] {
+				counts[k] += c
+				total += c
+			}
+		}
+		entropy := 0.0
+		for _, c := range counts {
+			if c > 0 {
+				p := float64(c) / float64(total)
+				entropy -= p * math.Log2(p)
+			}
+		}
+		// Entropy tops out at log2(32) = 5 bits; scale edges to a similar range.
+		return entropy + windowSum(edges, start, win)/float64(total)/64
+	}
+}
+
+// saliencyScorer rates a window by a pure-Go saliency map combining edge
+// energy, skin tones (faces, people) and local colour contrast against the
+// image mean, with a gentle bias toward the middle of the frame.
+func saliencyScorer(img *image.RGBA, horizontal bool, win int) func(start int) float64 {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	edges := edgeMap(img)
+
+	var meanR, meanG, meanB float64
+	for i := 0; i < len(img.Pix); i += 4 {
+		meanR += float64(img.Pix[i])
+		meanG += float64(img.Pix[i+1])
+		meanB += float64(img.Pix[i+2])
+	}
+	count := float64(w * h)
+	meanR, meanG, meanB = meanR/count, meanG/count, meanB/count
+
+	sal := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := img.Pix[img.PixOffset(x, y):]
+			r, g, b := float64(p[0]), float64(p[1]), float64(p[2])
+			contrast := math.Sqrt((r-meanR)*(r-meanR)+(g-meanG)*(g-meanG)+(b-meanB)*(b-meanB)) / 255
+			v := edges[y*w+x]/1020 + contrast
+			if isSkin(p[0], p[1], p[2]) {
+				v += 1.5
+			}
+			sal[y*w+x] = v
+		}
+	}
+	prefix := prefixSums(lineSums(sal, w, h, horizontal))
+
+	n := h
+	if horizontal {
+		n = w
+	}
+	return func(start int) float64 {
+		// Up to 10% off for windows pushed against an edge.
+		mid := float64(start) + float64(win)/2
+		bias := 1 - 0.1*math.Abs(mid-float64(n)/2)/(float64(n)/2)
+		return windowSum(prefix, start, win) * bias
+	}
+}
+
+// isSkin applies the classic RGB skin-tone rule (Kovac et al.), which is
+// cheap and good enough to steer crops toward people.
+func isSkin(r, g, b uint8) bool {
+	if r <= 95 || g <= 40 || b <= 20 || r <= g || r <= b {
+		return false
+	}
+	maxC := max(r, g, b)
+	minC := min(r, g, b)
+	return int(maxC)-int(minC) > 15 && int(r)-int(g) > 15
+}
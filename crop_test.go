@@ -0,0 +1,90 @@
+package yearcollage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// fillRect paints r in img with c.
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func TestCropRectModes(t *testing.T) {
+	gray := color.RGBA{120, 120, 120, 255}
+
+	// A wide, flat scene with a checkerboard on the far right.
+	detailRight := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	fillRect(detailRight, detailRight.Bounds(), gray)
+	for y := 10; y < 90; y++ {
+		for x := 220; x < 290; x++ {
+			if (x/5+y/5)%2 == 0 {
+				detailRight.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			} else {
+				detailRight.SetRGBA(x, y, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	}
+
+	// A wide scene with a skin-toned "face" on the left.
+	faceLeft := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	fillRect(faceLeft, faceLeft.Bounds(), color.RGBA{60, 90, 140, 255})
+	fillRect(faceLeft, image.Rect(20, 20, 80, 80), color.RGBA{224, 172, 140, 255})
+
+	// A tall portrait.
+	tall := image.NewRGBA(image.Rect(0, 0, 100, 300))
+	fillRect(tall, tall.Bounds(), gray)
+
+	cases := []struct {
+		name string
+		img  image.Image
+		mode CropMode
+		want func(r image.Rectangle) bool
+	}{
+		{"center matches legacy crop", detailRight, CropCenter, func(r image.Rectangle) bool { return r == image.Rect(100, 0, 200, 100) }},
+		{"entropy finds detail", detailRight, CropEntropy, func(r image.Rectangle) bool { return r.Min.X >= 180 && r.Dx() == 100 }},
+		{"saliency finds skin", faceLeft, CropSaliency, func(r image.Rectangle) bool { return r.Min.X <= 20 && r.Dx() == 100 }},
+		{"saliency also likes detail", detailRight, CropSaliency, func(r image.Rectangle) bool { return r.Min.X >= 180 }},
+		{"top keeps head of portraits", tall, CropTop, func(r image.Rectangle) bool { return r == image.Rect(0, 0, 100, 100) }},
+		{"top centers wide photos", detailRight, CropTop, func(r image.Rectangle) bool { return r == image.Rect(100, 0, 200, 100) }},
+		{"entropy on flat image stays centered", tall, CropEntropy, func(r image.Rectangle) bool { return r == image.Rect(0, 100, 100, 200) }},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := cropRect(tc.img, 1.0, tc.mode)
+			if !tc.want(got) {
+				t.Fatalf("cropRect(%s) = %v", tc.mode, got)
+			}
+			if !got.In(tc.img.Bounds()) {
+				t.Fatalf("cropRect(%s) = %v escapes bounds %v", tc.mode, got, tc.img.Bounds())
+			}
+		})
+	}
+}
+
+func TestCropToAspectKeepsOffsetBounds(t *testing.T) {
+	// Sub-images with non-zero origins must still crop inside their bounds.
+	base := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	sub := base.SubImage(image.Rect(50, 20, 350, 120))
+	for _, mode := range []CropMode{CropCenter, CropTop, CropEntropy, CropSaliency} {
+		got := cropToAspect(sub, 1.0, mode).Bounds()
+		if got.Dx() != 100 || got.Dy() != 100 || !got.In(sub.Bounds()) {
+			t.Fatalf("cropToAspect(%s) bounds = %v, want 100x100 inside %v", mode, got, sub.Bounds())
+		}
+	}
+}
+
+func TestIsSkin(t *testing.T) {
+	if !isSkin(224, 172, 140) {
+		t.Fatalf("typical skin tone rejected")
+	}
+	if isSkin(60, 90, 140) || isSkin(128, 128, 128) {
+		t.Fatalf("non-skin colour accepted")
+	}
+}
@@ -0,0 +1,64 @@
+package yearcollage
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/luceast/yearcollage/internal/jpegscale"
+)
+
+// decodeForTile decodes an image for a tile of tileWidth×tileHeight. JPEGs are
+// decoded at the smallest DCT scale (1/2, 1/4 or 1/8) that still covers the
+// tile after cropping, so a 24MP photo for a 400px tile never materializes at
+// full size. Other formats fall back to a full image.Decode.
+func decodeForTile(rs io.ReadSeeker, orientation, tileWidth, tileHeight int) (image.Image, error) {
+	cfg, format, err := image.DecodeConfig(rs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if format != "jpeg" {
+		img, _, err := image.Decode(rs)
+		return img, err
+	}
+
+	denom := jpegDenominator(cfg.Width, cfg.Height, orientation, tileWidth, tileHeight)
+	img, err := jpegscale.Decode(rs, denom)
+	if err != nil {
+		return nil, fmt.Errorf("jpeg 1/%d: %w", denom, err)
+	}
+	return img, nil
+}
+
+// jpegDenominator works out how far a width×height JPEG can be reduced while
+// the center crop for the tile aspect still has at least tile-sized pixels.
+func jpegDenominator(width, height, orientation, tileWidth, tileHeight int) int {
+	// Orientations 5-8 swap axes, so compare against the upright tile.
+	tw, th := tileWidth, tileHeight
+	if orientation >= 5 && orientation <= 8 {
+		tw, th = th, tw
+	}
+	if width <= 0 || height <= 0 || tw <= 0 || th <= 0 {
+		return 1
+	}
+
+	// The crop keeps the full extent of one axis; scale is the fraction of
+	// the source resolution the tile actually needs.
+	target := float64(tw) / float64(th)
+	cropW, cropH := float64(width), float64(height)
+	if cropW/cropH > target {
+		cropW = cropH * target
+	} else {
+		cropH = cropW / target
+	}
+	scale := math.Max(float64(tw)/cropW, float64(th)/cropH)
+
+	minW := int(math.Ceil(float64(width) * scale))
+	minH := int(math.Ceil(float64(height) * scale))
+	return jpegscale.Denominator(width, height, minW, minH)
+}
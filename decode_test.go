@@ -0,0 +1,127 @@
+package yearcollage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	"github.com/luceast/yearcollage/internal/heif"
+)
+
+func TestJPEGDenominator(t *testing.T) {
+	cases := []struct {
+		name                  string
+		w, h, orientation     int
+		tileWidth, tileHeight int
+		want                  int
+	}{
+		{"24MP to small square", 6000, 4000, 1, 400, 400, 8},
+		{"24MP to large square", 6000, 4000, 1, 1000, 1000, 4},
+		{"rotated portrait tile", 6000, 4000, 6, 300, 400, 8},
+		{"tile larger than source", 800, 600, 1, 1000, 1000, 1},
+		{"wide crop needs height", 4000, 1000, 1, 600, 300, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := jpegDenominator(tc.w, tc.h, tc.orientation, tc.tileWidth, tc.tileHeight)
+			if got != tc.want {
+				t.Fatalf("jpegDenominator(%d, %d, %d, %d, %d) = %d, want %d", tc.w, tc.h, tc.orientation, tc.tileWidth, tc.tileHeight, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeForTileReducesJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 800, 640)), nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	img, err := decodeForTile(bytes.NewReader(buf.Bytes()), 1, 100, 100)
+	if err != nil {
+		t.Fatalf("decodeForTile: %v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 200, 160); got != want {
+		t.Fatalf("decoded bounds = %v, want %v", got, want)
+	}
+}
+
+// heicHead is the ftyp and meta boxes of a HEIC file whose primary image is
+// 4032×3024 as far as its ispe property goes.
+const heicHead = "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic" +
+	"\x00\x00\x00\x30meta\x00\x00\x00\x00" +
+	"\x00\x00\x00\x24iprp\x00\x00\x00\x1cipco" +
+	"\x00\x00\x00\x14ispe\x00\x00\x00\x00\x00\x00\x0f\xc0\x00\x00\x0b\xd0"
+
+// heicWithPreview builds a HEIC file like heicHead whose item 2 is the JPEG
+// preview preview, located by iloc right after the meta box.
+func heicWithPreview(preview []byte) []byte {
+	box := func(typ string, parts ...[]byte) []byte {
+		data := bytes.Join(parts, nil)
+		return append(binary.BigEndian.AppendUint32(nil, uint32(8+len(data))), append([]byte(typ), data...)...)
+	}
+	ftyp := []byte(heicHead[:24])
+	meta := func(offset uint32) []byte {
+		iloc := binary.BigEndian.AppendUint32([]byte{0, 0, 0, 0, 0x44, 0, 0, 1, 0, 2, 0, 0, 0, 1}, offset)
+		return box("meta", []byte{0, 0, 0, 0},
+			box("iinf", []byte{0, 0, 0, 0, 0, 1}, box("infe", []byte{2, 0, 0, 0, 0, 2, 0, 0}, []byte("jpeg\x00"))),
+			box("iloc", binary.BigEndian.AppendUint32(iloc, uint32(len(preview)))),
+			[]byte(heicHead[36:]), // the iprp box with ispe
+		)
+	}
+	head := append(ftyp, meta(0)...)
+	head = append(ftyp, meta(uint32(len(head)+8))...)
+	return append(head, box("mdat", preview)...)
+}
+
+func TestDecodeForTileFormats(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	fillRect(src, src.Bounds(), color.RGBA{200, 40, 40, 255})
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	encoders := map[string]func(w io.Writer) error{
+		"tiff": func(w io.Writer) error { return tiff.Encode(w, src, nil) },
+		"bmp":  func(w io.Writer) error { return bmp.Encode(w, src) },
+		"gif":  func(w io.Writer) error { return gif.Encode(w, src, nil) },
+		// A HEIC container that only carries a JPEG preview we can use.
+		"heif": func(w io.Writer) error {
+			_, err := w.Write(heicWithPreview(jpg.Bytes()))
+			return err
+		},
+	}
+	for name, encode := range encoders {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encode(&buf); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			img, err := decodeForTile(bytes.NewReader(buf.Bytes()), 1, 20, 20)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got := img.Bounds().Size(); got != (image.Point{40, 30}) {
+				t.Fatalf("decoded size = %v, want 40x30", got)
+			}
+		})
+	}
+}
+
+func TestDecodeForTileReportsHEICWithoutPreview(t *testing.T) {
+	data := []byte(heicHead + "\x00\x00\x01\x00mdat")
+	_, err := decodeForTile(bytes.NewReader(data), 1, 20, 20)
+	if !errors.Is(err, heif.ErrNoPreview) {
+		t.Fatalf("error = %v, want %v", err, heif.ErrNoPreview)
+	}
+}
@@ -0,0 +1,221 @@
+package yearcollage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"slices"
+	"sync"
+)
+
+// DedupeMode decides how duplicate photos are detected before layout.
+type DedupeMode string
+
+// Dedupe modes.
+const (
+	DedupeOff        DedupeMode = "off"
+	DedupeExact      DedupeMode = "exact"      // byte-identical files
+	DedupePerceptual DedupeMode = "perceptual" // visually near-identical photos
+)
+
+// DefaultDedupeThreshold is the perceptual Hamming distance the command line
+// uses for Options.DedupeThreshold; it tolerates recompression and resizing
+// but keeps distinct burst frames apart more often than not.
+const DefaultDedupeThreshold = 6
+
+// Duplicate records a source dropped because it matched another one.
+type Duplicate struct {
+	Source string // the dropped source's Name
+	Kept   string // the Name of the source kept in its place
+}
+
+// fingerprint identifies a source's content for duplicate detection.
+type fingerprint struct {
+	sum    string // content hash, exact mode
+	hash   uint64 // dHash, perceptual mode
+	pixels int    // full-resolution pixel count, higher is better
+	size   int64  // encoded size in bytes, the tie-breaker
+	err    error
+}
+
+// dedupe drops duplicate sources according to the dedupe mode, keeping the
+// best-quality instance of each: the most pixels, then the largest file, then
+// the earliest in input order. The survivors keep their input order. Sources
+// that cannot be fingerprinted are kept so the error policy handles them.
+func (b *Builder) dedupe(ctx context.Context, srcs []Source) ([]Source, []Duplicate, error) {
+	mode := b.opts.dedupe()
+	prints, err := b.fingerprints(ctx, srcs, mode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order := make([]int, len(srcs))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		if c := cmp.Compare(prints[j].pixels, prints[i].pixels); c != 0 {
+			return c
+		}
+		return cmp.Compare(prints[j].size, prints[i].size)
+	})
+
+	threshold := b.opts.DedupeThreshold
+	keptBy := make([]int, len(srcs)) // index of the kept source, or -1
+	var kept []int
+	bySum := make(map[string]int)
+	for _, i := range order {
+		keptBy[i] = -1
+		p := prints[i]
+		if p.err != nil {
+			continue
+		}
+		switch mode {
+		case DedupeExact:
+			if k, ok := bySum[p.sum]; ok {
+				keptBy[i] = k
+				continue
+			}
+			bySum[p.sum] = i
+		case DedupePerceptual:
+			if k := slices.IndexFunc(kept, func(k int) bool {
+				return bits.OnesCount64(prints[k].hash^p.hash) <= threshold
+			}); k >= 0 {
+				keptBy[i] = kept[k]
+				continue
+			}
+			kept = append(kept, i)
+		}
+	}
+
+	var dups []Duplicate
+	out := make([]Source, 0, len(srcs))
+	for i, s := range srcs {
+		if k := keptBy[i]; k >= 0 {
+			dups = append(dups, Duplicate{Source: s.Name(), Kept: srcs[k].Name()})
+			continue
+		}
+		out = append(out, s)
+	}
+
+	b.logf("Dedupe (%s): dropped %d of %d images", mode, len(dups), len(srcs))
+	for _, d := range dups {
+		b.logf("  %s duplicates %s", d.Source, d.Kept)
+	}
+	return out, dups, nil
+}
+
+// fingerprints computes every source's fingerprint in parallel.
+func (b *Builder) fingerprints(ctx context.Context, srcs []Source, mode DedupeMode) ([]fingerprint, error) {
+	prints := make([]fingerprint, len(srcs))
+	b.stage(StageDedupe, len(srcs))
+
+	sem := make(chan struct{}, b.opts.jobs())
+	var wg sync.WaitGroup
+	for i, s := range srcs {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			prints[i] = fingerprintOf(s, mode)
+			b.item(StageDedupe, len(srcs), s.Name(), prints[i].err)
+		}()
+	}
+	wg.Wait()
+	return prints, ctx.Err()
+}
+
+// fingerprintOf hashes one source: its bytes in exact mode, or a 64-bit
+// difference hash of the upright photo in perceptual mode.
+func fingerprintOf(src Source, mode DedupeMode) fingerprint {
+	if mode == DedupeExact {
+		sum, err := sourceHash(src)
+		return fingerprint{sum: sum, err: err}
+	}
+
+	f, err := src.Open()
+	if err != nil {
+		return fingerprint{err: fmt.Errorf("open image %q: %w", src.Name(), err)}
+	}
+	defer f.Close()
+
+	var p fingerprint
+	if p.size, err = f.Seek(0, io.SeekEnd); err != nil {
+		return fingerprint{err: fmt.Errorf("size image %q: %w", src.Name(), err)}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fingerprint{err: fmt.Errorf("rewind image %q: %w", src.Name(), err)}
+	}
+	orientation := imageOrientation(f)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fingerprint{err: fmt.Errorf("rewind image %q: %w", src.Name(), err)}
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return fingerprint{err: fmt.Errorf("decode config %q: %w", src.Name(), err)}
+	}
+	p.pixels = cfg.Width * cfg.Height
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fingerprint{err: fmt.Errorf("rewind image %q: %w", src.Name(), err)}
+	}
+
+	// The hash only needs a few dozen pixels, so the smallest JPEG scale
+	// that covers a small square is plenty.
+	img, err := decodeForTile(f, orientation, 64, 64)
+	if err != nil {
+		return fingerprint{err: fmt.Errorf("decode image %q: %w", src.Name(), err)}
+	}
+	p.hash = dHash(normalizeOrientation(img, orientation))
+	return p
+}
+
+// dHash computes a difference hash: the photo is averaged down to a 9×8
+// grayscale grid and each bit records whether a cell is darker than its right
+// neighbour. Similar photos differ in few bits regardless of size or
+// compression.
+func dHash(img image.Image) uint64 {
+	const cols, rows = 9, 8
+	cells := grayCells(img, cols, rows)
+	var h uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			h <<= 1
+			if cells[y*cols+x] < cells[y*cols+x+1] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// grayCells averages the luminance of img over a cols×rows grid.
+func grayCells(img image.Image, cols, rows int) []float64 {
+	b := img.Bounds()
+	sums := make([]float64, cols*rows)
+	counts := make([]int, cols*rows)
+	if b.Empty() {
+		return sums
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := (y - b.Min.Y) * rows / b.Dy()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cx := (x - b.Min.X) * cols / b.Dx()
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sums[cy*cols+cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			counts[cy*cols+cx]++
+		}
+	}
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= float64(counts[i])
+		}
+	}
+	return sums
+}
@@ -0,0 +1,151 @@
+package yearcollage
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math/bits"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// patternPNG encodes a w×h picture whose content depends only on the
+// relative position, so different sizes show the same scene.
+func patternPNG(t *testing.T, w, h int, shade func(fx, fy float64) uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{shade(float64(x)/float64(w), float64(y)/float64(h))})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func diagonal(fx, fy float64) uint8 { return uint8(255 * (fx*fx + fy) / 2) }
+func rings(fx, fy float64) uint8 {
+	d := (fx-0.5)*(fx-0.5) + (fy-0.3)*(fy-0.3)
+	return uint8(int(d*40)%2) * 255
+}
+
+func TestDHashToleratesScaling(t *testing.T) {
+	hash := func(data []byte) uint64 {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dHash(img)
+	}
+	small, large := hash(patternPNG(t, 60, 40, diagonal)), hash(patternPNG(t, 300, 200, diagonal))
+	other := hash(patternPNG(t, 300, 200, rings))
+	if d := bits.OnesCount64(small ^ large); d > 2 {
+		t.Fatalf("rescaled copy differs in %d bits", d)
+	}
+	if d := bits.OnesCount64(large ^ other); d <= DefaultDedupeThreshold {
+		t.Fatalf("different scenes differ in only %d bits", d)
+	}
+}
+
+func TestRenderDedupes(t *testing.T) {
+	small := patternPNG(t, 60, 40, diagonal)
+	large := patternPNG(t, 300, 200, diagonal)
+	other := patternPNG(t, 300, 200, rings)
+	sources := []Source{
+		Bytes("a-small.png", small, time.Time{}),
+		Bytes("b-large.png", large, time.Time{}),
+		Bytes("c-other.png", other, time.Time{}),
+		Bytes("d-copy.png", small, time.Time{}),
+	}
+
+	cases := []struct {
+		mode DedupeMode
+		want []Duplicate
+	}{
+		{DedupeOff, nil},
+		{DedupeExact, []Duplicate{{Source: "d-copy.png", Kept: "a-small.png"}}},
+		// The larger rendition wins over both small copies.
+		{DedupePerceptual, []Duplicate{
+			{Source: "a-small.png", Kept: "b-large.png"},
+			{Source: "d-copy.png", Kept: "b-large.png"},
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			b, err := New(Options{TileWidth: 10, Columns: 1, Sort: SortName, Dedupe: tc.mode, DedupeThreshold: DefaultDedupeThreshold})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			res, err := b.Render(context.Background(), sources)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if !reflect.DeepEqual(res.Duplicates, tc.want) {
+				t.Fatalf("duplicates = %+v, want %+v", res.Duplicates, tc.want)
+			}
+			if got, want := res.Image.Bounds().Dy(), 10*(len(sources)-len(tc.want)); got != want {
+				t.Fatalf("canvas height = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestDedupeThresholdZeroNeedsIdenticalHashes(t *testing.T) {
+	// Two 9×8-block gradients whose dHashes differ only in the first bit:
+	// the second one's leftmost block is brighter than its neighbour.
+	gradient := func(first uint8) []byte {
+		img := image.NewGray(image.Rect(0, 0, 36, 32))
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 36; x++ {
+				v := uint8(20 + 20*(x/4))
+				if x < 4 && y < 4 {
+					v = first
+				}
+				img.SetGray(x, y, color.Gray{v})
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatalf("encode png: %v", err)
+		}
+		return buf.Bytes()
+	}
+	a, b := gradient(20), gradient(50)
+	ha, hb := hashPNG(t, a), hashPNG(t, b)
+	if d := bits.OnesCount64(ha ^ hb); d != 1 {
+		t.Fatalf("fixture hashes differ in %d bits, want 1", d)
+	}
+	sources := []Source{Bytes("a.png", a, time.Time{}), Bytes("b.png", b, time.Time{})}
+
+	for _, tc := range []struct {
+		threshold int
+		dups      int
+	}{{0, 0}, {1, 1}} {
+		bld, err := New(Options{TileWidth: 10, Columns: 1, Dedupe: DedupePerceptual, DedupeThreshold: tc.threshold})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		res, err := bld.Render(context.Background(), sources)
+		if err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+		if len(res.Duplicates) != tc.dups {
+			t.Fatalf("threshold %d: duplicates = %+v, want %d", tc.threshold, res.Duplicates, tc.dups)
+		}
+	}
+}
+
+func hashPNG(t *testing.T, data []byte) uint64 {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dHash(img)
+}
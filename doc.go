@@ -0,0 +1,26 @@
+// Package yearcollage turns a set of photos into a single collage image.
+//
+// Sources are laid out as a uniform grid, justified rows or a calendar,
+// cropped or fitted into their cells and drawn onto one canvas:
+//
+//	sources, err := yearcollage.Dir(ctx, "photos/2025", yearcollage.Filter{})
+//	if err != nil {
+//		return err
+//	}
+//	b, err := yearcollage.New(yearcollage.Options{
+//		TileWidth:  400,
+//		TileAspect: 3.0 / 2.0,
+//		Columns:    20,
+//		Sort:       yearcollage.SortEXIF,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	res, err := b.Encode(ctx, w, yearcollage.FormatJPEG, sources)
+//
+// Rendering runs on a bounded pool of workers, honours ctx for cancellation
+// and reports progress through Options.Progress. With Options.MaxMemory set,
+// Encode renders canvases too large for it in strips and streams them to PNG
+// or TIFF. Result.Tiles records where every photo was drawn and which part
+// of it shows. The yearcollage command is a thin wrapper around this package.
+package yearcollage
@@ -0,0 +1,60 @@
+package yearcollage
+
+import (
+	"image"
+	"image/color"
+)
+
+// ErrorPolicy decides what happens to images that cannot be turned into tiles.
+type ErrorPolicy string
+
+// Error policies.
+const (
+	OnErrorFail        ErrorPolicy = "fail"        // abort the render
+	OnErrorSkip        ErrorPolicy = "skip"        // drop the image and re-flow the layout
+	OnErrorPlaceholder ErrorPolicy = "placeholder" // keep the slot with a neutral tile
+)
+
+var (
+	placeholderBackground = color.RGBA{0x9e, 0x9e, 0x9e, 0xff}
+	placeholderGlyph      = color.RGBA{0x5c, 0x5c, 0x5c, 0xff}
+)
+
+// placeholderTile draws a neutral grey tile with a centered "×" so failed
+// photos are visible in the collage without drawing too much attention.
+func placeholderTile(w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(dst.Pix); i += 4 {
+		dst.Pix[i+0] = placeholderBackground.R
+		dst.Pix[i+1] = placeholderBackground.G
+		dst.Pix[i+2] = placeholderBackground.B
+		dst.Pix[i+3] = placeholderBackground.A
+	}
+
+	// The cross spans the middle 40% of the shorter edge.
+	size := min(w, h) * 2 / 5
+	thickness := max(1, min(w, h)/24)
+	x0, y0 := (w-size)/2, (h-size)/2
+	for i := 0; i < size; i++ {
+		for t := -thickness / 2; t <= thickness/2; t++ {
+			dst.SetRGBA(x0+i+t, y0+i, placeholderGlyph)
+			dst.SetRGBA(x0+size-1-i+t, y0+i, placeholderGlyph)
+		}
+	}
+	return dst
+}
+
+// withoutFailed drops failed sources while keeping the original order.
+func withoutFailed(srcs []Source, failures []Failure) []Source {
+	drop := make(map[string]bool, len(failures))
+	for _, f := range failures {
+		drop[f.Source] = true
+	}
+	kept := make([]Source, 0, len(srcs))
+	for _, s := range srcs {
+		if !drop[s.Name()] {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
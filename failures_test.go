@@ -0,0 +1,13 @@
+package yearcollage
+
+import "testing"
+
+func TestPlaceholderTileHasGlyph(t *testing.T) {
+	tile := placeholderTile(48, 48)
+	if tile.RGBAAt(1, 1) != placeholderBackground {
+		t.Fatalf("corner = %v, want background", tile.RGBAAt(1, 1))
+	}
+	if tile.RGBAAt(24, 24) != placeholderGlyph {
+		t.Fatalf("center = %v, want glyph", tile.RGBAAt(24, 24))
+	}
+}
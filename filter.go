@@ -0,0 +1,44 @@
+package yearcollage
+
+import "time"
+
+// dateFiltered reports whether From or To restricts the capture dates.
+func (o Options) dateFiltered() bool {
+	return !o.From.IsZero() || !o.To.IsZero()
+}
+
+// filterByDate keeps the sources captured within [From, To), using the
+// capture times keyed by name, and logs how many were dropped and why.
+func (b *Builder) filterByDate(srcs []Source, times map[string]time.Time) []Source {
+	var early, late, unknown int
+	kept := make([]Source, 0, len(srcs))
+	for _, s := range srcs {
+		t := times[s.Name()]
+		switch {
+		case t.IsZero():
+			unknown++
+		case !b.opts.From.IsZero() && t.Before(b.opts.From):
+			early++
+		case !b.opts.To.IsZero() && !t.Before(b.opts.To):
+			late++
+		default:
+			kept = append(kept, s)
+		}
+	}
+
+	b.logf("Date filter %s: kept %d of %d images (%d before, %d after, %d without a capture time)",
+		b.opts.dateRange(), len(kept), len(srcs), early, late, unknown)
+	return kept
+}
+
+// dateRange describes the From/To window for logs and errors.
+func (o Options) dateRange() string {
+	from, to := "…", "…"
+	if !o.From.IsZero() {
+		from = o.From.Format(time.DateTime)
+	}
+	if !o.To.IsZero() {
+		to = o.To.Format(time.DateTime)
+	}
+	return "[" + from + ", " + to + ")"
+}
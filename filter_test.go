@@ -0,0 +1,59 @@
+package yearcollage
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderFiltersByCaptureDate(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 12, 0, 0, 0, time.Local) }
+	// The PNGs carry no EXIF, so the modification time is the capture time.
+	dated := []struct {
+		name string
+		at   time.Time
+	}{
+		{"a.png", day(2024, time.December, 31)},
+		{"b.png", day(2025, time.March, 1)},
+		{"c.png", day(2025, time.July, 14)},
+		{"d.png", day(2026, time.January, 1)},
+		{"e.png", time.Time{}},
+	}
+	var sources []Source
+	for _, d := range dated {
+		sources = append(sources, Bytes(d.name, pngBytes(t, 10, 10, color.RGBA{A: 255}), d.at))
+	}
+
+	var logs []string
+	b, err := New(Options{
+		TileWidth: 10, Columns: 10, Sort: SortName,
+		From: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.Local),
+		To:   time.Date(2026, time.January, 1, 0, 0, 0, 0, time.Local),
+		Logf: func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) },
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := b.Render(context.Background(), sources)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	// Two photos left in a 10-column grid.
+	if got := res.Image.Bounds().Dx(); got != 100 {
+		t.Fatalf("canvas width = %d, want 100", got)
+	}
+	want := "kept 2 of 5 images (1 before, 1 after, 1 without a capture time)"
+	if !strings.Contains(strings.Join(logs, "\n"), want) {
+		t.Fatalf("logs %q do not explain the exclusions (%s)", logs, want)
+	}
+
+	// A range that matches nothing is an error rather than an empty collage.
+	b.opts.From = time.Date(2030, time.January, 1, 0, 0, 0, 0, time.Local)
+	b.opts.To = time.Time{}
+	if _, err := b.Render(context.Background(), sources); err == nil || !strings.Contains(err.Error(), "no images captured") {
+		t.Fatalf("Render error = %v, want no images captured", err)
+	}
+}
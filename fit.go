@@ -0,0 +1,114 @@
+package yearcollage
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// FitMode decides how a photo fills its tile.
+type FitMode string
+
+// Fit modes.
+const (
+	FitCrop     FitMode = "crop"      // fill the tile, trimming the photo to its aspect
+	FitContain  FitMode = "contain"   // show the whole photo on Background
+	FitBlurFill FitMode = "blur-fill" // show the whole photo over a blurred copy of itself
+)
+
+// blurDivisor sets how far blur-fill backdrops are shrunk before blurring;
+// bigger values give a softer backdrop for less work.
+const blurDivisor = 16
+
+// fitTile scales an upright photo into a spec.width×spec.height tile using
+// the spec's fit mode and resampling filter.
+func fitTile(img image.Image, spec tileSpec) *image.RGBA {
+	return fitWindow(img, tileWindow(img, spec), spec)
+}
+
+// tileWindow returns the part of an upright photo that the tile shows: the
+// crop window, or the whole photo when it is contained.
+func tileWindow(img image.Image, spec tileSpec) image.Rectangle {
+	if spec.fit == FitContain || spec.fit == FitBlurFill {
+		return img.Bounds()
+	}
+	return cropWindow(img, spec.ratio, spec.crop)
+}
+
+// fitWindow scales the window of img picked by tileWindow into the tile.
+func fitWindow(img image.Image, window image.Rectangle, spec tileSpec) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, spec.width, spec.height))
+	b := img.Bounds()
+	if b.Empty() {
+		return dst
+	}
+
+	switch spec.fit {
+	case FitContain, FitBlurFill:
+		if spec.fit == FitContain {
+			draw.Draw(dst, dst.Bounds(), image.NewUniform(spec.background), image.Point{}, draw.Src)
+		} else {
+			blurredBackdrop(dst, img)
+		}
+		r := containRect(b.Dx(), b.Dy(), spec.width, spec.height)
+		spec.scale(dst, r, img, b, draw.Over)
+	default:
+		// Trim the photo so it fits the target aspect without stretching.
+		cropped := subImage(img, window)
+		spec.scale(dst, dst.Bounds(), cropped, cropped.Bounds(), draw.Over)
+	}
+	return dst
+}
+
+// containRect returns the largest srcW×srcH-shaped rectangle that fits inside
+// a w×h tile, centered.
+func containRect(srcW, srcH, w, h int) image.Rectangle {
+	scale := math.Min(float64(w)/float64(srcW), float64(h)/float64(srcH))
+	dw := clampInt(int(math.Round(float64(srcW)*scale)), 1, w)
+	dh := clampInt(int(math.Round(float64(srcH)*scale)), 1, h)
+	x, y := (w-dw)/2, (h-dh)/2
+	return image.Rect(x, y, x+dw, y+dh)
+}
+
+// blurredBackdrop fills dst with a center-cropped, enlarged and heavily
+// blurred copy of img. The blur is done on a copy shrunk by blurDivisor and
+// smoothed again when scaling back up, so it stays cheap on big tiles.
+func blurredBackdrop(dst *image.RGBA, img image.Image) {
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	cropped := cropToAspect(img, float64(w)/float64(h), CropCenter)
+
+	small := image.NewRGBA(image.Rect(0, 0, max(1, w/blurDivisor), max(1, h/blurDivisor)))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), cropped, cropped.Bounds(), draw.Src, nil)
+	boxBlur(small, 1)
+	boxBlur(small, 1)
+	draw.BiLinear.Scale(dst, dst.Bounds(), small, small.Bounds(), draw.Src, nil)
+}
+
+// boxBlur applies a separable box blur of the given radius in place, clamping
+// at the edges.
+func boxBlur(img *image.RGBA, radius int) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	tmp := make([]uint8, len(img.Pix))
+	pass := func(src, dst []uint8, dx, dy int) {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				var sum [4]int
+				for k := -radius; k <= radius; k++ {
+					sx := clampInt(x+k*dx, 0, w-1)
+					sy := clampInt(y+k*dy, 0, h-1)
+					p := src[sy*img.Stride+sx*4:]
+					for c := 0; c < 4; c++ {
+						sum[c] += int(p[c])
+					}
+				}
+				o := y*img.Stride + x*4
+				for c := 0; c < 4; c++ {
+					dst[o+c] = uint8(sum[c] / (2*radius + 1))
+				}
+			}
+		}
+	}
+	pass(img.Pix, tmp, 1, 0)
+	pass(tmp, img.Pix, 0, 1)
+}
@@ -0,0 +1,57 @@
+package yearcollage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFitTileModes(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	bg := color.RGBA{0, 0, 255, 255}
+
+	// A 2:1 photo in a square tile.
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	fillRect(src, src.Bounds(), red)
+
+	spec := tileSpec{width: 100, height: 100, ratio: 1, crop: CropCenter, background: bg}
+
+	spec.fit = FitCrop
+	if got := fitTile(src, spec).RGBAAt(50, 5); got != red {
+		t.Fatalf("crop: top edge = %v, want photo %v", got, red)
+	}
+
+	spec.fit = FitContain
+	contained := fitTile(src, spec)
+	if got := contained.RGBAAt(50, 5); got != bg {
+		t.Fatalf("contain: top edge = %v, want background %v", got, bg)
+	}
+	if got := contained.RGBAAt(50, 50); got != red {
+		t.Fatalf("contain: center = %v, want photo %v", got, red)
+	}
+
+	spec.fit = FitBlurFill
+	filled := fitTile(src, spec)
+	if got := filled.RGBAAt(50, 5); got.R < 200 || got.B > 50 {
+		t.Fatalf("blur-fill: top edge = %v, want a blurred copy of the photo", got)
+	}
+	if got := filled.RGBAAt(50, 50); got != red {
+		t.Fatalf("blur-fill: center = %v, want photo %v", got, red)
+	}
+}
+
+func TestContainRect(t *testing.T) {
+	cases := []struct {
+		srcW, srcH, w, h int
+		want             image.Rectangle
+	}{
+		{200, 100, 100, 100, image.Rect(0, 25, 100, 75)},
+		{100, 300, 90, 90, image.Rect(30, 0, 60, 90)},
+		{40, 30, 80, 60, image.Rect(0, 0, 80, 60)},
+	}
+	for _, tc := range cases {
+		if got := containRect(tc.srcW, tc.srcH, tc.w, tc.h); got != tc.want {
+			t.Fatalf("containRect(%d×%d in %d×%d) = %v, want %v", tc.srcW, tc.srcH, tc.w, tc.h, got, tc.want)
+		}
+	}
+}
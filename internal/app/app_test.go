@@ -1,14 +1,22 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/luceast/yearcollage"
 )
 
 func TestConfigValidate(t *testing.T) {
@@ -21,27 +29,122 @@ func TestConfigValidate(t *testing.T) {
 	}{
 		{
 			name:    "columns provided",
-			cfg:     Config{InputDir: "in", TileWidth: 100, Columns: 2},
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 2},
 			wantErr: false,
 		},
 		{
 			name:    "collage aspect provided",
-			cfg:     Config{InputDir: "in", TileWidth: 100, CollageAspect: "16:9"},
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, CollageAspect: "16:9"},
 			wantErr: false,
 		},
 		{
 			name:    "missing columns and collage aspect",
-			cfg:     Config{InputDir: "in", TileWidth: 100},
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100},
 			wantErr: true,
 		},
 		{
 			name:    "negative columns",
-			cfg:     Config{InputDir: "in", TileWidth: 100, Columns: -1},
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative jobs",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, Jobs: -1},
+			wantErr: true,
+		},
+		{
+			name:    "justified with collage aspect",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 2, CollageAspect: "16:9", Layout: "justified"},
+			wantErr: true,
+		},
+		{
+			name:    "max crop out of range",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 2, Layout: "justified", MaxCrop: 0.9},
+			wantErr: true,
+		},
+		{
+			name:    "bad background colour",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, Fit: "contain", Background: "#12345"},
+			wantErr: true,
+		},
+		{
+			name:    "date range",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, From: "2025-06-01", To: "2025-08-31T23:00:00"},
+			wantErr: false,
+		},
+		{
+			name:    "year with from",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, Year: 2025, From: "2025-06-01"},
+			wantErr: true,
+		},
+		{
+			name:    "to before from",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, From: "2025-06-01", To: "2025-05-31"},
+			wantErr: true,
+		},
+		{
+			name:    "malformed date",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, From: "June 2025"},
 			wantErr: true,
 		},
 		{
 			name:    "invalid sort",
-			cfg:     Config{InputDir: "in", TileWidth: 100, Columns: 1, SortMode: "weird"},
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, SortMode: "weird"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid dedupe mode",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, Dedupe: "fuzzy"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid sample strategy",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, MaxImages: 10, Sample: "weekly"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid resample filter",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, Resample: "bicubic"},
+			wantErr: true,
+		},
+		{
+			name:    "sharpen without radius",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, Sharpen: "0.8,0"},
+			wantErr: true,
+		},
+		{
+			name:    "max memory",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, MaxMemory: "512MiB"},
+			wantErr: false,
+		},
+		{
+			name:    "malformed max memory",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, MaxMemory: "lots"},
+			wantErr: true,
+		},
+		{
+			name:    "negative max input pixels",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, MaxInputPixels: -1},
+			wantErr: true,
+		},
+		{
+			name:    "json without dry run",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, JSON: true},
+			wantErr: true,
+		},
+		{
+			name:    "manifest with dry run",
+			cfg:     Config{InputDirs: []string{"in"}, TileWidth: 100, Columns: 1, DryRun: true, Manifest: "tiles.json"},
+			wantErr: true,
+		},
+		{
+			name:    "files-from without input dir",
+			cfg:     Config{FilesFrom: "-", TileWidth: 100, Columns: 1},
+			wantErr: false,
+		},
+		{
+			name:    "no input at all",
+			cfg:     Config{TileWidth: 100, Columns: 1},
 			wantErr: true,
 		},
 	}
@@ -59,26 +162,48 @@ func TestConfigValidate(t *testing.T) {
 	}
 }
 
-func TestPickColumnsForCollage(t *testing.T) {
-	// The heuristic aims for balanced grids; spot-check a few shapes.
+func TestParseColor(t *testing.T) {
 	cases := []struct {
-		name   string
-		n      int
-		target float64
-		want   int
+		in      string
+		want    color.RGBA
+		wantErr bool
 	}{
-		{"ten images 16:9", 10, 16.0 / 9.0, 5},
-		{"ten images 1:1", 10, 1.0, 4},
-		{"single image", 1, 1.0, 1},
+		{"#1e90ff", color.RGBA{0x1e, 0x90, 0xff, 0xff}, false},
+		{"FFF", color.RGBA{0xff, 0xff, 0xff, 0xff}, false},
+		{"#abc", color.RGBA{0xaa, 0xbb, 0xcc, 0xff}, false},
+		{"#12345", color.RGBA{}, true},
+		{"#gg0000", color.RGBA{}, true},
 	}
+	for _, tc := range cases {
+		got, err := parseColor(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("parseColor(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+		}
+		if !tc.wantErr && got != tc.want {
+			t.Fatalf("parseColor(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
 
+func TestParseSharpen(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    yearcollage.Sharpen
+		wantErr bool
+	}{
+		{"0.8,1", yearcollage.Sharpen{Amount: 0.8, Radius: 1}, false},
+		{"2, 0.5", yearcollage.Sharpen{Amount: 2, Radius: 0.5}, false},
+		{"0.8", yearcollage.Sharpen{}, true},
+		{"strong,1", yearcollage.Sharpen{}, true},
+	}
 	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			got := pickColumnsForCollage(tc.n, tc.target)
-			if got != tc.want {
-				t.Fatalf("pickColumnsForCollage(%d, %.3f) = %d, want %d", tc.n, tc.target, got, tc.want)
-			}
-		})
+		got, err := parseSharpen(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("parseSharpen(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+		}
+		if !tc.wantErr && got != tc.want {
+			t.Fatalf("parseSharpen(%q) = %v, want %v", tc.in, got, tc.want)
+		}
 	}
 }
 
@@ -95,7 +220,7 @@ func TestRunWithCollageAspectOverridesTileAspect(t *testing.T) {
 
 	outPath := filepath.Join(tmp, "out.png")
 	cfg := Config{
-		InputDir:      tmp,
+		InputDirs:     []string{tmp},
 		Output:        outPath,
 		TileAspect:    "3:2", // should be ignored when collage-aspect is set
 		TileWidth:     100,
@@ -103,7 +228,7 @@ func TestRunWithCollageAspectOverridesTileAspect(t *testing.T) {
 		CollageAspect: "1:1",
 	}
 
-	if err := Run(cfg); err != nil {
+	if err := Run(context.Background(), cfg); err != nil {
 		t.Fatalf("Run returned error: %v", err)
 	}
 
@@ -125,6 +250,261 @@ func TestRunWithCollageAspectOverridesTileAspect(t *testing.T) {
 	}
 }
 
+func TestRunParallelMatchesSerial(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in")
+
+	for i := 0; i < 9; i++ {
+		path := filepath.Join(in, fmt.Sprintf("img-%02d.png", i))
+		if err := writeSolidPNG(path, 30+7*i, 50-3*i, color.RGBA{uint8(25 * i), uint8(200 - 20*i), 90, 255}); err != nil {
+			t.Fatalf("write image %s: %v", path, err)
+		}
+	}
+
+	// Render the same input serially and with several workers; every pixel
+	// must match because tile slots are fixed by index.
+	render := func(jobs int) *image.RGBA {
+		outPath := filepath.Join(tmp, fmt.Sprintf("out-%d.png", jobs))
+		cfg := Config{
+			InputDirs:  []string{in},
+			Output:     outPath,
+			TileAspect: "1:1",
+			TileWidth:  20,
+			Columns:    4,
+			SortMode:   "name",
+			Jobs:       jobs,
+		}
+		if err := Run(context.Background(), cfg); err != nil {
+			t.Fatalf("Run(jobs=%d) returned error: %v", jobs, err)
+		}
+		return readRGBA(t, outPath)
+	}
+
+	serial := render(1)
+	parallel := render(4)
+	if serial.Bounds() != parallel.Bounds() {
+		t.Fatalf("bounds differ: serial %v, parallel %v", serial.Bounds(), parallel.Bounds())
+	}
+	if !bytes.Equal(serial.Pix, parallel.Pix) {
+		t.Fatalf("parallel render differs from serial render")
+	}
+}
+
+func TestRunStreamsUnderMaxMemory(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in")
+	for i := 0; i < 9; i++ {
+		path := filepath.Join(in, fmt.Sprintf("img-%02d.png", i))
+		if err := writeSolidPNG(path, 30, 30, color.RGBA{uint8(25 * i), 90, uint8(200 - 20*i), 255}); err != nil {
+			t.Fatalf("write image %s: %v", path, err)
+		}
+	}
+	cfg := Config{InputDirs: []string{in}, TileAspect: "1:1", TileWidth: 20, Columns: 4, SortMode: "name", Progress: progressOff}
+
+	cfg.Output = filepath.Join(tmp, "whole.png")
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := readRGBA(t, cfg.Output)
+
+	// The 80×60 canvas needs 18.8 KiB; 8 KiB holds one row of tiles.
+	cfg.MaxMemory = "8KiB"
+	for _, name := range []string{"strips.png", "strips.tif"} {
+		cfg.Output = filepath.Join(tmp, name)
+		if err := Run(context.Background(), cfg); err != nil {
+			t.Fatalf("Run %s: %v", name, err)
+		}
+		if got := readRGBA(t, cfg.Output); !bytes.Equal(got.Pix, want.Pix) {
+			t.Fatalf("%s differs from the in-memory render", name)
+		}
+	}
+
+	cfg.Output = filepath.Join(tmp, "strips.jpg")
+	if err := Run(context.Background(), cfg); err == nil {
+		t.Fatal("Run streamed a JPEG")
+	}
+	if _, err := os.Stat(cfg.Output); !os.IsNotExist(err) {
+		t.Fatalf("refused JPEG left output behind: %v", err)
+	}
+}
+
+func TestRunRefusesOversizedCanvas(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in")
+	for i := 0; i < 4; i++ {
+		if err := writeSolidPNG(filepath.Join(in, fmt.Sprintf("img-%02d.png", i)), 20, 20, color.RGBA{0, 0, 255, 255}); err != nil {
+			t.Fatalf("write image: %v", err)
+		}
+	}
+	// A typo: -w 20000 instead of 200.
+	cfg := Config{InputDirs: []string{in}, Output: filepath.Join(tmp, "out.png"), TileAspect: "1:1", TileWidth: 20000, Columns: 2, MaxOutputPixels: 1_000_000_000, Progress: progressOff}
+	err := Run(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "40000×40000 canvas is 1600.0 MP") || !strings.Contains(err.Error(), "tile-width 20000") {
+		t.Fatalf("Run error = %v, want the canvas size and tile-width", err)
+	}
+	if _, err := os.Stat(cfg.Output); !os.IsNotExist(err) {
+		t.Fatalf("refused run left output behind: %v", err)
+	}
+}
+
+func TestRunReusesCachedTiles(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in")
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(in, fmt.Sprintf("img-%02d.png", i))
+		if err := writeSolidPNG(path, 40, 20+10*i, color.RGBA{uint8(60 * i), 80, 120, 255}); err != nil {
+			t.Fatalf("write image %s: %v", path, err)
+		}
+	}
+
+	cfg := Config{
+		InputDirs:  []string{in},
+		TileAspect: "1:1",
+		TileWidth:  16,
+		Columns:    2,
+		SortMode:   "name",
+		CacheDir:   filepath.Join(tmp, "cache"),
+	}
+
+	cfg.Output = filepath.Join(tmp, "first.png")
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// The first run must have populated the cache; the second one reads it.
+	entries, err := os.ReadDir(filepath.Join(tmp, "cache"))
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("cache dir is empty after first run (err=%v)", err)
+	}
+
+	cfg.Output = filepath.Join(tmp, "second.png")
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if !bytes.Equal(readRGBA(t, filepath.Join(tmp, "first.png")).Pix, readRGBA(t, cfg.Output).Pix) {
+		t.Fatalf("cached render differs from fresh render")
+	}
+
+	// Changing a photo must invalidate only that tile.
+	if err := writeSolidPNG(filepath.Join(in, "img-00.png"), 40, 20, color.RGBA{255, 255, 0, 255}); err != nil {
+		t.Fatalf("rewrite image: %v", err)
+	}
+	cfg.Output = filepath.Join(tmp, "third.png")
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("third Run: %v", err)
+	}
+	got := readRGBA(t, cfg.Output)
+	if r, g, b, _ := got.At(8, 8).RGBA(); r>>8 != 255 || g>>8 != 255 || b>>8 != 0 {
+		t.Fatalf("changed photo served stale tile: got (%d,%d,%d)", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestRunJustifiedLayout(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in")
+	sizes := [][2]int{{60, 40}, {30, 60}, {50, 50}, {90, 30}, {40, 30}}
+	for i, sz := range sizes {
+		path := filepath.Join(in, fmt.Sprintf("img-%02d.png", i))
+		if err := writeSolidPNG(path, sz[0], sz[1], color.RGBA{uint8(40 * i), 90, 160, 255}); err != nil {
+			t.Fatalf("write image %s: %v", path, err)
+		}
+	}
+
+	cfg := Config{
+		InputDirs:  []string{in},
+		Output:     filepath.Join(tmp, "out.png"),
+		TileAspect: "1:1",
+		TileWidth:  50,
+		Columns:    4,
+		SortMode:   "name",
+		Layout:     "justified",
+		RowHeight:  60,
+	}
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	out := readRGBA(t, cfg.Output)
+	if out.Bounds().Dx() != 200 {
+		t.Fatalf("output width = %d, want 200", out.Bounds().Dx())
+	}
+	// The first photo (3:2) starts the first row; its top-left pixel is set.
+	if _, _, _, a := out.At(0, 0).RGBA(); a == 0 {
+		t.Fatalf("top-left pixel is empty")
+	}
+}
+
+func TestRunCancelledLeavesNoOutput(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in")
+	for i := 0; i < 3; i++ {
+		if err := writeSolidPNG(filepath.Join(in, fmt.Sprintf("img-%02d.png", i)), 20, 20, color.RGBA{0, 0, 255, 255}); err != nil {
+			t.Fatalf("write image: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg := Config{InputDirs: []string{in}, Output: filepath.Join(tmp, "out.png"), TileAspect: "1:1", TileWidth: 10, Columns: 2, Progress: progressOff}
+	if err := Run(ctx, cfg); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run error = %v, want context.Canceled", err)
+	}
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("cancelled run left files behind: %v", entries)
+	}
+}
+
+func TestWriteFileAtomicReplaces(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "out.png")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("write old output: %v", err)
+	}
+	errEncode := errors.New("encode failed")
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, _ = w.Write([]byte("partial"))
+		return errEncode
+	}); !errors.Is(err, errEncode) {
+		t.Fatalf("writeFileAtomic error = %v, want the write error", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Fatalf("failed write replaced the output with %q", data)
+	}
+
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		return yearcollage.FormatPNG.Encode(w, image.NewRGBA(image.Rect(0, 0, 4, 3)))
+	}); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	if got := readRGBA(t, path).Bounds(); got != image.Rect(0, 0, 4, 3) {
+		t.Fatalf("saved bounds = %v, want 4x3", got)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+// readRGBA decodes an image file into an RGBA buffer for pixel comparisons.
+func readRGBA(t *testing.T, path string) *image.RGBA {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
 // writeSolidPNG creates a solid color PNG for quick test fixtures.
 func writeSolidPNG(path string, w, h int, c color.Color) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
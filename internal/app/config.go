@@ -1,36 +1,231 @@
 package app
 
-import "fmt"
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luceast/yearcollage"
+	"github.com/luceast/yearcollage/internal/aspect"
+	"github.com/luceast/yearcollage/internal/bytesize"
+)
 
 // Config holds all CLI parameters.
 type Config struct {
-	InputDir      string
-	Output        string
-	TileAspect    string
-	TileWidth     int
-	Columns       int
-	CollageAspect string
-	SortMode      string
+	InputDirs       []string
+	FilesFrom       string // path list file, "-" for stdin
+	Null            bool   // FilesFrom entries are NUL-terminated
+	Include         []string
+	Exclude         []string
+	Output          string
+	TileAspect      string
+	TileWidth       int
+	Columns         int
+	CollageAspect   string
+	SortMode        string
+	Jobs            int
+	CacheDir        string
+	OnError         string
+	ErrorReport     string
+	Manifest        string // JSON file describing every tile
+	Layout          string
+	RowHeight       int
+	MaxCrop         float64
+	Calendar        string
+	Crop            string
+	Fit             string
+	Background      string
+	EmbeddedThumbs  bool
+	Resample        string
+	LinearLight     bool
+	Sharpen         string // "amount,radius"
+	MaxMemory       string // byte size, e.g. "4GiB"; empty or "0" means unlimited
+	MaxInputPixels  int64
+	MaxOutputPixels int64
+	DryRun          bool // print the plan instead of rendering
+	JSON            bool // print the plan as JSON
+	Progress        string
+	From            string
+	To              string
+	Year            int
+	Dedupe          string
+	DedupeThreshold int
+	MinSharpness    float64
+	Burst           time.Duration
+	MaxImages       int
+	Sample          string
+	Seed            int64
 }
 
 // Validate ensures required flags are provided and values make sense for the renderer.
 func (c Config) Validate() error {
-	if c.InputDir == "" {
-		return fmt.Errorf("missing required flag: -input")
+	if len(c.InputDirs) == 0 && c.FilesFrom == "" {
+		return fmt.Errorf("missing required flag: -input or -files-from")
 	}
-	if c.TileWidth <= 0 {
-		return fmt.Errorf("tile-width must be greater than zero")
+	switch c.Progress {
+	case "", progressAuto, progressBar, progressPlain, progressOff:
+	default:
+		return fmt.Errorf("invalid progress mode %q (use \"auto\", \"bar\", \"plain\", or \"off\")", c.Progress)
 	}
-	if c.Columns <= 0 && c.CollageAspect == "" {
-		return fmt.Errorf("either columns or collage-aspect must be set")
+	if c.JSON && !c.DryRun {
+		return fmt.Errorf("json only applies to plan (or -dry-run)")
 	}
-	if c.Columns < 0 {
-		return fmt.Errorf("columns must not be negative")
+	if c.Manifest != "" && c.DryRun {
+		return fmt.Errorf("manifest cannot be written by plan (or -dry-run)")
 	}
-	switch c.SortMode {
-	case "", "time", "name", "exif":
-	default:
-		return fmt.Errorf("invalid sort mode %q (use \"time\", \"name\", or \"exif\")", c.SortMode)
+	_, err := c.options()
+	return err
+}
+
+// options translates the flag values into library options and validates them.
+func (c Config) options() (yearcollage.Options, error) {
+	opts := yearcollage.Options{
+		TileWidth:       c.TileWidth,
+		Columns:         c.Columns,
+		Layout:          yearcollage.Layout(c.Layout),
+		RowHeight:       c.RowHeight,
+		MaxCrop:         c.MaxCrop,
+		Calendar:        yearcollage.CalendarMode(c.Calendar),
+		Crop:            yearcollage.CropMode(c.Crop),
+		Fit:             yearcollage.FitMode(c.Fit),
+		Sort:            yearcollage.SortMode(c.SortMode),
+		Dedupe:          yearcollage.DedupeMode(c.Dedupe),
+		DedupeThreshold: c.DedupeThreshold,
+		MinSharpness:    c.MinSharpness,
+		BurstWindow:     c.Burst,
+		MaxImages:       c.MaxImages,
+		Sample:          yearcollage.SampleStrategy(c.Sample),
+		Seed:            c.Seed,
+		Jobs:            c.Jobs,
+		CacheDir:        c.CacheDir,
+		OnError:         yearcollage.ErrorPolicy(c.OnError),
+		EmbeddedThumbs:  c.EmbeddedThumbs,
+		Resample:        yearcollage.Resample(c.Resample),
+		LinearLight:     c.LinearLight,
+		MaxInputPixels:  c.MaxInputPixels,
+		MaxOutputPixels: c.MaxOutputPixels,
+	}
+	if opts.Sort == yearcollage.SortNone {
+		opts.Sort = yearcollage.SortModTime
+	}
+
+	if c.TileAspect != "" {
+		ratio, err := aspect.Parse(c.TileAspect)
+		if err != nil {
+			return opts, fmt.Errorf("invalid tile-aspect %q: %w", c.TileAspect, err)
+		}
+		opts.TileAspect = ratio
+	}
+	if c.CollageAspect != "" {
+		ratio, err := aspect.Parse(c.CollageAspect)
+		if err != nil {
+			return opts, fmt.Errorf("invalid collage-aspect %q: %w", c.CollageAspect, err)
+		}
+		opts.CollageAspect = ratio
+	}
+	if c.Year != 0 {
+		if c.From != "" || c.To != "" {
+			return opts, fmt.Errorf("year cannot be combined with from/to")
+		}
+		opts.From = time.Date(c.Year, time.January, 1, 0, 0, 0, 0, time.Local)
+		opts.To = opts.From.AddDate(1, 0, 0)
+	}
+	if c.From != "" {
+		from, _, err := parseDate(c.From)
+		if err != nil {
+			return opts, fmt.Errorf("invalid from: %w", err)
+		}
+		opts.From = from
+	}
+	if c.To != "" {
+		to, dateOnly, err := parseDate(c.To)
+		if err != nil {
+			return opts, fmt.Errorf("invalid to: %w", err)
+		}
+		// A bare date includes that whole day.
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		opts.To = to
+	}
+	if c.Background != "" {
+		bg, err := parseColor(c.Background)
+		if err != nil {
+			return opts, fmt.Errorf("invalid background: %w", err)
+		}
+		opts.Background = bg
+	}
+	if c.Sharpen != "" {
+		sharpen, err := parseSharpen(c.Sharpen)
+		if err != nil {
+			return opts, fmt.Errorf("invalid sharpen: %w", err)
+		}
+		opts.Sharpen = sharpen
+	}
+	if c.MaxMemory != "" {
+		limit, err := bytesize.Parse(c.MaxMemory)
+		if err != nil {
+			return opts, fmt.Errorf("invalid max-memory %q: %w", c.MaxMemory, err)
+		}
+		opts.MaxMemory = limit
+	}
+	return opts, opts.Validate()
+}
+
+// parseDate reads a local date (2006-01-02) or date and time
+// (2006-01-02T15:04:05 or with a space), reporting whether only a date was
+// given.
+func parseDate(s string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", time.DateTime} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("%q is not a date like 2025-06-21 or 2025-06-21T18:30:00", s)
+}
+
+// parseColor reads a #rgb or #rrggbb hex colour; the leading # is optional.
+func parseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("colour %q must look like #rrggbb or #rgb", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("colour %q must look like #rrggbb or #rgb", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+// parseSharpen reads an unsharp mask as "amount,radius", e.g. "0.8,1".
+func parseSharpen(s string) (yearcollage.Sharpen, error) {
+	amount, radius, ok := strings.Cut(s, ",")
+	if !ok {
+		return yearcollage.Sharpen{}, fmt.Errorf("%q must look like amount,radius, e.g. 0.8,1", s)
+	}
+	a, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil {
+		return yearcollage.Sharpen{}, fmt.Errorf("%q must look like amount,radius, e.g. 0.8,1", s)
+	}
+	r, err := strconv.ParseFloat(strings.TrimSpace(radius), 64)
+	if err != nil {
+		return yearcollage.Sharpen{}, fmt.Errorf("%q must look like amount,radius, e.g. 0.8,1", s)
+	}
+	return yearcollage.Sharpen{Amount: a, Radius: r}, nil
+}
+
+// onError reports the error policy, defaulting to fail.
+func (c Config) onError() string {
+	if c.OnError == "" {
+		return string(yearcollage.OnErrorFail)
 	}
-	return nil
+	return c.OnError
 }
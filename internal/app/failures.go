@@ -0,0 +1,61 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/luceast/yearcollage"
+)
+
+// tileFailure records an image that could not be rendered and why.
+type tileFailure struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// errorReport is the JSON document written by --error-report.
+type errorReport struct {
+	Policy string        `json:"policy"`
+	Failed []tileFailure `json:"failed"`
+}
+
+// tileFailures converts library failures into report entries.
+func tileFailures(failures []yearcollage.Failure) []tileFailure {
+	out := make([]tileFailure, len(failures))
+	for i, f := range failures {
+		out[i] = tileFailure{Path: f.Source, Reason: f.Err.Error()}
+	}
+	return out
+}
+
+// reportFailures logs a summary of every image that failed to render.
+func reportFailures(failures []tileFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	log.Printf("%d images failed to render:", len(failures))
+	for _, f := range failures {
+		log.Printf("  %s: %s", f.Path, f.Reason)
+	}
+}
+
+// writeErrorReport stores the failures as JSON when a report path is set. The
+// report is written even when nothing failed so scripts can rely on it.
+func writeErrorReport(path, policy string, failures []tileFailure) error {
+	if path == "" {
+		return nil
+	}
+	if failures == nil {
+		failures = []tileFailure{}
+	}
+	data, err := json.MarshalIndent(errorReport{Policy: policy, Failed: failures}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode error report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write error report %q: %w", path, err)
+	}
+	return nil
+}
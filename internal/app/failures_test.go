@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFailureFixture creates five good PNGs plus one truncated JPEG that
+// sorts into the middle of the list.
+func writeFailureFixture(t *testing.T, dir string) string {
+	t.Helper()
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("img-%02d.png", i*2))
+		if err := writeSolidPNG(path, 20, 20, color.RGBA{0, 0, 255, 255}); err != nil {
+			t.Fatalf("write image %s: %v", path, err)
+		}
+	}
+	bad := filepath.Join(dir, "img-03.jpg")
+	if err := os.WriteFile(bad, []byte("\xff\xd8\xff\xe0truncated"), 0o644); err != nil {
+		t.Fatalf("write corrupt image: %v", err)
+	}
+	return bad
+}
+
+func TestRunOnErrorPolicies(t *testing.T) {
+	cases := []struct {
+		policy     string
+		wantErr    bool
+		wantHeight int
+	}{
+		// 6 images in 3 columns need 2 rows; without the bad file 5 still do.
+		{"fail", true, 0},
+		{"placeholder", false, 20},
+		{"skip", false, 20},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.policy, func(t *testing.T) {
+			tmp := t.TempDir()
+			in := filepath.Join(tmp, "in")
+			bad := writeFailureFixture(t, in)
+
+			cfg := Config{
+				InputDirs:   []string{in},
+				Output:      filepath.Join(tmp, "out.png"),
+				TileAspect:  "1:1",
+				TileWidth:   10,
+				Columns:     3,
+				SortMode:    "name",
+				OnError:     tc.policy,
+				ErrorReport: filepath.Join(tmp, "errors.json"),
+			}
+			err := Run(context.Background(), cfg)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), bad) {
+					t.Fatalf("Run error = %v, want error mentioning %s", err, bad)
+				}
+			} else if err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+
+			data, err := os.ReadFile(cfg.ErrorReport)
+			if err != nil {
+				t.Fatalf("read error report: %v", err)
+			}
+			var report errorReport
+			if err := json.Unmarshal(data, &report); err != nil {
+				t.Fatalf("parse error report: %v", err)
+			}
+			if report.Policy != tc.policy || len(report.Failed) != 1 || report.Failed[0].Path != bad {
+				t.Fatalf("error report = %+v, want one failure for %s under %s", report, bad, tc.policy)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			out := readRGBA(t, cfg.Output)
+			if out.Bounds().Dy() != tc.wantHeight {
+				t.Fatalf("output height = %d, want %d", out.Bounds().Dy(), tc.wantHeight)
+			}
+			// Slot 2 holds the bad file under placeholder; under skip the
+			// next good image moves up into it.
+			r, g, b, _ := out.At(25, 2).RGBA()
+			gotBlue := r>>8 == 0 && g>>8 == 0 && b>>8 == 255
+			if wantBlue := tc.policy == "skip"; gotBlue != wantBlue {
+				t.Fatalf("slot 2 blue = %v, want %v (got %d,%d,%d)", gotBlue, wantBlue, r>>8, g>>8, b>>8)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/luceast/yearcollage"
+)
+
+// stdinName is the -files-from value that reads the list from standard input.
+const stdinName = "-"
+
+// collectSources gathers the photos from every input directory and the
+// -files-from list, in that order, dropping paths that refer to the same file
+// more than once. Listed files are used as given, without filters.
+func collectSources(ctx context.Context, cfg Config, stdin io.Reader) ([]yearcollage.Source, error) {
+	var paths []string
+	filter := yearcollage.Filter{Include: cfg.Include, Exclude: cfg.Exclude}
+	for _, dir := range cfg.InputDirs {
+		// Ensure the input path exists before walking it.
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("stat input dir %q: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("input path %q is not a directory", dir)
+		}
+
+		// Collect supported image files recursively.
+		found, err := yearcollage.Dir(ctx, dir, filter)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Found %d images in %s", len(found), dir)
+		for _, s := range found {
+			paths = append(paths, s.Name())
+		}
+	}
+
+	if cfg.FilesFrom != "" {
+		listed, err := readFileListFrom(cfg.FilesFrom, cfg.Null, stdin)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Read %d paths from %s", len(listed), cfg.FilesFrom)
+		paths = append(paths, listed...)
+	}
+
+	paths, dupes := dedupePaths(paths)
+	if dupes > 0 {
+		log.Printf("Dropped %d duplicate paths", dupes)
+	}
+	sources := make([]yearcollage.Source, len(paths))
+	for i, p := range paths {
+		sources[i] = yearcollage.File(p)
+	}
+	return sources, nil
+}
+
+// readFileListFrom reads a path list from the named file, or from stdin when
+// name is "-".
+func readFileListFrom(name string, null bool, stdin io.Reader) ([]string, error) {
+	if name == stdinName {
+		paths, err := readFileList(stdin, null)
+		if err != nil {
+			return nil, fmt.Errorf("read file list from stdin: %w", err)
+		}
+		return paths, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("open file list: %w", err)
+	}
+	defer f.Close()
+	paths, err := readFileList(f, null)
+	if err != nil {
+		return nil, fmt.Errorf("read file list %q: %w", name, err)
+	}
+	return paths, nil
+}
+
+// readFileList splits r into paths, one per line or NUL-terminated when null
+// is set (as written by find -print0). Empty entries are skipped; in line
+// mode a trailing carriage return is dropped too.
+func readFileList(r io.Reader, null bool) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	if null {
+		sc.Split(splitNull)
+	}
+
+	var paths []string
+	for sc.Scan() {
+		p := sc.Text()
+		if !null {
+			p = strings.TrimSuffix(p, "\r")
+		}
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths, sc.Err()
+}
+
+// splitNull is a bufio.SplitFunc for NUL-terminated records.
+func splitNull(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// dedupePaths keeps the first occurrence of each file, comparing cleaned
+// absolute paths so "a/b.jpg" and "./a/../a/b.jpg" count as one, and reports
+// how many were dropped.
+func dedupePaths(paths []string) ([]string, int) {
+	seen := make(map[string]bool, len(paths))
+	kept := paths[:0]
+	for _, p := range paths {
+		key, err := filepath.Abs(p)
+		if err != nil {
+			key = filepath.Clean(p)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, p)
+	}
+	return kept, len(paths) - len(kept)
+}
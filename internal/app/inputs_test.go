@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFileList(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		null bool
+		want []string
+	}{
+		{"lines", "a.jpg\nb dir/c.jpg\n\nd.png", false, []string{"a.jpg", "b dir/c.jpg", "d.png"}},
+		{"crlf", "a.jpg\r\nb.jpg\r\n", false, []string{"a.jpg", "b.jpg"}},
+		{"nul", "a.jpg\x00new\nline.jpg\x00\x00c.jpg", true, []string{"a.jpg", "new\nline.jpg", "c.jpg"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readFileList(strings.NewReader(tc.in), tc.null)
+			if err != nil {
+				t.Fatalf("readFileList: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCollectSourcesMergesAndDedupes(t *testing.T) {
+	tmp := t.TempDir()
+	phone := filepath.Join(tmp, "phone")
+	camera := filepath.Join(tmp, "camera")
+	for _, p := range []string{
+		filepath.Join(phone, "a.png"),
+		filepath.Join(phone, "b.png"),
+		filepath.Join(camera, "c.png"),
+		filepath.Join(tmp, "extra.png"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeSolidPNG(p, 4, 4, color.RGBA{0, 0, 255, 255}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The list repeats a directory hit under a different spelling and adds a
+	// file outside both directories.
+	list := phone + "/../phone/a.png\x00" + filepath.Join(tmp, "extra.png") + "\x00"
+	cfg := Config{
+		InputDirs: []string{phone, camera, phone},
+		FilesFrom: stdinName,
+		Null:      true,
+	}
+	sources, err := collectSources(context.Background(), cfg, strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("collectSources: %v", err)
+	}
+
+	var got []string
+	for _, s := range sources {
+		got = append(got, s.Name())
+	}
+	want := []string{
+		filepath.Join(phone, "a.png"),
+		filepath.Join(phone, "b.png"),
+		filepath.Join(camera, "c.png"),
+		filepath.Join(tmp, "extra.png"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sources = %q, want %q", got, want)
+	}
+}
+
+func TestCollectSourcesRejectsFileAsInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	if err := writeSolidPNG(path, 4, 4, color.RGBA{255, 0, 0, 255}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := collectSources(context.Background(), Config{InputDirs: []string{path}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("error = %v, want not a directory", err)
+	}
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"github.com/luceast/yearcollage"
+)
+
+// manifestVersion is the schema version written by --manifest. Adding fields
+// keeps it; renaming, removing or changing the meaning of one bumps it.
+const manifestVersion = 1
+
+// manifest is the JSON document written by --manifest.
+type manifest struct {
+	Version int            `json:"version"`
+	Output  string         `json:"output"`
+	Format  string         `json:"format"`
+	Layout  manifestLayout `json:"layout"`
+	Tiles   []manifestTile `json:"tiles"`
+}
+
+// manifestLayout holds the parameters that apply to the whole collage.
+type manifestLayout struct {
+	Mode       string `json:"mode"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Columns    int    `json:"columns"`
+	Rows       int    `json:"rows"`
+	TileWidth  int    `json:"tile_width,omitempty"`
+	TileHeight int    `json:"tile_height,omitempty"`
+	Fit        string `json:"fit"`
+	Crop       string `json:"crop"`
+	Background string `json:"background"`
+	Resample   string `json:"resample"`
+	Sort       string `json:"sort"`
+}
+
+// manifestTile records one photo on the canvas.
+type manifestTile struct {
+	Path        string       `json:"path"`
+	Captured    *time.Time   `json:"captured"`
+	Row         int          `json:"row"`
+	Column      int          `json:"column"`
+	Dest        manifestRect `json:"dest"`
+	Crop        manifestRect `json:"crop"`
+	Orientation int          `json:"orientation"`
+}
+
+// manifestRect is a rectangle by its top-left corner and size.
+type manifestRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func rectOf(r image.Rectangle) manifestRect {
+	return manifestRect{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
+
+// writeManifest stores where every photo of the collage came from and went
+// to as JSON when a manifest path is set.
+func writeManifest(path string, cfg Config, res *yearcollage.Result) error {
+	if path == "" {
+		return nil
+	}
+	m := manifest{
+		Version: manifestVersion,
+		Output:  cfg.Output,
+		Format:  string(yearcollage.FormatFor(cfg.Output)),
+		Layout: manifestLayout{
+			Mode:       cmp.Or(cfg.Layout, string(yearcollage.LayoutGrid)),
+			Width:      res.Bounds.Dx(),
+			Height:     res.Bounds.Dy(),
+			Columns:    res.Columns,
+			Rows:       res.Rows,
+			TileWidth:  res.TileWidth,
+			TileHeight: res.TileHeight,
+			Fit:        cmp.Or(cfg.Fit, string(yearcollage.FitCrop)),
+			Crop:       cmp.Or(cfg.Crop, string(yearcollage.CropCenter)),
+			Background: cmp.Or(cfg.Background, "#000000"),
+			Resample:   cmp.Or(cfg.Resample, string(yearcollage.ResampleFast)),
+			Sort:       cmp.Or(cfg.SortMode, string(yearcollage.SortModTime)),
+		},
+		Tiles: make([]manifestTile, len(res.Tiles)),
+	}
+	for i, t := range res.Tiles {
+		m.Tiles[i] = manifestTile{
+			Path:        t.Source,
+			Row:         t.Row,
+			Column:      t.Column,
+			Dest:        rectOf(t.Rect),
+			Crop:        rectOf(t.Crop),
+			Orientation: t.Orientation,
+		}
+		if !t.Time.IsZero() {
+			m.Tiles[i].Captured = &t.Time
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write manifest %q: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunWritesManifest(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in")
+	for name, size := range map[string][2]int{"a.png": {40, 20}, "b.png": {20, 20}, "c.png": {20, 60}} {
+		if err := writeSolidPNG(filepath.Join(in, name), size[0], size[1], color.RGBA{200, 60, 60, 255}); err != nil {
+			t.Fatalf("write image %s: %v", name, err)
+		}
+	}
+
+	cfg := Config{
+		InputDirs:  []string{in},
+		Output:     filepath.Join(tmp, "out.png"),
+		TileAspect: "1:1",
+		TileWidth:  10,
+		Columns:    2,
+		SortMode:   "name",
+		Crop:       "top",
+		Manifest:   filepath.Join(tmp, "tiles.json"),
+	}
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.Manifest)
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	var got manifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decode manifest: %v\n%s", err, data)
+	}
+	if got.Version != manifestVersion || got.Format != "png" || got.Output != cfg.Output {
+		t.Fatalf("header = version %d, format %q, output %q", got.Version, got.Format, got.Output)
+	}
+	wantLayout := manifestLayout{Mode: "grid", Width: 20, Height: 20, Columns: 2, Rows: 2, TileWidth: 10, TileHeight: 10,
+		Fit: "crop", Crop: "top", Background: "#000000", Resample: "fast", Sort: "name"}
+	if got.Layout != wantLayout {
+		t.Fatalf("layout = %+v, want %+v", got.Layout, wantLayout)
+	}
+
+	want := []manifestTile{
+		{Path: filepath.Join(in, "a.png"), Row: 0, Column: 0, Dest: manifestRect{0, 0, 10, 10}, Crop: manifestRect{10, 0, 20, 20}, Orientation: 1},
+		{Path: filepath.Join(in, "b.png"), Row: 0, Column: 1, Dest: manifestRect{10, 0, 10, 10}, Crop: manifestRect{0, 0, 20, 20}, Orientation: 1},
+		{Path: filepath.Join(in, "c.png"), Row: 1, Column: 0, Dest: manifestRect{0, 10, 10, 10}, Crop: manifestRect{0, 0, 20, 20}, Orientation: 1},
+	}
+	if len(got.Tiles) != len(want) {
+		t.Fatalf("manifest has %d tiles, want %d", len(got.Tiles), len(want))
+	}
+	for i, tile := range got.Tiles {
+		if tile.Captured == nil {
+			t.Errorf("tile %d has no capture time", i)
+		}
+		tile.Captured = nil
+		if tile != want[i] {
+			t.Errorf("tile %d = %+v, want %+v", i, tile, want[i])
+		}
+	}
+}
+
+func TestManifestOmitsJustifiedTileSize(t *testing.T) {
+	tmp := t.TempDir()
+	in := filepath.Join(tmp, "in")
+	for name, size := range map[string][2]int{"a.png": {60, 20}, "b.png": {20, 40}} {
+		if err := writeSolidPNG(filepath.Join(in, name), size[0], size[1], color.RGBA{60, 60, 200, 255}); err != nil {
+			t.Fatalf("write image %s: %v", name, err)
+		}
+	}
+	cfg := Config{
+		InputDirs:  []string{in},
+		Output:     filepath.Join(tmp, "out.png"),
+		TileAspect: "1:1",
+		TileWidth:  40,
+		Columns:    3,
+		Layout:     "justified",
+		Manifest:   filepath.Join(tmp, "tiles.json"),
+	}
+	if err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	data, err := os.ReadFile(cfg.Manifest)
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	if strings.Contains(string(data), "tile_width") || strings.Contains(string(data), "tile_height") {
+		t.Fatalf("justified manifest has a tile size:\n%s", data)
+	}
+}
@@ -1,92 +0,0 @@
-package app
-
-import "image"
-
-// normalizeOrientation rotates or flips the image according to the EXIF/TIFF
-// orientation value. This mirrors the mappings defined in the EXIF spec so we
-// only need to conditionally rotate rather than re-encode metadata later.
-func normalizeOrientation(img image.Image, orientation int) image.Image {
-	switch orientation {
-	case 2:
-		return flipHorizontal(img)
-	case 3:
-		return rotate180(img)
-	case 4:
-		return flipVertical(img)
-	case 5:
-		return rotate90CCW(flipHorizontal(img))
-	case 6:
-		return rotate90CW(img)
-	case 7:
-		return rotate90CW(flipHorizontal(img))
-	case 8:
-		return rotate90CCW(img)
-	default:
-		return img
-	}
-}
-
-// flipHorizontal mirrors pixels along the vertical axis.
-func flipHorizontal(img image.Image) image.Image {
-	b := img.Bounds()
-	w, h := b.Dx(), b.Dy()
-	dst := image.NewRGBA(image.Rect(0, 0, w, h))
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			dst.Set(w-1-x, y, img.At(b.Min.X+x, b.Min.Y+y))
-		}
-	}
-	return dst
-}
-
-// flipVertical mirrors pixels along the horizontal axis.
-func flipVertical(img image.Image) image.Image {
-	b := img.Bounds()
-	w, h := b.Dx(), b.Dy()
-	dst := image.NewRGBA(image.Rect(0, 0, w, h))
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			dst.Set(x, h-1-y, img.At(b.Min.X+x, b.Min.Y+y))
-		}
-	}
-	return dst
-}
-
-// rotate180 rotates the image 180 degrees (upside down).
-func rotate180(img image.Image) image.Image {
-	b := img.Bounds()
-	w, h := b.Dx(), b.Dy()
-	dst := image.NewRGBA(image.Rect(0, 0, w, h))
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			dst.Set(w-1-x, h-1-y, img.At(b.Min.X+x, b.Min.Y+y))
-		}
-	}
-	return dst
-}
-
-// rotate90CW rotates the image 90 degrees clockwise.
-func rotate90CW(img image.Image) image.Image {
-	b := img.Bounds()
-	w, h := b.Dx(), b.Dy()
-	dst := image.NewRGBA(image.Rect(0, 0, h, w))
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			dst.Set(h-1-y, x, img.At(b.Min.X+x, b.Min.Y+y))
-		}
-	}
-	return dst
-}
-
-// rotate90CCW rotates the image 90 degrees counter-clockwise.
-func rotate90CCW(img image.Image) image.Image {
-	b := img.Bounds()
-	w, h := b.Dx(), b.Dy()
-	dst := image.NewRGBA(image.Rect(0, 0, h, w))
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			dst.Set(y, w-1-x, img.At(b.Min.X+x, b.Min.Y+y))
-		}
-	}
-	return dst
-}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/luceast/yearcollage"
+	"github.com/luceast/yearcollage/internal/bytesize"
+)
+
+// planReport is the JSON document printed by `yearcollage plan --json`.
+type planReport struct {
+	Output      string `json:"output"`
+	Format      string `json:"format"`
+	Images      int    `json:"images"`
+	Columns     int    `json:"columns"`
+	Rows        int    `json:"rows"`
+	TileWidth   int    `json:"tile_width,omitempty"`
+	TileHeight  int    `json:"tile_height,omitempty"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	EmptyCells  int    `json:"empty_cells"`
+	Strips      int    `json:"strips"`
+	MemoryBytes int64  `json:"memory_bytes"`
+	OutputBytes int64  `json:"output_bytes"`
+}
+
+// printPlan writes the dry-run plan for output to w, as aligned text or as
+// JSON.
+func printPlan(w io.Writer, output string, p *yearcollage.Plan, asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(planReport{
+			Output:      output,
+			Format:      string(p.Format),
+			Images:      p.Images,
+			Columns:     p.Columns,
+			Rows:        p.Rows,
+			TileWidth:   p.TileWidth,
+			TileHeight:  p.TileHeight,
+			Width:       p.Width,
+			Height:      p.Height,
+			EmptyCells:  p.EmptyCells,
+			Strips:      p.Strips,
+			MemoryBytes: p.Memory,
+			OutputBytes: p.OutputSize,
+		}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode plan: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	memory := bytesize.Format(p.Memory)
+	if p.Strips > 1 {
+		memory += fmt.Sprintf(" (canvas in %d strips)", p.Strips)
+	}
+	tile := "varies (justified)"
+	if p.TileWidth > 0 {
+		tile = fmt.Sprintf("%d×%d", p.TileWidth, p.TileHeight)
+	}
+	_, err := fmt.Fprintf(w, `Output:      %s (%s)
+Images:      %d
+Grid:        %d columns × %d rows
+Tile size:   %s
+Canvas:      %d×%d
+Empty cells: %d
+Memory:      ~%s
+File size:   ~%s
+`, output, p.Format, p.Images, p.Columns, p.Rows, tile, p.Width, p.Height, p.EmptyCells, memory, bytesize.Format(p.OutputSize))
+	return err
+}
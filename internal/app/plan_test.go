@@ -0,0 +1,61 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/luceast/yearcollage"
+)
+
+func TestPrintPlan(t *testing.T) {
+	p := &yearcollage.Plan{
+		Format: yearcollage.FormatPNG, Images: 10, Columns: 4, Rows: 3, TileWidth: 400, TileHeight: 300,
+		Width: 1600, Height: 900, EmptyCells: 2, Strips: 3, Memory: 12 << 20, OutputSize: 2880000,
+	}
+
+	var text bytes.Buffer
+	if err := printPlan(&text, "out.png", p, false); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+	for _, want := range []string{"out.png (png)", "4 columns × 3 rows", "400×300", "1600×900", "Empty cells: 2", "~12.0 MiB (canvas in 3 strips)", "~2.7 MiB"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text plan lacks %q:\n%s", want, text.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := printPlan(&out, "out.png", p, true); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+	var got planReport
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("decode JSON plan: %v\n%s", err, out.String())
+	}
+	want := planReport{Output: "out.png", Format: "png", Images: 10, Columns: 4, Rows: 3, TileWidth: 400, TileHeight: 300,
+		Width: 1600, Height: 900, EmptyCells: 2, Strips: 3, MemoryBytes: 12 << 20, OutputBytes: 2880000}
+	if got != want {
+		t.Fatalf("JSON plan = %+v, want %+v", got, want)
+	}
+}
+
+func TestPrintPlanJustified(t *testing.T) {
+	p := &yearcollage.Plan{Format: yearcollage.FormatJPEG, Images: 5, Columns: 3, Rows: 2, Width: 600, Height: 300, Strips: 1}
+
+	var text bytes.Buffer
+	if err := printPlan(&text, "out.jpg", p, false); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+	if !strings.Contains(text.String(), "Tile size:   varies (justified)") {
+		t.Errorf("text plan lacks the varying tile size:\n%s", text.String())
+	}
+
+	var out bytes.Buffer
+	if err := printPlan(&out, "out.jpg", p, true); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+	if strings.Contains(out.String(), "tile_width") || strings.Contains(out.String(), "tile_height") {
+		t.Errorf("JSON plan has a tile size for a justified layout:\n%s", out.String())
+	}
+}
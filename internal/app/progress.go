@@ -0,0 +1,179 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luceast/yearcollage"
+)
+
+// Progress display modes.
+const (
+	progressAuto  = "auto"  // bar on a terminal, plain lines otherwise
+	progressBar   = "bar"   // a single redrawn line with a bar and ETA
+	progressPlain = "plain" // periodic log-style lines, for files and CI logs
+	progressOff   = "off"
+)
+
+const (
+	barWidth      = 30
+	barInterval   = 100 * time.Millisecond
+	plainInterval = 5 * time.Second
+)
+
+// progress renders yearcollage events as a terminal bar or periodic lines.
+// It is driven by the library's serialized Progress callback and doubles as
+// the log output, which the library may write from other goroutines.
+type progress struct {
+	w        io.Writer
+	bar      bool
+	interval time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex // guards w and everything below
+	line string     // the bar line on screen while drawn is set
+
+	stage       yearcollage.Stage
+	done, total int
+	failed      int
+	start, last time.Time
+	drawn       bool // a bar line is on screen and needs a newline
+}
+
+// newProgress returns a reporter for the given mode writing to w, or nil when
+// progress is off. Auto mode draws a bar only when w is a terminal.
+func newProgress(mode string, w io.Writer) *progress {
+	switch mode {
+	case progressOff:
+		return nil
+	case "", progressAuto:
+		mode = progressPlain
+		if isTerminal(w) {
+			mode = progressBar
+		}
+	}
+	p := &progress{w: w, bar: mode == progressBar, interval: plainInterval, now: time.Now}
+	if p.bar {
+		p.interval = barInterval
+	}
+	return p
+}
+
+// isTerminal reports whether w is a character device such as a TTY.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// event updates the counters and redraws when the interval has passed or the
+// stage has finished.
+func (p *progress) event(e yearcollage.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	switch e.Kind {
+	case yearcollage.EventStage:
+		p.endLine()
+		p.stage, p.done, p.total, p.failed = e.Stage, 0, e.Total, 0
+		p.start, p.last = now, time.Time{}
+		return
+	case yearcollage.EventItem:
+		p.done = e.Done
+		if e.Err != nil {
+			p.failed++
+		}
+	}
+	if p.done < p.total && now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	p.draw(now)
+}
+
+// draw writes the current state.
+func (p *progress) draw(now time.Time) {
+	status := fmt.Sprintf("%s %d/%d (%d%%)", p.stage, p.done, p.total, percent(p.done, p.total))
+	if p.failed > 0 {
+		status += fmt.Sprintf(", %d failed", p.failed)
+	}
+	if eta, ok := p.eta(now); ok {
+		status += ", ETA " + formatETA(eta)
+	}
+
+	if !p.bar {
+		fmt.Fprintln(p.w, status)
+		return
+	}
+	filled := barWidth * p.done / max(1, p.total)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+	// Pad so a shorter line fully overwrites the previous one.
+	p.line = fmt.Sprintf("[%s] %-50s", bar, status)
+	fmt.Fprint(p.w, "\r"+p.line)
+	p.drawn = true
+	if p.done >= p.total {
+		p.endLine()
+	}
+}
+
+// Write passes log output through to w. A bar line on screen is cleared
+// first and redrawn after the log line, so the two never share a line.
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.drawn {
+		return p.w.Write(b)
+	}
+	fmt.Fprint(p.w, "\r"+strings.Repeat(" ", len(p.line))+"\r")
+	n, err := p.w.Write(b)
+	fmt.Fprint(p.w, "\r"+p.line)
+	return n, err
+}
+
+// finish ends an on-screen bar line so later log output starts cleanly.
+func (p *progress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.endLine()
+}
+
+// endLine is finish for callers that hold mu.
+func (p *progress) endLine() {
+	if p.drawn {
+		fmt.Fprintln(p.w)
+		p.drawn = false
+	}
+}
+
+// eta extrapolates the remaining time from the average pace so far.
+func (p *progress) eta(now time.Time) (time.Duration, bool) {
+	if p.done == 0 || p.done >= p.total {
+		return 0, false
+	}
+	elapsed := now.Sub(p.start)
+	return time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done)), true
+}
+
+// percent returns done/total as a whole percentage.
+func percent(done, total int) int {
+	if total <= 0 {
+		return 100
+	}
+	return done * 100 / total
+}
+
+// formatETA renders a duration as m:ss, or h:mm:ss for long renders.
+func formatETA(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
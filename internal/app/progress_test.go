@@ -0,0 +1,100 @@
+package app
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luceast/yearcollage"
+)
+
+// feed drives p through one stage of n items, advancing a fake clock by step
+// before every item.
+func feed(p *progress, n int, step time.Duration) {
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return clock }
+	p.event(yearcollage.Event{Kind: yearcollage.EventStage, Stage: yearcollage.StageRender, Total: n})
+	for i := 1; i <= n; i++ {
+		clock = clock.Add(step)
+		p.event(yearcollage.Event{Kind: yearcollage.EventItem, Stage: yearcollage.StageRender, Done: i, Total: n})
+	}
+	p.finish()
+}
+
+func TestProgressPlainLinesAreThrottled(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(progressPlain, &buf)
+	feed(p, 20, time.Second)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// The first item, then every 5 seconds, then the final item.
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), buf.String())
+	}
+	if lines[0] != "render 1/20 (5%), ETA 0:19" {
+		t.Fatalf("first line = %q", lines[0])
+	}
+	if lines[len(lines)-1] != "render 20/20 (100%)" {
+		t.Fatalf("last line = %q", lines[len(lines)-1])
+	}
+}
+
+func TestProgressBarRedrawsOneLine(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(progressBar, &buf)
+	feed(p, 4, time.Second)
+
+	out := buf.String()
+	if strings.Count(out, "\n") != 1 || !strings.HasSuffix(out, "\n") {
+		t.Fatalf("bar output should end in exactly one newline: %q", out)
+	}
+	if !strings.Contains(out, "ETA 0:03") || !strings.Contains(out, "["+strings.Repeat("=", barWidth)+"] render 4/4 (100%)") {
+		t.Fatalf("bar output missing ETA or final state: %q", out)
+	}
+}
+
+func TestProgressBarClearsForLogLines(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(progressBar, &buf)
+	p.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+	p.event(yearcollage.Event{Kind: yearcollage.EventStage, Stage: yearcollage.StageRender, Total: 4})
+	p.event(yearcollage.Event{Kind: yearcollage.EventItem, Stage: yearcollage.StageRender, Done: 1, Total: 4})
+	log.New(p, "", 0).Print("warn: cache write failed")
+
+	out := buf.String()
+	before, after, ok := strings.Cut(out, "warn: cache write failed\n")
+	if !ok {
+		t.Fatalf("log line missing: %q", out)
+	}
+	// The bar is blanked out and the cursor returned before the log line.
+	if !strings.HasSuffix(before, "\r"+strings.Repeat(" ", len(p.line))+"\r") {
+		t.Fatalf("bar not cleared before the log line: %q", out)
+	}
+	if !strings.HasPrefix(after, "\r[") || !strings.Contains(after, "render 1/4") {
+		t.Fatalf("bar not redrawn after the log line: %q", out)
+	}
+}
+
+func TestProgressOffAndAuto(t *testing.T) {
+	if newProgress(progressOff, &bytes.Buffer{}) != nil {
+		t.Fatalf("off mode returned a reporter")
+	}
+	if p := newProgress(progressAuto, &bytes.Buffer{}); p == nil || p.bar {
+		t.Fatalf("auto mode on a buffer should print plain lines")
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		3 * time.Second:             "0:03",
+		95 * time.Second:            "1:35",
+		2*time.Hour + 5*time.Minute: "2:05:00",
+		1500 * time.Millisecond:     "0:02",
+	} {
+		if got := formatETA(d); got != want {
+			t.Fatalf("formatETA(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
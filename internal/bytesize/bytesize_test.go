@@ -0,0 +1,36 @@
+package bytesize
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"1048576", 1 << 20, false},
+		{"512MB", 512e6, false},
+		{"2GiB", 2 << 30, false},
+		{"1.5 g", 3 << 29, false},
+		{"4T", 4 << 40, false},
+		{"lots", 0, true},
+		{"-1G", 0, true},
+	}
+	for _, tc := range cases {
+		got, err := Parse(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("Parse(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+		}
+		if !tc.wantErr && got != tc.want {
+			t.Fatalf("Parse(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB"} {
+		if got := Format(n); got != want {
+			t.Fatalf("Format(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/luceast/yearcollage/internal/pngchunk"
+)
+
+// entryExt marks files owned by the cache so stats and prune never touch
+// anything else that happens to live in the directory.
+const entryExt = ".tile.png"
+
+// hashExt marks the files that remember a source's content hash.
+const hashExt = ".hash"
+
+// hashDir holds the content hash records, apart from the tile shards.
+const hashDir = "sources"
+
+// noteKeyword names the PNG tEXt chunk that carries an entry's note.
+const noteKeyword = "yearcollage"
+
+// Cache stores rendered tiles on disk. Entries are addressed by a key that
+// combines the source file's content hash with every render parameter, so a
+// changed photo or a different tile size simply misses instead of going stale.
+type Cache struct {
+	dir string
+}
+
+// Open prepares dir for use as a tile cache, creating it when missing.
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache dir %q: %w", dir, err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// ErrNoCache reports that a cache directory does not exist.
+var ErrNoCache = errors.New("no cache")
+
+// OpenExisting opens dir as a tile cache without creating it, so read-only
+// commands leave the file system alone. A missing dir reports ErrNoCache.
+func OpenExisting(dir string) (*Cache, error) {
+	info, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w at %q", ErrNoCache, dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open cache dir %q: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("cache dir %q is not a directory", dir)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Dir reports the cache root directory.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Hash returns the hex SHA-256 of everything read from r.
+func Hash(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, bufio.NewReader(r)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashPath is where the content hash record of the source file at path lives.
+func (c *Cache) hashPath(path string) string {
+	key := Key(path)
+	return filepath.Join(c.dir, hashDir, key[:2], key+hashExt)
+}
+
+// SourceHash returns the content hash recorded for the file at path, if the
+// file still has the given size and modification time. Hashing every photo
+// on each run would read the whole library just to find the tiles.
+func (c *Cache) SourceHash(path string, size int64, modTime time.Time) (string, bool) {
+	p := c.hashPath(path)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", false
+	}
+	var (
+		gotSize int64
+		gotMod  int64
+		hash    string
+	)
+	if n, err := fmt.Sscanf(string(data), "%d %d %s", &gotSize, &gotMod, &hash); err != nil || n != 3 {
+		_ = os.Remove(p)
+		return "", false
+	}
+	if gotSize != size || gotMod != modTime.UnixNano() {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return hash, true
+}
+
+// PutSourceHash records the content hash of the file at path along with the
+// size and modification time it was computed for.
+func (c *Cache) PutSourceHash(path string, size int64, modTime time.Time, hash string) error {
+	p := c.hashPath(path)
+	return writeAtomic(p, []byte(fmt.Sprintf("%d %d %s\n", size, modTime.UnixNano(), hash)))
+}
+
+// writeAtomic writes data to a temporary file next to p and renames it into
+// place, so readers never see a partial file.
+func writeAtomic(p string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("create cache shard: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("close cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("commit cache entry: %w", err)
+	}
+	return nil
+}
+
+// Key derives a cache key from the content hash and the render parameters.
+func Key(contentHash string, params ...string) string {
+	h := sha256.New()
+	io.WriteString(h, contentHash)
+	for _, p := range params {
+		// Separate parts so ("ab", "c") and ("a", "bc") never collide.
+		io.WriteString(h, "\x00")
+		io.WriteString(h, p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path shards entries by the first two key characters to keep directories small.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+entryExt)
+}
+
+// Get loads a cached tile and the note stored with it. Misses and unreadable
+// entries both report false; a corrupt entry is removed so the next Put can
+// replace it.
+func (c *Cache) Get(key string) (*image.RGBA, string, bool) {
+	p := c.path(key)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, "", false
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		_ = os.Remove(p)
+		return nil, "", false
+	}
+	note := readNote(data)
+
+	// Touch the entry so age-based pruning evicts the least recently used tiles.
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba, note, true
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba, note, true
+}
+
+// Put stores a tile with a short note, such as how it was cut from its
+// source. The entry is written to a temporary file and renamed into place, so
+// concurrent renders never observe a partially written tile.
+func (c *Cache) Put(key string, img image.Image, note string) error {
+	p := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("create cache shard: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create cache entry: %w", err)
+	}
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	w := bufio.NewWriter(tmp)
+	if err := enc.Encode(noteWriter(w, note), img); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("encode cache entry: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("close cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("commit cache entry: %w", err)
+	}
+	return nil
+}
+
+// Stats summarizes the cache contents.
+type Stats struct {
+	Entries int
+	Bytes   int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// Stats walks the cache and totals entry count and size.
+func (c *Cache) Stats() (Stats, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return Stats{}, err
+	}
+	var s Stats
+	for _, e := range entries {
+		s.Entries++
+		s.Bytes += e.size
+		if s.Oldest.IsZero() || e.modTime.Before(s.Oldest) {
+			s.Oldest = e.modTime
+		}
+		if e.modTime.After(s.Newest) {
+			s.Newest = e.modTime
+		}
+	}
+	return s, nil
+}
+
+// Prune removes entries not used within maxAge (when > 0) and then evicts the
+// least recently used entries until the cache fits in maxBytes (when > 0).
+// It reports how many entries were removed and how many bytes were freed.
+func (c *Cache) Prune(maxAge time.Duration, maxBytes int64) (removed int, freed int64, err error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Oldest first, so both age and size eviction walk from the front.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		expired := maxAge > 0 && e.modTime.Before(cutoff)
+		oversized := maxBytes > 0 && total > maxBytes
+		if !expired && !oversized {
+			continue
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return removed, freed, fmt.Errorf("remove cache entry %q: %w", e.path, err)
+		}
+		removed++
+		freed += e.size
+		total -= e.size
+	}
+
+	// Hash records are tiny; they only go once unused for maxAge.
+	if maxAge > 0 {
+		records, err := c.files(filepath.Join(c.dir, hashDir), hashExt)
+		if err != nil {
+			return removed, freed, err
+		}
+		for _, e := range records {
+			if e.modTime.Before(cutoff) {
+				_ = os.Remove(e.path)
+			}
+		}
+	}
+	return removed, freed, nil
+}
+
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// entries lists every tile file in the cache.
+func (c *Cache) entries() ([]entry, error) {
+	return c.files(c.dir, entryExt)
+}
+
+// files lists the files below root whose names end in ext. A missing root
+// has none.
+func (c *Cache) files(root, ext string) ([]entry, error) {
+	var out []entry
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ext) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		out = append(out, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan cache %q: %w", c.dir, err)
+	}
+	return out, nil
+}
+
+// noteWriter passes a PNG stream through to w and inserts the note as a tEXt
+// chunk right after the IHDR chunk.
+func noteWriter(w io.Writer, note string) io.Writer {
+	if note == "" {
+		return w
+	}
+	return pngchunk.Writer(w, pngchunk.Encode("tEXt", []byte(noteKeyword+"\x00"+note)))
+}
+
+// readNote returns the note of a PNG entry, or "" when it has none. The
+// decoder has already checked the chunk structure.
+func readNote(data []byte) string {
+	for off := 8; off+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[off:]))
+		typ := string(data[off+4 : off+8])
+		end := off + 8 + n
+		if typ == "IDAT" || end > len(data) {
+			break
+		}
+		if text, ok := bytes.CutPrefix(data[off+8:end], []byte(noteKeyword+"\x00")); ok && typ == "tEXt" {
+			return string(text)
+		}
+		off = end + 4
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutGetRoundTrip(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "tiles"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.RGBA{10, 20, 30, 255})
+	key := Key("abc", "w=3", "h=2")
+
+	if _, _, ok := c.Get(key); ok {
+		t.Fatalf("Get on empty cache reported a hit")
+	}
+	if err := c.Put(key, img, "crop=1,2,3,4"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, note, ok := c.Get(key)
+	if !ok {
+		t.Fatalf("Get after Put reported a miss")
+	}
+	if !bytes.Equal(got.Pix, img.Pix) {
+		t.Fatalf("cached pixels differ from stored tile")
+	}
+	if note != "crop=1,2,3,4" {
+		t.Fatalf("note = %q, want %q", note, "crop=1,2,3,4")
+	}
+}
+
+func TestKeyDependsOnEveryPart(t *testing.T) {
+	base := Key("hash", "a", "bc")
+	for _, other := range []string{
+		Key("hash2", "a", "bc"),
+		Key("hash", "ab", "c"),
+		Key("hash", "a", "bc", ""),
+	} {
+		if other == base {
+			t.Fatalf("distinct inputs produced the same key %s", base)
+		}
+	}
+	if Key("hash", "a", "bc") != base {
+		t.Fatalf("Key is not deterministic")
+	}
+}
+
+func TestPruneAndStats(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// Three entries with staggered ages; the oldest should go first.
+	keys := []string{Key("old"), Key("mid"), Key("new")}
+	for i, key := range keys {
+		if err := c.Put(key, image.NewRGBA(image.Rect(0, 0, 8, 8)), ""); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		age := time.Now().Add(-time.Duration(len(keys)-i) * time.Hour)
+		if err := os.Chtimes(c.path(key), age, age); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.Entries != 3 || stats.Bytes <= 0 {
+		t.Fatalf("Stats = %+v, want 3 entries with a positive size", stats)
+	}
+
+	removed, _, err := c.Prune(150*time.Minute, 0)
+	if err != nil {
+		t.Fatalf("Prune by age: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Prune by age removed %d entries, want 1", removed)
+	}
+	if _, err := os.Stat(c.path(keys[0])); !os.IsNotExist(err) {
+		t.Fatalf("oldest entry still present after prune")
+	}
+
+	info, err := os.Stat(c.path(keys[2]))
+	if err != nil {
+		t.Fatalf("stat newest entry: %v", err)
+	}
+	removed, _, err = c.Prune(0, info.Size())
+	if err != nil {
+		t.Fatalf("Prune by size: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Prune by size removed %d entries, want 1", removed)
+	}
+	if _, _, ok := c.Get(keys[2]); !ok {
+		t.Fatalf("newest entry evicted by size prune")
+	}
+}
+
+func TestSourceHashNeedsSameSizeAndTime(t *testing.T) {
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	mod := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	if _, ok := c.SourceHash("/photos/a.jpg", 10, mod); ok {
+		t.Fatalf("SourceHash on empty cache reported a hit")
+	}
+	if err := c.PutSourceHash("/photos/a.jpg", 10, mod, "abc"); err != nil {
+		t.Fatalf("PutSourceHash: %v", err)
+	}
+	if got, ok := c.SourceHash("/photos/a.jpg", 10, mod); !ok || got != "abc" {
+		t.Fatalf("SourceHash = %q, %v; want abc, true", got, ok)
+	}
+	for _, tc := range []struct {
+		path string
+		size int64
+		mod  time.Time
+	}{
+		{"/photos/b.jpg", 10, mod},
+		{"/photos/a.jpg", 11, mod},
+		{"/photos/a.jpg", 10, mod.Add(time.Nanosecond)},
+	} {
+		if _, ok := c.SourceHash(tc.path, tc.size, tc.mod); ok {
+			t.Fatalf("SourceHash(%s, %d, %v) hit a record for another file state", tc.path, tc.size, tc.mod)
+		}
+	}
+	// Hash records are not tiles.
+	if s, err := c.Stats(); err != nil || s.Entries != 0 {
+		t.Fatalf("Stats = %+v, %v; want no entries", s, err)
+	}
+}
+
+func TestOpenExistingLeavesMissingDirAlone(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tiles")
+	if _, err := OpenExisting(dir); !errors.Is(err, ErrNoCache) {
+		t.Fatalf("OpenExisting = %v, want ErrNoCache", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("OpenExisting created %s", dir)
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenExisting(dir); err != nil {
+		t.Fatalf("OpenExisting on an existing dir: %v", err)
+	}
+}
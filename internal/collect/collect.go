@@ -1,39 +1,107 @@
 package collect
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// Images walks the root directory recursively and returns supported image paths.
-func Images(root string) ([]string, error) {
-	var images []string
+// imageExt is the allowlist of extensions (case-insensitive).
+var imageExt = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".webp": true,
+	".gif":  true,
+	".bmp":  true,
+	".tif":  true,
+	".tiff": true,
+	".heic": true,
+	".heif": true,
+	".avif": true,
+}
+
+// skipExt lists extensions that are never images, so they are not opened
+// for sniffing; camera raw files are here because they look like TIFFs.
+var skipExt = map[string]bool{
+	".txt": true, ".json": true, ".xmp": true, ".aae": true, ".db": true,
+	".mp4": true, ".mov": true, ".m4v": true, ".avi": true, ".mkv": true, ".3gp": true,
+	".cr2": true, ".cr3": true, ".nef": true, ".arw": true, ".dng": true, ".orf": true, ".rw2": true, ".raf": true,
+}
 
-	// Allowlist of extensions (case-insensitive).
-	allowedExt := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".webp": true,
+// isImage decides by extension, sniffing the content of unknown ones.
+func isImage(path, name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if imageExt[ext] {
+		return true
+	}
+	if skipExt[ext] || strings.HasPrefix(name, ".") {
+		return false
+	}
+	return sniff(path)
+}
+
+// Images walks the root directory recursively and returns supported image
+// paths that pass the filter and the IgnoreFile found along the way. The walk
+// stops with ctx.Err() once ctx is cancelled.
+func Images(ctx context.Context, root string, f Filter) ([]string, error) {
+	include, err := parseRules(f.Include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include: %w", err)
+	}
+	exclude, err := parseRules(f.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude: %w", err)
 	}
 
+	var images []string
+	ignores := ignoreRules{}
+
 	// WalkDir avoids following symlinks and reports errors via callback.
-	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
 			log.Printf("warn: skipping %q: %v", path, err)
 			return nil
 		}
 
+		rel, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+
 		if d.IsDir() {
+			if rel != "." && (ignores.ignored(rel, true) || matches(exclude, rel, true)) {
+				return filepath.SkipDir
+			}
+			rules, err := readIgnoreFile(filepath.Join(path, IgnoreFile))
+			if err != nil {
+				log.Printf("warn: %s: %v", filepath.Join(path, IgnoreFile), err)
+			}
+			if len(rules) > 0 {
+				ignores[rel] = rules
+			}
 			return nil
 		}
 
-		ext := strings.ToLower(filepath.Ext(d.Name()))
-		if allowedExt[ext] {
-			images = append(images, path)
+		if ignores.ignored(rel, false) || matches(exclude, rel, false) {
+			return nil
+		}
+		if len(include) > 0 && !matches(include, rel, false) {
+			return nil
+		}
+		// Files with another or no extension are sniffed, so misnamed
+		// photos are still found.
+		if !isImage(path, d.Name()) {
+			return nil
 		}
+		images = append(images, path)
 		return nil
 	})
 
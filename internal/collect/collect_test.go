@@ -1,8 +1,10 @@
 package collect
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -30,7 +32,7 @@ func TestImages(t *testing.T) {
 	}
 
 	// Collect all images under root; should ignore unsupported files.
-	paths, err := Images(root)
+	paths, err := Images(context.Background(), root, Filter{})
 	if err != nil {
 		t.Fatalf("Images returned error: %v", err)
 	}
@@ -55,3 +57,51 @@ func TestImages(t *testing.T) {
 		}
 	}
 }
+
+func TestImagesStopsWhenCancelled(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.jpg"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Images(ctx, root, Filter{}); err != context.Canceled {
+		t.Fatalf("Images error = %v, want context.Canceled", err)
+	}
+}
+
+func TestImagesSniffsContent(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"IMG_0001":            "\x89PNG\r\n\x1a\n0000", // no extension
+		"b.jpg_original":      "\xff\xd8\xff\xe0000000",
+		"c.heic":              "\x00\x00\x00\x18ftypheic0000",
+		"d.tiff":              "II*\x00",
+		"e.gif":               "GIF89a",
+		"misnamed.dat":        "\x00\x00\x00\x18ftypavif0000",
+		"notes.md":            "# holiday",
+		"raw.cr2":             "II*\x00\x10\x00\x00\x00CR",
+		"unknown-tiff":        "II*\x00", // could be a raw file
+		"clip.bin":            "\x00\x00\x00\x18ftypisom0000",
+		".yearcollageignore2": "\xff\xd8\xff",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("write file %s: %v", name, err)
+		}
+	}
+
+	paths, err := Images(context.Background(), root, Filter{})
+	if err != nil {
+		t.Fatalf("Images returned error: %v", err)
+	}
+	var got []string
+	for _, p := range paths {
+		got = append(got, filepath.Base(p))
+	}
+	want := []string{"IMG_0001", "b.jpg_original", "c.heic", "d.tiff", "e.gif", "misnamed.dat"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Images() = %v, want %v", got, want)
+	}
+}
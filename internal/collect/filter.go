@@ -0,0 +1,194 @@
+package collect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFile is read in every directory during the walk. It uses gitignore
+// syntax: one pattern per line, # comments, ! to re-include, a trailing / for
+// directories only, and a leading or inner / to anchor the pattern to the
+// directory holding the file.
+const IgnoreFile = ".yearcollageignore"
+
+// Filter narrows down which files Images returns. Patterns follow the same
+// rules as IgnoreFile lines relative to the walk root: without a slash they
+// match a file or directory name at any depth, with a slash they match the
+// path from the root, and ** spans any number of directories. Within each
+// list the last matching pattern wins, so a leading ! takes back what an
+// earlier pattern matched.
+type Filter struct {
+	// Include, when non-empty, keeps only files matching one of its patterns.
+	Include []string
+	// Exclude drops files, and whole directories, matching any pattern.
+	Exclude []string
+}
+
+// rule is one parsed gitignore-style pattern.
+type rule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseRule parses a single pattern line.
+func parseRule(line string) (rule, error) {
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// \# and \! escape a literal leading character.
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.HasPrefix(line, "/") {
+		r.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+	}
+	if line == "" {
+		return r, errors.New("empty pattern")
+	}
+	for _, seg := range strings.Split(line, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return r, fmt.Errorf("pattern %q: %w", line, err)
+		}
+	}
+	r.pattern = line
+	return r, nil
+}
+
+// parseRules parses a list of patterns.
+func parseRules(patterns []string) ([]rule, error) {
+	rules := make([]rule, 0, len(patterns))
+	for _, p := range patterns {
+		r, err := parseRule(p)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// readIgnoreFile parses the ignore file at name; a missing file yields no
+// rules. Malformed lines are skipped with the line number in the error.
+func readIgnoreFile(name string) ([]rule, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []rule
+	var errs []error
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseRule(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+			continue
+		}
+		rules = append(rules, r)
+	}
+	if err := sc.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return rules, errors.Join(errs...)
+}
+
+// match reports whether the rule applies to rel, a slash-separated path
+// relative to the directory the rule belongs to.
+func (r rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		return globMatch(r.pattern, rel)
+	}
+	return globMatch(r.pattern, path.Base(rel))
+}
+
+// matches applies rules in order to rel, like the lines of an ignore file:
+// the last rule that matches decides, and a negated one undoes the earlier
+// matches. It reports false when no rule matches.
+func matches(rules []rule, rel string, isDir bool) bool {
+	matched := false
+	for _, r := range rules {
+		if r.match(rel, isDir) {
+			matched = !r.negate
+		}
+	}
+	return matched
+}
+
+// globMatch matches a slash-separated path against a pattern whose segments
+// use path.Match syntax, where a ** segment spans zero or more directories.
+func globMatch(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pat[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], parts[0]); !ok {
+			return false
+		}
+		pat, parts = pat[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// ignoreRules tracks the ignore files seen during a walk, keyed by the
+// slash-separated directory path relative to the root ("." for the root).
+type ignoreRules map[string][]rule
+
+// ignored applies every ignore file from the root down to rel's parent, in
+// order, so deeper files and later lines override earlier ones.
+func (ig ignoreRules) ignored(rel string, isDir bool) bool {
+	ignored := false
+	apply := func(dir, sub string) {
+		for _, r := range ig[dir] {
+			if r.match(sub, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	apply(".", rel)
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' {
+			apply(rel[:i], rel[i+1:])
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,151 @@
+package collect
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.jpg", "a.jpg", true},
+		{"*.jpg", "dir/a.jpg", false},
+		{"dir/*.jpg", "dir/a.jpg", true},
+		{"**/a.jpg", "a.jpg", true},
+		{"**/a.jpg", "x/y/a.jpg", true},
+		{"2025/**", "2025/06/a.jpg", true},
+		{"2025/**/*.png", "2025/a.png", true},
+		{"2025/**/*.png", "2025/06/01/a.png", true},
+		{"2025/**/*.png", "2024/06/a.png", false},
+		{"a/**/b/*.jpg", "a/x/y/b/c.jpg", true},
+		{"a/**/b/*.jpg", "a/x/y/c.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImagesFilter(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"2025/a.jpg",
+		"2025/Screenshot 1.png",
+		"2025/trip/b.jpg",
+		"2025/trip/c_thumb.jpg",
+		"2025/@eaDir/a.jpg",
+		"2024/d.jpg",
+	} {
+		writeFile(t, root, name, "x")
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"none", Filter{}, []string{
+			"2024/d.jpg", "2025/@eaDir/a.jpg", "2025/Screenshot 1.png", "2025/a.jpg", "2025/trip/b.jpg", "2025/trip/c_thumb.jpg",
+		}},
+		{"include subtree", Filter{Include: []string{"2025/**/*.jpg"}}, []string{
+			"2025/@eaDir/a.jpg", "2025/a.jpg", "2025/trip/b.jpg", "2025/trip/c_thumb.jpg",
+		}},
+		{"exclude names at any depth", Filter{Exclude: []string{"@eaDir", "Screenshot*", "*_thumb.jpg"}}, []string{
+			"2024/d.jpg", "2025/a.jpg", "2025/trip/b.jpg",
+		}},
+		{"include and exclude", Filter{Include: []string{"2025/**"}, Exclude: []string{"2025/trip/"}}, []string{
+			"2025/@eaDir/a.jpg", "2025/Screenshot 1.png", "2025/a.jpg",
+		}},
+		{"negated exclude re-includes", Filter{Exclude: []string{"*.jpg", "!2025/trip/*.jpg"}}, []string{
+			"2025/Screenshot 1.png", "2025/trip/b.jpg", "2025/trip/c_thumb.jpg",
+		}},
+		{"negated include drops", Filter{Include: []string{"2025/**", "!*_thumb.jpg"}}, []string{
+			"2025/@eaDir/a.jpg", "2025/Screenshot 1.png", "2025/a.jpg", "2025/trip/b.jpg",
+		}},
+		{"negation alone matches nothing", Filter{Exclude: []string{"!2025/a.jpg"}}, []string{
+			"2024/d.jpg", "2025/@eaDir/a.jpg", "2025/Screenshot 1.png", "2025/a.jpg", "2025/trip/b.jpg", "2025/trip/c_thumb.jpg",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := Images(context.Background(), root, tt.filter)
+			if err != nil {
+				t.Fatalf("Images: %v", err)
+			}
+			if got := relPaths(t, root, paths); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImagesRejectsBadPattern(t *testing.T) {
+	if _, err := Images(context.Background(), t.TempDir(), Filter{Exclude: []string{"[a-"}}); err == nil {
+		t.Fatal("expected an error for a malformed pattern")
+	}
+}
+
+func TestImagesHonoursIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"a.jpg",
+		"Screenshot-1.png",
+		"Screenshot-keep.png",
+		"@eaDir/a.jpg",
+		"trip/b.jpg",
+		"trip/skip.jpg",
+		"trip/raw/c.jpg",
+		"trip/deep/skip.jpg",
+		"other/skip.jpg",
+		"other/raw/e.jpg",
+	} {
+		writeFile(t, root, name, "x")
+	}
+	writeFile(t, root, IgnoreFile, "# NAS thumbnails\n@eaDir/\nScreenshot*\n!Screenshot-keep.png\n/other/raw\n")
+	// Unanchored patterns apply below the file; anchored ones only to its
+	// own directory. A deeper file can re-include what a parent ignored.
+	writeFile(t, root, "trip/"+IgnoreFile, "skip.jpg\n/raw/\n")
+	writeFile(t, root, "trip/deep/"+IgnoreFile, "!skip.jpg\n")
+
+	paths, err := Images(context.Background(), root, Filter{})
+	if err != nil {
+		t.Fatalf("Images: %v", err)
+	}
+	want := []string{"Screenshot-keep.png", "a.jpg", "other/skip.jpg", "trip/b.jpg", "trip/deep/skip.jpg"}
+	if got := relPaths(t, root, paths); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func writeFile(t *testing.T, root, name, data string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write file %s: %v", path, err)
+	}
+}
+
+// relPaths returns the paths relative to root with forward slashes, sorted.
+func relPaths(t *testing.T, root string, paths []string) []string {
+	t.Helper()
+	rel := make([]string, len(paths))
+	for i, p := range paths {
+		r, err := filepath.Rel(root, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel[i] = filepath.ToSlash(r)
+	}
+	sort.Strings(rel)
+	return rel
+}
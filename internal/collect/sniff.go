@@ -0,0 +1,48 @@
+package collect
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// sniffLen is how many leading bytes sniff reads.
+const sniffLen = 16
+
+// heifBrands are the ISO BMFF major brands of still HEIF and AVIF images;
+// videos use other brands and are not picked up.
+var heifBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1", "avif", "avis"}
+
+// sniff reports whether the file at path starts like an image this tool can
+// handle, for files whose extension does not say so. TIFF signatures are not
+// accepted here: camera raw files (CR2, NEF, DNG, ...) share them.
+func sniff(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, head)
+	return sniffImage(head[:n])
+}
+
+// sniffImage matches the magic bytes of JPEG, PNG, GIF, BMP, WebP and HEIF.
+func sniffImage(head []byte) bool {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xff, 0xd8, 0xff}),
+		bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")),
+		bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")),
+		len(head) >= 14 && bytes.HasPrefix(head, []byte("BM")) && head[6] == 0 && head[7] == 0 && head[8] == 0 && head[9] == 0,
+		len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WEBP":
+		return true
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		for _, brand := range heifBrands {
+			if string(head[8:12]) == brand {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+// Package heif registers HEIC and AVIF files with the image package. Their
+// HEVC and AV1 codecs have no pure Go decoder, so the embedded JPEG preview or
+// EXIF thumbnail is decoded instead; files without one fail with an error
+// that says so. Import it for its side effect:
+//
+//	import _ "github.com/luceast/yearcollage/internal/heif"
+package heif
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+)
+
+// ErrNoPreview is returned for HEIC/AVIF files without an embedded JPEG.
+var ErrNoPreview = errors.New("HEIC/AVIF has no embedded JPEG preview and cannot be decoded; convert it to JPEG first (e.g. heif-convert or sips)")
+
+// brands are the ISO BMFF major brands of still HEIF and AVIF images.
+var brands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1", "avif", "avis"}
+
+func init() {
+	for _, brand := range brands {
+		image.RegisterFormat("heif", "????ftyp"+brand, Decode, DecodeConfig)
+	}
+}
+
+// Decode returns the largest JPEG embedded in the HEIF file, turned by the
+// irot property like the image it stands in for. Only the meta box and the
+// data of JPEG and Exif items, located through iloc, are read.
+func Decode(r io.Reader) (image.Image, error) {
+	cr := &countingReader{r: r}
+	data, err := readMeta(cr)
+	if errors.Is(err, errNoSize) {
+		return nil, ErrNoPreview
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseMeta(data)
+	if err != nil {
+		return nil, err
+	}
+	ids := m.previewItems()
+	items, err := m.readItems(cr, cr.n, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var best []byte
+	bestPixels, turns := 0, 0
+	for _, id := range ids {
+		if jpg, pixels := largestJPEG(items[id]); pixels > bestPixels {
+			best, bestPixels, turns = jpg, pixels, m.rotationOf(id)
+		}
+	}
+	if best == nil {
+		return nil, ErrNoPreview
+	}
+	img, err := jpeg.Decode(bytes.NewReader(best))
+	if err != nil {
+		return nil, err
+	}
+	return rotate(img, turns), nil
+}
+
+// DecodeConfig returns the size of the primary image as recorded by its ispe
+// property, with width and height swapped when irot turns it a quarter. It
+// reads no further than the meta box, which sits before the pixel data in
+// practice, so probing a photo never loads all of it. Mirroring by imir
+// keeps the size and is ignored.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	data, err := readMeta(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	m, err := parseMeta(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	width, height, err := m.size()
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := color.YCbCrModel
+	if m.rotation() != 0 {
+		model = color.RGBAModel
+	}
+	return image.Config{ColorModel: model, Width: width, Height: height}, nil
+}
+
+// largestJPEG finds the embedded JPEG with the most pixels in data, trying
+// every start-of-image marker, and returns it from its marker on.
+func largestJPEG(data []byte) ([]byte, int) {
+	marker := []byte{0xff, 0xd8, 0xff}
+	best, bestPixels := -1, 0
+	for i := 0; ; i++ {
+		j := bytes.Index(data[i:], marker)
+		if j < 0 {
+			break
+		}
+		i += j
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data[i:]))
+		if err == nil && cfg.Width*cfg.Height > bestPixels {
+			best, bestPixels = i, cfg.Width*cfg.Height
+		}
+	}
+	if best < 0 {
+		return nil, 0
+	}
+	return data[best:], bestPixels
+}
+
+// rotate turns img anticlockwise by the given number of quarter turns.
+func rotate(img image.Image, turns int) image.Image {
+	if turns == 0 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, h, w))
+	if turns == 2 {
+		out = image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch turns {
+			case 1:
+				out.Set(y, w-1-x, c)
+			case 2:
+				out.Set(w-1-x, h-1-y, c)
+			case 3:
+				out.Set(h-1-y, x, c)
+			}
+		}
+	}
+	return out
+}
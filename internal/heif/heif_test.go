@@ -0,0 +1,202 @@
+package heif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"testing"
+)
+
+func jpegBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// halves encodes a w×h JPEG that is black on the left and white on the
+// right, so its orientation survives compression.
+func halves(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := w / 2; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// item is a non-primary item of a test file.
+type item struct {
+	typ  string
+	data []byte
+}
+
+// heifFile builds a HEIF file whose primary item 1 is a w×h HEVC image
+// turned by irot quarter turns, followed by the given items as IDs 2, 3, …
+// with their data in mdat.
+func heifFile(w, h uint32, irot byte, items ...item) []byte {
+	ftyp := []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	build := func(dataStart uint32) []byte {
+		infe := func(id uint16, typ string) []byte {
+			return mkbox("infe", []byte{2, 0, 0, 0}, binary.BigEndian.AppendUint16(nil, id), []byte{0, 0}, []byte(typ), []byte{0})
+		}
+		iinf := [][]byte{{0, 0, 0, 0}, binary.BigEndian.AppendUint16(nil, uint16(1+len(items))), infe(1, "hvc1")}
+		iloc := [][]byte{{0, 0, 0, 0, 0x44, 0x00}, binary.BigEndian.AppendUint16(nil, uint16(len(items)))}
+		off := dataStart
+		for i, it := range items {
+			iinf = append(iinf, infe(uint16(2+i), it.typ))
+			e := binary.BigEndian.AppendUint16(nil, uint16(2+i))
+			e = append(e, 0, 0, 0, 1)
+			e = binary.BigEndian.AppendUint32(e, off)
+			e = binary.BigEndian.AppendUint32(e, uint32(len(it.data)))
+			iloc = append(iloc, e)
+			off += uint32(len(it.data))
+		}
+		props, assoc := [][]byte{ispeBox(w, h)}, []byte{0x81}
+		if irot != 0 {
+			props, assoc = append(props, mkbox("irot", []byte{irot})), append(assoc, 0x82)
+		}
+		ipma := append([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 1, byte(len(assoc))}, assoc...)
+		return mkbox("meta", []byte{0, 0, 0, 0},
+			mkbox("pitm", []byte{0, 0, 0, 0, 0, 1}),
+			mkbox("iinf", iinf...),
+			mkbox("iloc", iloc...),
+			mkbox("iprp", mkbox("ipco", props...), mkbox("ipma", ipma)),
+		)
+	}
+	meta := build(0)
+	meta = build(uint32(len(ftyp) + len(meta) + 8))
+	var mdat [][]byte
+	for _, it := range items {
+		mdat = append(mdat, it.data)
+	}
+	return bytes.Join([][]byte{ftyp, meta, mkbox("mdat", mdat...)}, nil)
+}
+
+func TestDecodePicksLargestPreview(t *testing.T) {
+	// An Exif block with a thumbnail and a bigger JPEG preview item, as
+	// some cameras write.
+	exif := append([]byte("\x00\x00\x00\x06Exif\x00\x00junk"), jpegBytes(t, 16, 12)...)
+	file := heifFile(4000, 3000, 0, item{"Exif", exif}, item{"jpeg", jpegBytes(t, 64, 48)})
+
+	// Nothing past the last item is read.
+	img, format, err := image.Decode(io.MultiReader(bytes.NewReader(file), failReader{}))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if format != "heif" {
+		t.Fatalf("format = %q, want heif", format)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{64, 48}) {
+		t.Fatalf("decoded size = %v, want 64x48", got)
+	}
+}
+
+func TestDecodeTurnsRotatedImages(t *testing.T) {
+	// A portrait photo stored landscape with irot 90° anticlockwise, its
+	// Exif thumbnail stored the same way.
+	exif := append([]byte("\x00\x00\x00\x06Exif\x00\x00"), halves(t, 64, 48)...)
+	file := heifFile(4032, 3024, 1, item{"Exif", exif})
+
+	cfg, err := DecodeConfig(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("DecodeConfig: %v", err)
+	}
+	if cfg.Width != 3024 || cfg.Height != 4032 {
+		t.Fatalf("DecodeConfig = %dx%d, want 3024x4032", cfg.Width, cfg.Height)
+	}
+	img, err := Decode(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{48, 64}) {
+		t.Fatalf("decoded size = %v, want 48x64", got)
+	}
+	// Turning anticlockwise brings the white right half to the top.
+	top, _, _, _ := img.At(24, 8).RGBA()
+	bottom, _, _, _ := img.At(24, 56).RGBA()
+	if top < 0xc000 || bottom > 0x4000 {
+		t.Fatalf("top = %#x, bottom = %#x; want white over black", top, bottom)
+	}
+}
+
+func TestDecodeWithoutPreview(t *testing.T) {
+	file := heifFile(4032, 3024, 0)
+	if _, err := Decode(bytes.NewReader(file)); !errors.Is(err, ErrNoPreview) {
+		t.Fatalf("Decode = %v, want %v", err, ErrNoPreview)
+	}
+}
+
+// mkbox encodes an ISO BMFF box around the concatenated payloads.
+func mkbox(typ string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	return append(append(out, typ...), data...)
+}
+
+// ispeBox encodes an ispe property of w×h.
+func ispeBox(w, h uint32) []byte {
+	return mkbox("ispe", []byte{0, 0, 0, 0}, binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, w), h))
+}
+
+// failReader fails every read, standing in for pixel data that must not be
+// touched.
+type failReader struct{}
+
+func (failReader) Read([]byte) (int, error) { return 0, errors.New("read past the meta box") }
+
+func TestDecodeConfigReadsPrimaryISPE(t *testing.T) {
+	// Item 2 is a 160×120 thumbnail listed first; the primary item 1 has
+	// the second property.
+	meta := mkbox("meta", []byte{0, 0, 0, 0},
+		mkbox("pitm", []byte{0, 0, 0, 0, 0, 1}),
+		mkbox("iprp",
+			mkbox("ipco", ispeBox(160, 120), ispeBox(4032, 3024)),
+			mkbox("ipma", []byte{0, 0, 0, 0, 0, 0, 0, 2, 0, 2, 1, 0x81, 0, 1, 1, 0x82}),
+		),
+	)
+	var head bytes.Buffer
+	head.WriteString("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	head.Write(mkbox("free", make([]byte, 100)))
+	head.Write(meta)
+
+	cfg, format, err := image.DecodeConfig(io.MultiReader(&head, failReader{}))
+	if err != nil {
+		t.Fatalf("DecodeConfig: %v", err)
+	}
+	if format != "heif" || cfg.Width != 4032 || cfg.Height != 3024 {
+		t.Fatalf("DecodeConfig = %s %dx%d, want heif 4032x3024", format, cfg.Width, cfg.Height)
+	}
+}
+
+func TestDecodeConfigWithoutISPE(t *testing.T) {
+	var file bytes.Buffer
+	file.WriteString("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	file.Write(mkbox("meta", []byte{0, 0, 0, 0}, mkbox("pitm", []byte{0, 0, 0, 0, 0, 1})))
+	file.Write(jpegBytes(t, 16, 12))
+	if _, err := DecodeConfig(&file); !errors.Is(err, errNoSize) {
+		t.Fatalf("DecodeConfig = %v, want %v", err, errNoSize)
+	}
+}
+
+func TestDecodeConfigRejectsHugeMeta(t *testing.T) {
+	var file bytes.Buffer
+	file.WriteString("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	file.Write(binary.BigEndian.AppendUint32(nil, maxMetaSize+16))
+	file.WriteString("meta")
+	if _, err := DecodeConfig(io.MultiReader(&file, failReader{})); err == nil {
+		t.Fatalf("DecodeConfig read a meta box over %d bytes", maxMetaSize)
+	}
+}
@@ -0,0 +1,443 @@
+package heif
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// maxMetaSize caps the meta box read into memory. Item info and properties
+// take a few kilobytes; the pixels live in mdat.
+const maxMetaSize = 1 << 20
+
+// maxPreviewSize caps the bytes Decode reads for preview items.
+const maxPreviewSize = 64 << 20
+
+// errNoSize is returned when the meta box names no image size.
+var errNoSize = errors.New("heif: no ispe image size in meta box")
+
+// box is an ISO BMFF box: its type and payload.
+type box struct {
+	typ  string
+	data []byte
+}
+
+// extent is a run of item data, by absolute file offset, or by offset into
+// the meta box's idat when inIdat is set.
+type extent struct {
+	offset, length uint64
+	inIdat         bool
+}
+
+// meta is what the meta box says about the file's items.
+type meta struct {
+	primary uint32
+	props   []box
+	assoc   map[uint32][]int // item ID → 1-based property indexes
+	types   map[uint32]string
+	extents map[uint32][]extent
+	idat    []byte
+}
+
+// countingReader tracks how far into the file a reader has got.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readMeta reads top-level boxes until the meta box and returns its payload,
+// skipping everything before it without buffering. A file that ends first
+// reports errNoSize.
+func readMeta(r io.Reader) ([]byte, error) {
+	ended := func(err error) error {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return errNoSize
+		}
+		return err
+	}
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, ended(err)
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		typ := string(hdr[4:])
+		head := int64(8)
+		switch size {
+		case 0: // runs to the end of the file
+			if typ == "meta" {
+				return nil, fmt.Errorf("heif: meta box without size")
+			}
+			return nil, errNoSize
+		case 1:
+			var large [8]byte
+			if _, err := io.ReadFull(r, large[:]); err != nil {
+				return nil, ended(err)
+			}
+			size, head = int64(binary.BigEndian.Uint64(large[:])), 16
+		}
+		if size < head {
+			return nil, fmt.Errorf("heif: %q box of %d bytes", typ, size)
+		}
+		if typ != "meta" {
+			if _, err := io.CopyN(io.Discard, r, size-head); err != nil {
+				return nil, ended(err)
+			}
+			continue
+		}
+		if size-head > maxMetaSize {
+			return nil, fmt.Errorf("heif: meta box of %d bytes exceeds %d", size-head, maxMetaSize)
+		}
+		data := make([]byte, size-head)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("heif: truncated meta box: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// children splits a box payload into the boxes it contains.
+func children(data []byte) ([]box, error) {
+	var out []box
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("heif: truncated box header")
+		}
+		size := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		head := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, fmt.Errorf("heif: truncated box header")
+			}
+			size, head = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < head || size > uint64(len(data)) {
+			return nil, fmt.Errorf("heif: %q box of %d bytes in %d", typ, size, len(data))
+		}
+		out = append(out, box{typ: typ, data: data[head:size]})
+		data = data[size:]
+	}
+	return out, nil
+}
+
+// parseMeta reads the item properties, types and locations from a meta box
+// payload. Item boxes that are cut short keep what was read before the cut.
+func parseMeta(data []byte) (*meta, error) {
+	if len(data) < 4 {
+		return nil, errNoSize
+	}
+	boxes, err := children(data[4:]) // meta is a full box
+	if err != nil {
+		return nil, err
+	}
+
+	m := &meta{assoc: make(map[uint32][]int), types: make(map[uint32]string), extents: make(map[uint32][]extent)}
+	for _, b := range boxes {
+		switch b.typ {
+		case "pitm":
+			if len(b.data) >= 6 && b.data[0] == 0 {
+				m.primary = uint32(binary.BigEndian.Uint16(b.data[4:]))
+			} else if len(b.data) >= 8 {
+				m.primary = binary.BigEndian.Uint32(b.data[4:])
+			}
+		case "iprp":
+			inner, err := children(b.data)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range inner {
+				switch c.typ {
+				case "ipco":
+					if m.props, err = children(c.data); err != nil {
+						return nil, err
+					}
+				case "ipma":
+					parseIPMA(c.data, m.assoc)
+				}
+			}
+		case "iinf":
+			parseIINF(b.data, m.types)
+		case "iloc":
+			parseILOC(b.data, m.extents)
+		case "idat":
+			m.idat = b.data
+		}
+	}
+	return m, nil
+}
+
+// property returns the first property of the given type associated with
+// item.
+func (m *meta) property(item uint32, typ string) (box, bool) {
+	for _, idx := range m.assoc[item] {
+		if idx >= 1 && idx <= len(m.props) && m.props[idx-1].typ == typ {
+			return m.props[idx-1], true
+		}
+	}
+	return box{}, false
+}
+
+// rotation is the primary image's irot angle in quarter turns
+// anticlockwise, 0 to 3.
+func (m *meta) rotation() int {
+	if p, ok := m.property(m.primary, "irot"); ok && len(p.data) >= 1 {
+		return int(p.data[0] & 3)
+	}
+	return 0
+}
+
+// rotationOf is the turn a preview item needs to show like the primary
+// image: a JPEG item's own irot, or the primary's for the Exif thumbnail,
+// which is stored as coded.
+func (m *meta) rotationOf(id uint32) int {
+	if m.types[id] == "jpeg" {
+		if p, ok := m.property(id, "irot"); ok && len(p.data) >= 1 {
+			return int(p.data[0] & 3)
+		}
+		return 0
+	}
+	return m.rotation()
+}
+
+// size returns the displayed width and height of the primary image: its ispe
+// property, turned by irot. Files whose primary item cannot be resolved
+// report the largest ispe instead.
+func (m *meta) size() (width, height int, err error) {
+	ispe := func(b box) (int, int, bool) {
+		if b.typ != "ispe" || len(b.data) < 12 {
+			return 0, 0, false
+		}
+		w, h := binary.BigEndian.Uint32(b.data[4:]), binary.BigEndian.Uint32(b.data[8:])
+		return int(w), int(h), w > 0 && h > 0 && w <= 1<<30 && h <= 1<<30
+	}
+	if p, ok := m.property(m.primary, "ispe"); ok {
+		if w, h, ok := ispe(p); ok {
+			if m.rotation()%2 == 1 {
+				w, h = h, w
+			}
+			return w, h, nil
+		}
+	}
+	for _, p := range m.props {
+		if w, h, ok := ispe(p); ok && w*h > width*height {
+			width, height = w, h
+		}
+	}
+	if width == 0 {
+		return 0, 0, errNoSize
+	}
+	return width, height, nil
+}
+
+// previewItems returns the IDs of items that can hold a JPEG: JPEG-coded
+// images and the Exif block with its thumbnail, in ID order.
+func (m *meta) previewItems() []uint32 {
+	var ids []uint32
+	for id, typ := range m.types {
+		if typ == "jpeg" || typ == "Exif" {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// readItems reads the data of the given items from r, which has been read
+// up to offset pos. File extents are visited in offset order, skipping the
+// bytes between them, so only item data is held in memory. Extents before
+// pos cannot be reached without seeking and leave their item short.
+func (m *meta) readItems(r io.Reader, pos int64, ids []uint32) (map[uint32][]byte, error) {
+	type part struct {
+		id uint32
+		extent
+		data []byte
+	}
+	var parts []*part
+	var total uint64
+	for _, id := range ids {
+		for _, e := range m.extents[id] {
+			parts = append(parts, &part{id: id, extent: e})
+			total += e.length
+		}
+	}
+	if total > maxPreviewSize {
+		return nil, fmt.Errorf("heif: preview items of %d bytes exceed %d", total, maxPreviewSize)
+	}
+
+	byOffset := slices.Clone(parts)
+	slices.SortStableFunc(byOffset, func(a, b *part) int { return cmp.Compare(a.offset, b.offset) })
+	for _, p := range byOffset {
+		if p.inIdat {
+			if p.offset+p.length <= uint64(len(m.idat)) {
+				p.data = m.idat[p.offset : p.offset+p.length]
+			}
+			continue
+		}
+		if p.offset < uint64(pos) {
+			continue
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(p.offset)-pos); err != nil {
+			break
+		}
+		p.data = make([]byte, p.length)
+		n, err := io.ReadFull(r, p.data)
+		p.data, pos = p.data[:n], int64(p.offset)+int64(n)
+		if err != nil {
+			break
+		}
+	}
+
+	out := make(map[uint32][]byte, len(ids))
+	for _, p := range parts {
+		out[p.id] = append(out[p.id], p.data...)
+	}
+	return out, nil
+}
+
+// parseIPMA adds the item property associations of an ipma payload to assoc.
+func parseIPMA(data []byte, assoc map[uint32][]int) {
+	if len(data) < 8 {
+		return
+	}
+	version, flags := data[0], data[3]
+	count := binary.BigEndian.Uint32(data[4:])
+	data = data[8:]
+	for range count {
+		var item uint32
+		if version < 1 {
+			if len(data) < 3 {
+				return
+			}
+			item, data = uint32(binary.BigEndian.Uint16(data)), data[2:]
+		} else {
+			if len(data) < 5 {
+				return
+			}
+			item, data = binary.BigEndian.Uint32(data), data[4:]
+		}
+		n := int(data[0])
+		data = data[1:]
+		for range n {
+			var idx int
+			if flags&1 != 0 {
+				if len(data) < 2 {
+					return
+				}
+				idx, data = int(binary.BigEndian.Uint16(data)&0x7fff), data[2:]
+			} else {
+				if len(data) < 1 {
+					return
+				}
+				idx, data = int(data[0]&0x7f), data[1:]
+			}
+			assoc[item] = append(assoc[item], idx)
+		}
+	}
+}
+
+// parseIINF records the type of every item entry in an iinf payload. Entries
+// before version 2 carry no type and are left out.
+func parseIINF(data []byte, types map[uint32]string) {
+	if len(data) < 6 {
+		return
+	}
+	head := 6
+	if data[0] != 0 {
+		head = 8
+	}
+	if len(data) < head {
+		return
+	}
+	entries, err := children(data[head:])
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		d := e.data
+		if e.typ != "infe" || len(d) < 4 {
+			continue
+		}
+		switch d[0] {
+		case 2:
+			if len(d) >= 12 {
+				types[uint32(binary.BigEndian.Uint16(d[4:]))] = string(d[8:12])
+			}
+		case 3:
+			if len(d) >= 14 {
+				types[binary.BigEndian.Uint32(d[4:])] = string(d[10:14])
+			}
+		}
+	}
+}
+
+// parseILOC records the extents of every item in an iloc payload that lives
+// in the file or in idat.
+func parseILOC(data []byte, extents map[uint32][]extent) {
+	if len(data) < 6 {
+		return
+	}
+	version := data[0]
+	offsetSize, lengthSize := int(data[4]>>4), int(data[4]&15)
+	baseSize, indexSize := int(data[5]>>4), 0
+	if version == 1 || version == 2 {
+		indexSize = int(data[5] & 15)
+	}
+	data = data[6:]
+
+	// next reads an unsigned big-endian field of 0, 2, 4 or 8 bytes.
+	ok := true
+	next := func(size int) uint64 {
+		if len(data) < size {
+			ok = false
+			return 0
+		}
+		var v uint64
+		switch size {
+		case 2:
+			v = uint64(binary.BigEndian.Uint16(data))
+		case 4:
+			v = uint64(binary.BigEndian.Uint32(data))
+		case 8:
+			v = binary.BigEndian.Uint64(data)
+		}
+		data = data[size:]
+		return v
+	}
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	count := next(idSize)
+	for i := uint64(0); ok && i < count; i++ {
+		id := uint32(next(idSize))
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			method = next(2) & 15
+		}
+		next(2) // data_reference_index
+		base := next(baseSize)
+		n := next(2)
+		var es []extent
+		for j := uint64(0); ok && j < n; j++ {
+			next(indexSize)
+			off, length := next(offsetSize), next(lengthSize)
+			es = append(es, extent{offset: base + off, length: length, inIdat: method == 1})
+		}
+		if ok && method <= 1 {
+			extents[id] = es
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package icc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/luceast/yearcollage/internal/pngchunk"
+)
+
+// maxProfileSize bounds the inflated size of a PNG iCCP profile.
+const maxProfileSize = 4 << 20
+
+var (
+	jpegMagic    = []byte{0xff, 0xd8}
+	pngMagic     = []byte("\x89PNG\r\n\x1a\n")
+	iccSignature = []byte("ICC_PROFILE\x00")
+)
+
+// Extract returns the ICC profile embedded in a JPEG (APP2 segments) or PNG
+// (iCCP chunk) stream. It returns nil without an error when the stream has
+// no profile or is another format. Only the headers are read.
+func Extract(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(8)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, jpegMagic):
+		return extractJPEG(br)
+	case bytes.HasPrefix(magic, pngMagic):
+		return extractPNG(br)
+	default:
+		return nil, nil
+	}
+}
+
+// extractJPEG walks the marker segments up to the first scan and joins the
+// ICC_PROFILE APP2 chunks in sequence order.
+func extractJPEG(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(2); err != nil {
+		return nil, err
+	}
+	var chunks [][]byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != 0xff {
+			return nil, errors.New("icc: malformed jpeg marker")
+		}
+		marker, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case marker == 0xff: // fill byte
+			if err := r.UnreadByte(); err != nil {
+				return nil, err
+			}
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			continue // standalone markers
+		case marker == 0xd9 || marker == 0xda: // end of image, start of scan
+			return joinChunks(chunks)
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, err
+		}
+		n := int(binary.BigEndian.Uint16(length[:])) - 2
+		if n < 0 {
+			return nil, errors.New("icc: malformed jpeg segment length")
+		}
+		if marker != 0xe2 {
+			if _, err := r.Discard(n); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+		if len(payload) > len(iccSignature)+2 && bytes.HasPrefix(payload, iccSignature) {
+			seq, count := int(payload[len(iccSignature)]), int(payload[len(iccSignature)+1])
+			if seq < 1 || seq > count {
+				return nil, fmt.Errorf("icc: profile chunk %d of %d", seq, count)
+			}
+			if chunks == nil {
+				chunks = make([][]byte, count)
+			}
+			if count != len(chunks) {
+				return nil, errors.New("icc: inconsistent profile chunk count")
+			}
+			chunks[seq-1] = payload[len(iccSignature)+2:]
+		}
+	}
+}
+
+func joinChunks(chunks [][]byte) ([]byte, error) {
+	if chunks == nil {
+		return nil, nil
+	}
+	var profile []byte
+	for i, c := range chunks {
+		if c == nil {
+			return nil, fmt.Errorf("icc: profile chunk %d of %d missing", i+1, len(chunks))
+		}
+		profile = append(profile, c...)
+	}
+	return profile, nil
+}
+
+// extractPNG walks the chunks before the image data and inflates iCCP.
+func extractPNG(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(len(pngMagic)); err != nil {
+		return nil, err
+	}
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, err
+		}
+		n := int64(binary.BigEndian.Uint32(head[:4]))
+		switch string(head[4:]) {
+		case "IDAT", "IEND":
+			return nil, nil
+		case "iCCP":
+			if n > maxProfileSize {
+				return nil, errors.New("icc: iCCP chunk too large")
+			}
+			data := make([]byte, n)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+			// Keyword, NUL, compression method 0 (zlib), compressed profile.
+			nul := bytes.IndexByte(data, 0)
+			if nul < 0 || nul+2 > len(data) || data[nul+1] != 0 {
+				return nil, errors.New("icc: malformed iCCP chunk")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(data[nul+2:]))
+			if err != nil {
+				return nil, fmt.Errorf("icc: iCCP: %w", err)
+			}
+			defer zr.Close()
+			profile, err := io.ReadAll(io.LimitReader(zr, maxProfileSize))
+			if err != nil {
+				return nil, fmt.Errorf("icc: iCCP: %w", err)
+			}
+			return profile, nil
+		}
+		if _, err := io.CopyN(io.Discard, r, n+4); err != nil { // data and CRC
+			return nil, err
+		}
+	}
+}
+
+// maxSegmentData is the profile payload that fits in one APP2 segment.
+const maxSegmentData = 0xffff - 2 - 14
+
+// JPEGWriter returns a writer that passes a JPEG stream through to w,
+// inserting profile as ICC_PROFILE APP2 segments right after the SOI marker.
+func JPEGWriter(w io.Writer, profile []byte) io.Writer {
+	count := (len(profile) + maxSegmentData - 1) / maxSegmentData
+	var segs []byte
+	for i := 0; i < count; i++ {
+		chunk := profile[i*maxSegmentData : min((i+1)*maxSegmentData, len(profile))]
+		segs = append(segs, 0xff, 0xe2)
+		segs = binary.BigEndian.AppendUint16(segs, uint16(2+len(iccSignature)+2+len(chunk)))
+		segs = append(segs, iccSignature...)
+		segs = append(segs, byte(i+1), byte(count))
+		segs = append(segs, chunk...)
+	}
+	return pngchunk.Insert(w, len(jpegMagic), segs)
+}
+
+// PNGWriter returns a writer that passes a PNG stream through to w,
+// inserting profile as an iCCP chunk right after the IHDR chunk.
+func PNGWriter(w io.Writer, profile []byte) io.Writer {
+	var data bytes.Buffer
+	data.WriteString("ICC profile\x00\x00")
+	zw := zlib.NewWriter(&data)
+	zw.Write(profile) // writes to a bytes.Buffer cannot fail
+	zw.Close()
+	return pngchunk.Writer(w, pngchunk.Encode("iCCP", data.Bytes()))
+}
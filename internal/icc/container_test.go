@@ -0,0 +1,82 @@
+package icc
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestEmbedAndExtract(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	large := bytes.Repeat([]byte("profile"), 20000) // spans three APP2 segments
+
+	cases := []struct {
+		name    string
+		profile []byte
+		encode  func(*bytes.Buffer, []byte) error
+		decode  func(*bytes.Buffer) error
+	}{
+		{"jpeg", SRGB(), encodeJPEG(img), decodeJPEG},
+		{"jpeg multi-segment", large, encodeJPEG(img), decodeJPEG},
+		{"png", SRGB(), encodePNG(img), decodePNG},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tc.encode(&buf, tc.profile); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			got, err := Extract(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if !bytes.Equal(got, tc.profile) {
+				t.Fatalf("Extract returned %d bytes, want the %d embedded", len(got), len(tc.profile))
+			}
+			if err := tc.decode(&buf); err != nil {
+				t.Fatalf("decode with embedded profile: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractWithoutProfile(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var j, p bytes.Buffer
+	if err := jpeg.Encode(&j, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&p, img); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"jpeg": j.Bytes(), "png": p.Bytes(), "other": []byte("GIF89a")} {
+		got, err := Extract(bytes.NewReader(data))
+		if err != nil || got != nil {
+			t.Fatalf("%s: Extract = %d bytes, %v; want nil, nil", name, len(got), err)
+		}
+	}
+}
+
+func encodeJPEG(img image.Image) func(*bytes.Buffer, []byte) error {
+	return func(buf *bytes.Buffer, profile []byte) error {
+		return jpeg.Encode(JPEGWriter(buf, profile), img, nil)
+	}
+}
+
+func encodePNG(img image.Image) func(*bytes.Buffer, []byte) error {
+	return func(buf *bytes.Buffer, profile []byte) error {
+		return png.Encode(PNGWriter(buf, profile), img)
+	}
+}
+
+func decodeJPEG(buf *bytes.Buffer) error {
+	_, err := jpeg.Decode(buf)
+	return err
+}
+
+func decodePNG(buf *bytes.Buffer) error {
+	_, err := png.Decode(buf)
+	return err
+}
@@ -0,0 +1,227 @@
+// Package icc reads the ICC colour profiles embedded in JPEG and PNG files,
+// converts pixels from matrix/TRC RGB profiles (Adobe RGB, Display P3,
+// ProPhoto and most camera profiles) to sRGB, and embeds an sRGB profile in
+// encoded output. LUT-based profiles are not supported.
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrUnsupported is returned for profiles that are not matrix/TRC RGB
+// profiles, such as CMYK or LUT-based ones.
+var ErrUnsupported = errors.New("icc: only matrix/TRC RGB profiles are supported")
+
+const headerSize = 128
+
+// Profile is a parsed matrix/TRC RGB profile.
+type Profile struct {
+	// Description is the profile's name, e.g. "Adobe RGB (1998)".
+	Description string
+	// colorants holds the XYZ (D50) of the red, green and blue primaries
+	// as the columns of a matrix.
+	colorants [3][3]float64
+	trc       [3]curve
+}
+
+// Parse reads an ICC profile.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < headerSize+4 {
+		return nil, errors.New("icc: profile too short")
+	}
+	if string(data[36:40]) != "acsp" {
+		return nil, errors.New("icc: missing profile signature")
+	}
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, fmt.Errorf("%w (colour space %q, connection space %q)", ErrUnsupported, data[16:20], data[20:24])
+	}
+
+	tags := make(map[string][]byte)
+	count := int(binary.BigEndian.Uint32(data[headerSize:]))
+	if count > (len(data)-headerSize-4)/12 {
+		return nil, errors.New("icc: tag table exceeds profile")
+	}
+	for i := 0; i < count; i++ {
+		e := data[headerSize+4+12*i:]
+		sig := string(e[:4])
+		off, size := binary.BigEndian.Uint32(e[4:]), binary.BigEndian.Uint32(e[8:])
+		if uint64(off)+uint64(size) > uint64(len(data)) || size < 8 {
+			return nil, fmt.Errorf("icc: tag %q exceeds profile", sig)
+		}
+		tags[sig] = data[off : off+size]
+	}
+
+	p := &Profile{Description: description(tags["desc"])}
+	for c, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := parseXYZ(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrUnsupported, sig, err)
+		}
+		for r := range xyz {
+			p.colorants[r][c] = xyz[r]
+		}
+	}
+	for c, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		trc, err := parseCurve(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrUnsupported, sig, err)
+		}
+		p.trc[c] = trc
+	}
+	return p, nil
+}
+
+// IsSRGB reports whether the profile describes sRGB closely enough that
+// converting would not change any 8-bit value noticeably.
+func (p *Profile) IsSRGB() bool {
+	for r := range p.colorants {
+		for c := range p.colorants[r] {
+			if math.Abs(p.colorants[r][c]-srgbColorants[r][c]) > 0.002 {
+				return false
+			}
+		}
+	}
+	for _, trc := range p.trc {
+		for _, x := range []float64{0.02, 0.1, 0.25, 0.5, 0.75, 0.9} {
+			if math.Abs(trc.eval(x)-srgbDecode(x)) > 0.002 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// parseXYZ reads an XYZType tag.
+func parseXYZ(b []byte) ([3]float64, error) {
+	if len(b) < 20 || string(b[:4]) != "XYZ " {
+		return [3]float64{}, errors.New("missing or not an XYZ tag")
+	}
+	return [3]float64{s15Fixed16(b[8:]), s15Fixed16(b[12:]), s15Fixed16(b[16:])}, nil
+}
+
+// curve is a tone reproduction curve mapping encoded values in [0, 1] to
+// linear light.
+type curve struct {
+	table  []float64  // sampled curve, when set
+	gamma  float64    // plain power law otherwise
+	kind   int        // parametric function type, -1 for table or gamma
+	params [7]float64 // g, a, b, c, d, e, f
+}
+
+// parseCurve reads a curveType or parametricCurveType tag.
+func parseCurve(b []byte) (curve, error) {
+	if len(b) < 12 {
+		return curve{}, errors.New("missing curve tag")
+	}
+	switch string(b[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(b[8:]))
+		if len(b) < 12+2*n {
+			return curve{}, errors.New("curve table exceeds tag")
+		}
+		switch n {
+		case 0:
+			return curve{gamma: 1, kind: -1}, nil
+		case 1:
+			return curve{gamma: float64(binary.BigEndian.Uint16(b[12:])) / 256, kind: -1}, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(b[12+2*i:])) / 0xffff
+		}
+		return curve{table: table, kind: -1}, nil
+	case "para":
+		kind := int(binary.BigEndian.Uint16(b[8:]))
+		nparams := []int{1, 3, 4, 5, 7}
+		if kind >= len(nparams) {
+			return curve{}, fmt.Errorf("unknown parametric curve type %d", kind)
+		}
+		if len(b) < 12+4*nparams[kind] {
+			return curve{}, errors.New("parametric curve exceeds tag")
+		}
+		c := curve{kind: kind}
+		for i := 0; i < nparams[kind]; i++ {
+			c.params[i] = s15Fixed16(b[12+4*i:])
+		}
+		return c, nil
+	default:
+		return curve{}, fmt.Errorf("unsupported curve type %q", b[:4])
+	}
+}
+
+// eval maps an encoded value in [0, 1] to linear light.
+func (c curve) eval(x float64) float64 {
+	if c.table != nil {
+		pos := x * float64(len(c.table)-1)
+		i := min(int(pos), len(c.table)-2)
+		return c.table[i] + (pos-float64(i))*(c.table[i+1]-c.table[i])
+	}
+	g, a, b, cc, d, e, f := c.params[0], c.params[1], c.params[2], c.params[3], c.params[4], c.params[5], c.params[6]
+	pow := func(v float64) float64 { return math.Pow(max(v, 0), g) }
+	switch c.kind {
+	case 0:
+		return pow(x)
+	case 1:
+		if x >= -b/a {
+			return pow(a*x + b)
+		}
+		return 0
+	case 2:
+		if x >= -b/a {
+			return pow(a*x+b) + cc
+		}
+		return cc
+	case 3:
+		if x >= d {
+			return pow(a*x + b)
+		}
+		return cc * x
+	case 4:
+		if x >= d {
+			return pow(a*x+b) + e
+		}
+		return cc*x + f
+	default:
+		return math.Pow(x, c.gamma)
+	}
+}
+
+// description reads a v2 textDescriptionType or v4 multiLocalizedUnicodeType
+// tag, returning the first record of the latter.
+func description(b []byte) string {
+	if len(b) < 12 {
+		return ""
+	}
+	switch string(b[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(b[8:]))
+		if n == 0 || len(b) < 12+n {
+			return ""
+		}
+		return strings.TrimRight(string(b[12:12+n]), "\x00")
+	case "mluc":
+		if binary.BigEndian.Uint32(b[8:]) == 0 || len(b) < 28 {
+			return ""
+		}
+		n, off := int(binary.BigEndian.Uint32(b[20:])), int(binary.BigEndian.Uint32(b[24:]))
+		if off+n > len(b) {
+			return ""
+		}
+		units := make([]uint16, n/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[off+2*i:])
+		}
+		return string(utf16.Decode(units))
+	default:
+		return ""
+	}
+}
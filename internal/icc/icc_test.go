@@ -0,0 +1,169 @@
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// adobeRGB builds an Adobe RGB (1998) profile: its published D50 primaries
+// and a 563/256 gamma stored as a one-entry curve.
+func adobeRGB() []byte {
+	colorants := matrix{
+		{0.60974, 0.20528, 0.14919},
+		{0.31111, 0.62567, 0.06322},
+		{0.01947, 0.06087, 0.74457},
+	}
+	return buildProfile("Adobe RGB (1998)", colorants, curveTag([]uint16{563}))
+}
+
+// paraTag encodes a parametricCurveType.
+func paraTag(kind int, params ...float64) []byte {
+	b := make([]byte, 12, 12+4*len(params))
+	copy(b, "para")
+	binary.BigEndian.PutUint16(b[8:], uint16(kind))
+	for _, p := range params {
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(p*65536))))
+	}
+	return b
+}
+
+func TestParseSRGB(t *testing.T) {
+	p, err := Parse(SRGB())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if p.Description != srgbDescription {
+		t.Fatalf("Description = %q, want %q", p.Description, srgbDescription)
+	}
+	if !p.IsSRGB() {
+		t.Fatal("IsSRGB = false for our own sRGB profile")
+	}
+
+	// A v4-style sRGB profile with a parametric curve is recognised too.
+	v4, err := Parse(buildProfile("sRGB v4", srgbColorants, paraTag(3, 2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045)))
+	if err != nil {
+		t.Fatalf("Parse v4: %v", err)
+	}
+	if !v4.IsSRGB() {
+		t.Fatal("IsSRGB = false for a parametric sRGB profile")
+	}
+
+	adobe, err := Parse(adobeRGB())
+	if err != nil {
+		t.Fatalf("Parse Adobe RGB: %v", err)
+	}
+	if adobe.IsSRGB() {
+		t.Fatal("IsSRGB = true for Adobe RGB")
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	cmyk := append([]byte(nil), SRGB()...)
+	copy(cmyk[16:], "CMYK")
+	noMatrix := buildProfile("LUT only", srgbColorants, curveTag(nil))
+	copy(noMatrix[headerSize+4+12*3:], "A2B0") // rename rXYZ
+
+	cases := []struct {
+		name        string
+		data        []byte
+		unsupported bool
+	}{
+		{"truncated", SRGB()[:100], false},
+		{"not a profile", make([]byte, 200), false},
+		{"cmyk", cmyk, true},
+		{"no matrix", noMatrix, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.data)
+			if err == nil {
+				t.Fatal("Parse succeeded, want an error")
+			}
+			if got := errors.Is(err, ErrUnsupported); got != tc.unsupported {
+				t.Fatalf("errors.Is(%v, ErrUnsupported) = %v, want %v", err, got, tc.unsupported)
+			}
+		})
+	}
+}
+
+func TestParametricCurves(t *testing.T) {
+	cases := []struct {
+		tag  []byte
+		x    float64
+		want float64
+	}{
+		{paraTag(0, 2), 0.5, 0.25},
+		{paraTag(1, 1, 2, -0.5), 0.2, 0},
+		{paraTag(1, 1, 2, -0.5), 0.75, 1},
+		{paraTag(2, 1, 1, 0, 0.25), 0.5, 0.75},
+		{paraTag(3, 1, 1, 0, 0.5, 0.4), 0.2, 0.1},
+		{paraTag(4, 1, 1, 0, 0.5, 0.4, 0.1, 0.05), 0.2, 0.15},
+		{paraTag(4, 1, 1, 0, 0.5, 0.4, 0.1, 0.05), 0.6, 0.7},
+		{curveTag(nil), 0.3, 0.3},
+		{curveTag([]uint16{0, 0x8000, 0xffff}), 0.25, 0.25},
+	}
+	for i, tc := range cases {
+		c, err := parseCurve(tc.tag)
+		if err != nil {
+			t.Fatalf("case %d: parseCurve: %v", i, err)
+		}
+		if got := c.eval(tc.x); math.Abs(got-tc.want) > 1e-3 {
+			t.Fatalf("case %d: eval(%v) = %v, want %v", i, tc.x, got, tc.want)
+		}
+	}
+}
+
+func TestTransformAdobeRGB(t *testing.T) {
+	p, err := Parse(adobeRGB())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tr := p.ToSRGB()
+
+	// Expected values from an independent float implementation of the same
+	// Bradford-adapted matrix conversion.
+	cases := []struct{ in, want color.RGBA }{
+		{color.RGBA{200, 60, 60, 0xff}, color.RGBA{231, 57, 57, 0xff}},
+		{color.RGBA{128, 128, 128, 0xff}, color.RGBA{129, 129, 129, 0xff}},
+		{color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{color.RGBA{40, 180, 90, 0xff}, color.RGBA{0, 181, 83, 0xff}}, // red clipped to the gamut
+	}
+	img := image.NewRGBA(image.Rect(0, 0, len(cases), 1))
+	for i, tc := range cases {
+		img.SetRGBA(i, 0, tc.in)
+	}
+	out := tr.Apply(img)
+	for i, tc := range cases {
+		got := out.RGBAAt(i, 0)
+		if diff(got.R, tc.want.R) > 1 || diff(got.G, tc.want.G) > 1 || diff(got.B, tc.want.B) > 1 || got.A != 0xff {
+			t.Fatalf("%v: got %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTransformSRGBIsIdentity(t *testing.T) {
+	p, err := Parse(SRGB())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	// A non-RGBA input is copied, leaving img intact for comparison.
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 1))
+	for x := 0; x < 256; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{uint8(x), uint8(255 - x), uint8(x / 2), 0xff})
+	}
+	out := p.ToSRGB().Apply(img)
+	for x := 0; x < 256; x++ {
+		want, got := img.NRGBAAt(x, 0), out.RGBAAt(x, 0)
+		if diff(got.R, want.R) > 1 || diff(got.G, want.G) > 1 || diff(got.B, want.B) > 1 {
+			t.Fatalf("x=%d: got %v, want %v", x, got, want)
+		}
+	}
+}
+
+func diff(a, b uint8) int {
+	return int(math.Abs(float64(a) - float64(b)))
+}
@@ -0,0 +1,108 @@
+package icc
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// srgbDescription names the profile SRGB returns.
+const srgbDescription = "sRGB IEC61966-2.1"
+
+// SRGB returns a compact ICC v2 sRGB profile for embedding in output files.
+// The slice is shared and must not be modified.
+var SRGB = sync.OnceValue(func() []byte {
+	table := make([]uint16, 1024)
+	for i := range table {
+		table[i] = uint16(math.Round(srgbDecode(float64(i)/float64(len(table)-1)) * 0xffff))
+	}
+	return buildProfile(srgbDescription, srgbColorants, curveTag(table))
+})
+
+// buildProfile assembles a v2 display profile with the given primaries and
+// one tone curve tag shared by all three channels.
+func buildProfile(desc string, colorants matrix, trc []byte) []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	tags := []tag{
+		{"desc", descTag(desc)},
+		{"cprt", textTag("No copyright, use freely")},
+		{"wtpt", xyzTag(d50)},
+		{"rXYZ", xyzTag([3]float64{colorants[0][0], colorants[1][0], colorants[2][0]})},
+		{"gXYZ", xyzTag([3]float64{colorants[0][1], colorants[1][1], colorants[2][1]})},
+		{"bXYZ", xyzTag([3]float64{colorants[0][2], colorants[1][2], colorants[2][2]})},
+		{"rTRC", trc},
+		{"gTRC", nil}, // nil shares the previous tag's data
+		{"bTRC", nil},
+	}
+
+	p := make([]byte, headerSize+4+12*len(tags))
+	binary.BigEndian.PutUint32(p[8:], 0x02100000) // version 2.1
+	copy(p[12:], "mntr")
+	copy(p[16:], "RGB ")
+	copy(p[20:], "XYZ ")
+	copy(p[36:], "acsp")
+	putXYZ(p[68:], d50) // connection space illuminant
+	binary.BigEndian.PutUint32(p[headerSize:], uint32(len(tags)))
+
+	var off, size int
+	for i, t := range tags {
+		if t.data != nil {
+			for len(p)%4 != 0 {
+				p = append(p, 0)
+			}
+			off, size = len(p), len(t.data)
+			p = append(p, t.data...)
+		}
+		e := p[headerSize+4+12*i:]
+		copy(e, t.sig)
+		binary.BigEndian.PutUint32(e[4:], uint32(off))
+		binary.BigEndian.PutUint32(e[8:], uint32(size))
+	}
+	binary.BigEndian.PutUint32(p, uint32(len(p)))
+	return p
+}
+
+func putXYZ(b []byte, xyz [3]float64) {
+	for i, v := range xyz {
+		binary.BigEndian.PutUint32(b[4*i:], uint32(int32(math.Round(v*65536))))
+	}
+}
+
+func xyzTag(xyz [3]float64) []byte {
+	b := make([]byte, 20)
+	copy(b, "XYZ ")
+	putXYZ(b[8:], xyz)
+	return b
+}
+
+// curveTag encodes a sampled curveType.
+func curveTag(table []uint16) []byte {
+	b := make([]byte, 12+2*len(table))
+	copy(b, "curv")
+	binary.BigEndian.PutUint32(b[8:], uint32(len(table)))
+	for i, v := range table {
+		binary.BigEndian.PutUint16(b[12+2*i:], v)
+	}
+	return b
+}
+
+// descTag encodes a v2 textDescriptionType with empty Unicode and
+// ScriptCode parts.
+func descTag(s string) []byte {
+	b := make([]byte, 12, 12+len(s)+1+8+3+67)
+	copy(b, "desc")
+	binary.BigEndian.PutUint32(b[8:], uint32(len(s)+1))
+	b = append(b, s...)
+	b = append(b, 0)
+	return append(b, make([]byte, 8+3+67)...)
+}
+
+func textTag(s string) []byte {
+	b := make([]byte, 8, 8+len(s)+1)
+	copy(b, "text")
+	b = append(b, s...)
+	return append(b, 0)
+}
@@ -0,0 +1,183 @@
+package icc
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"sync"
+)
+
+// outSteps is the resolution of the linear-light lookup that re-encodes
+// converted values to 8-bit sRGB.
+const outSteps = 4096
+
+// Transform converts 8-bit pixels from a profile's colour space to sRGB. It
+// is safe for concurrent use.
+type Transform struct {
+	in [3][256]float32 // encoded channel value → linear light
+	m  [3][3]float32   // linear profile RGB → linear sRGB
+}
+
+// ToSRGB returns the conversion from the profile to sRGB. The profile's
+// primaries are relative to the D50 connection space; the Bradford transform
+// adapts them to sRGB's D65 white, so white stays white.
+func (p *Profile) ToSRGB() *Transform {
+	t := &Transform{}
+	for c := range p.trc {
+		for v := range t.in[c] {
+			t.in[c][v] = float32(p.trc[c].eval(float64(v) / 255))
+		}
+	}
+	m := mul(xyzToSRGB, mul(bradford(d50, d65), p.colorants))
+	for r := range m {
+		for c := range m[r] {
+			t.m[r][c] = float32(m[r][c])
+		}
+	}
+	return t
+}
+
+// Apply returns img converted to sRGB. An *image.RGBA is converted in
+// place; other types are copied first. Colours outside the sRGB gamut are
+// clipped.
+func (t *Transform) Apply(img image.Image) *image.RGBA {
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		b := img.Bounds()
+		rgba = image.NewRGBA(b)
+		draw.Draw(rgba, b, img, b.Min, draw.Src)
+	}
+	out := srgbEncodeTable()
+	b := rgba.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := rgba.Pix[rgba.PixOffset(b.Min.X, y):]
+		for x := 0; x < b.Dx(); x++ {
+			p := row[x*4 : x*4+4 : x*4+4]
+			a := p[3]
+			if a == 0 {
+				continue
+			}
+			var rgb [3]float32
+			for c := range rgb {
+				v := p[c]
+				if a != 0xff {
+					// The curves apply to straight colour.
+					v = uint8(min(int(v)*0xff/int(a), 0xff))
+				}
+				rgb[c] = t.in[c][v]
+			}
+			for c := range rgb {
+				l := t.m[c][0]*rgb[0] + t.m[c][1]*rgb[1] + t.m[c][2]*rgb[2]
+				v := out[int(min(max(l, 0), 1)*(outSteps-1)+0.5)]
+				if a != 0xff {
+					v = uint8(int(v) * int(a) / 0xff)
+				}
+				p[c] = v
+			}
+		}
+	}
+	return rgba
+}
+
+// srgbEncodeTable maps linear light in outSteps steps to 8-bit sRGB.
+var srgbEncodeTable = sync.OnceValue(func() []uint8 {
+	t := make([]uint8, outSteps)
+	for i := range t {
+		t[i] = uint8(math.Round(srgbEncode(float64(i)/(outSteps-1)) * 255))
+	}
+	return t
+})
+
+// srgbDecode is the sRGB transfer function from encoded value to linear light.
+func srgbDecode(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// srgbEncode is the inverse of srgbDecode.
+func srgbEncode(l float64) float64 {
+	if l <= 0.0031308 {
+		return l * 12.92
+	}
+	return 1.055*math.Pow(l, 1/2.4) - 0.055
+}
+
+type matrix = [3][3]float64
+
+// White points and matrices from the sRGB (IEC 61966-2-1) and ICC specs.
+var (
+	d50 = [3]float64{0.9642, 1, 0.8249}
+	d65 = [3]float64{0.95047, 1, 1.08883}
+
+	srgbToXYZ = matrix{
+		{0.4124564, 0.3575761, 0.1804375},
+		{0.2126729, 0.7151522, 0.0721750},
+		{0.0193339, 0.1191920, 0.9503041},
+	}
+	xyzToSRGB = inverse(srgbToXYZ)
+
+	bradfordCone = matrix{
+		{0.8951, 0.2664, -0.1614},
+		{-0.7502, 1.7135, 0.0367},
+		{0.0389, -0.0685, 1.0296},
+	}
+
+	// srgbColorants are sRGB's primaries adapted to the D50 connection
+	// space, as an sRGB profile stores them.
+	srgbColorants = mul(bradford(d65, d50), srgbToXYZ)
+)
+
+// bradford returns the chromatic adaptation from white point src to dst.
+func bradford(src, dst [3]float64) matrix {
+	s, d := apply(bradfordCone, src), apply(bradfordCone, dst)
+	var scale matrix
+	for i := range scale {
+		scale[i][i] = d[i] / s[i]
+	}
+	return mul(inverse(bradfordCone), mul(scale, bradfordCone))
+}
+
+func mul(a, b matrix) matrix {
+	var m matrix
+	for r := range m {
+		for c := range m[r] {
+			for k := 0; k < 3; k++ {
+				m[r][c] += a[r][k] * b[k][c]
+			}
+		}
+	}
+	return m
+}
+
+func apply(m matrix, v [3]float64) [3]float64 {
+	var out [3]float64
+	for r := range out {
+		out[r] = m[r][0]*v[0] + m[r][1]*v[1] + m[r][2]*v[2]
+	}
+	return out
+}
+
+func inverse(m matrix) matrix {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	return matrix{
+		{
+			(m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det,
+			(m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det,
+			(m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det,
+		},
+		{
+			(m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det,
+			(m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det,
+			(m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det,
+		},
+		{
+			(m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det,
+			(m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det,
+			(m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det,
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package jpegscale
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDecodeScaledFormats decodes the standard library's JPEG fixtures at
+// every scale and compares them with a full-size stdlib decode that is
+// box-averaged down to the same size. The 150×103 video frames are not a
+// multiple of any MCU size, so edge blocks are covered too. Full-size decodes
+// must match exactly; reduced ones drop the frequencies a box filter would
+// alias and repeat subsampled chroma where image/jpeg interpolates it, so
+// they only stay close on average, with rare larger differences at hard
+// edges.
+func TestDecodeScaledFormats(t *testing.T) {
+	files := []struct {
+		name, file string
+	}{
+		{"gray", "video-005.gray.q50.jpeg"},
+		{"ycbcr 4:2:0", "video-001.q50.420.jpeg"},
+		{"ycbcr 4:2:2", "video-001.q50.422.jpeg"},
+		{"ycbcr 4:4:4", "video-001.q50.444.jpeg"},
+		{"progressive 4:2:0", "video-001.q50.420.progressive.jpeg"},
+		{"progressive 4:4:4", "video-001.q50.444.progressive.jpeg"},
+		{"adobe cmyk", "video-001.cmyk.jpeg"},
+		{"restart interval", "video-001.restart2.jpeg"},
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join("testdata", f.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: stdlib decode: %v", f.name, err)
+		}
+		full := toRGBA(ref)
+
+		for _, denom := range []int{1, 2, 4, 8} {
+			img, err := Decode(bytes.NewReader(data), denom)
+			if err != nil {
+				t.Fatalf("%s 1/%d: %v", f.name, denom, err)
+			}
+			if want := ScaleRect(ref.Bounds(), denom); img.Bounds() != want {
+				t.Fatalf("%s 1/%d: bounds = %v, want %v", f.name, denom, img.Bounds(), want)
+			}
+			mean, outliers := compareBoxed(toRGBA(img), full, denom)
+			switch {
+			case denom == 1 && (mean != 0 || outliers != 0):
+				t.Errorf("%s: full-size decode differs from image/jpeg (mean %.2f)", f.name, mean)
+			case mean > maxMeanDiff:
+				t.Errorf("%s 1/%d: mean difference %.2f, want at most %v", f.name, denom, mean, maxMeanDiff)
+			case outliers > maxOutliers:
+				t.Errorf("%s 1/%d: %.2f%% of samples off by more than %d, want at most %.0f%%", f.name, denom, 100*outliers, outlierDiff, 100*maxOutliers)
+			}
+		}
+	}
+}
+
+// Tolerances for reduced decodes, in 8-bit channel steps.
+const (
+	maxMeanDiff = 7
+	outlierDiff = 32
+	maxOutliers = 0.03 // fraction of samples beyond outlierDiff
+)
+
+func toRGBA(img image.Image) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+	return out
+}
+
+// compareBoxed returns the mean per-channel difference between scaled and
+// the denom×denom block averages of full, and the fraction of samples off by
+// more than outlierDiff. Blocks at the right and bottom edges average only
+// the pixels inside the image.
+func compareBoxed(scaled, full *image.RGBA, denom int) (mean, outliers float64) {
+	fb := full.Bounds()
+	var total, n, far int
+	for y := scaled.Bounds().Min.Y; y < scaled.Bounds().Max.Y; y++ {
+		for x := scaled.Bounds().Min.X; x < scaled.Bounds().Max.X; x++ {
+			var sum [3]int
+			count := 0
+			for sy := y * denom; sy < min((y+1)*denom, fb.Max.Y); sy++ {
+				for sx := x * denom; sx < min((x+1)*denom, fb.Max.X); sx++ {
+					p := full.RGBAAt(sx, sy)
+					sum[0] += int(p.R)
+					sum[1] += int(p.G)
+					sum[2] += int(p.B)
+					count++
+				}
+			}
+			p := scaled.RGBAAt(x, y)
+			for c, got := range []uint8{p.R, p.G, p.B} {
+				want := (sum[c] + count/2) / count
+				d := max(int(got)-want, want-int(got))
+				total += d
+				n++
+				if d > outlierDiff {
+					far++
+				}
+			}
+		}
+	}
+	return float64(total) / float64(n), float64(far) / float64(n)
+}
@@ -0,0 +1,86 @@
+package jpegscale
+
+import (
+	"image"
+	"math"
+)
+
+// idctTables holds, for each reduced block size n (1, 2 and 4), the n-point
+// inverse DCT basis evaluated at the n output sample positions. Row x, column
+// u is sqrt(n/8) * α(u) * cos((2x+1)uπ/2n), where α is the orthonormal DCT
+// weight. The sqrt(n/8) factor keeps the DC gain of the 8-point transform so
+// a reduced block has the same average brightness as the full one.
+var idctTables = map[int][][]float64{
+	1: idctTable(1),
+	2: idctTable(2),
+	4: idctTable(4),
+}
+
+func idctTable(n int) [][]float64 {
+	t := make([][]float64, n)
+	for x := 0; x < n; x++ {
+		t[x] = make([]float64, n)
+		for u := 0; u < n; u++ {
+			alpha := math.Sqrt(2 / float64(n))
+			if u == 0 {
+				alpha = math.Sqrt(1 / float64(n))
+			}
+			t[x][u] = math.Sqrt(float64(n)/8) * alpha * math.Cos(float64(2*x+1)*float64(u)*math.Pi/float64(2*n))
+		}
+	}
+	return t
+}
+
+// idctScaled reconstructs an n×n block from the top-left n×n dequantized
+// coefficients of b, discarding the higher frequencies that cannot be
+// represented at the reduced size. Results are written back into b using the
+// same 8-wide stride as idct, so callers read pixel (x, y) from b[8*y+x].
+func idctScaled(b *block, n int) {
+	if n == 1 {
+		// A single pixel is just the block average.
+		b[0] = int32(math.Round(float64(b[0]) / 8))
+		return
+	}
+
+	t := idctTables[n]
+	var tmp [4 * 4]float64
+
+	// Rows first: tmp[v][x] = Σ_u t[x][u] * F[v][u].
+	for v := 0; v < n; v++ {
+		for x := 0; x < n; x++ {
+			sum := 0.0
+			for u := 0; u < n; u++ {
+				sum += t[x][u] * float64(b[8*v+u])
+			}
+			tmp[4*v+x] = sum
+		}
+	}
+	// Then columns: out[y][x] = Σ_v t[y][v] * tmp[v][x].
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			sum := 0.0
+			for v := 0; v < n; v++ {
+				sum += t[y][v] * tmp[4*v+x]
+			}
+			b[8*y+x] = int32(math.Round(sum))
+		}
+	}
+}
+
+// Denominator picks the largest supported scale denominator (8, 4, 2 or 1)
+// that still leaves an image of width×height at least minWidth×minHeight
+// pixels, so the caller can downscale further without upsampling.
+func Denominator(width, height, minWidth, minHeight int) int {
+	for _, denom := range []int{8, 4, 2} {
+		if (width+denom-1)/denom >= minWidth && (height+denom-1)/denom >= minHeight {
+			return denom
+		}
+	}
+	return 1
+}
+
+// ScaleRect maps a rectangle in full-size pixel coordinates to the matching
+// rectangle in an image decoded at 1/denom, rounding outward.
+func ScaleRect(r image.Rectangle, denom int) image.Rectangle {
+	return image.Rect(r.Min.X/denom, r.Min.Y/denom, (r.Max.X+denom-1)/denom, (r.Max.Y+denom-1)/denom)
+}
@@ -0,0 +1,94 @@
+package jpegscale
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// TestDecodeScaled checks that reduced decodes have the expected size and that
+// every output pixel matches the average of the block it replaces.
+func TestDecodeScaled(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 67, 45))
+	for y := 0; y < 45; y++ {
+		for x := 0; x < 67; x++ {
+			src.SetGray(x, y, color.Gray{Y: uint8(2*x + 2*y)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	full, err := Decode(bytes.NewReader(buf.Bytes()), 1)
+	if err != nil {
+		t.Fatalf("decode full: %v", err)
+	}
+	if full.Bounds() != src.Bounds() {
+		t.Fatalf("full bounds = %v, want %v", full.Bounds(), src.Bounds())
+	}
+
+	cases := []struct {
+		denom int
+		want  image.Rectangle
+	}{
+		{2, image.Rect(0, 0, 34, 23)},
+		{4, image.Rect(0, 0, 17, 12)},
+		{8, image.Rect(0, 0, 9, 6)},
+	}
+	for _, tc := range cases {
+		img, err := Decode(bytes.NewReader(buf.Bytes()), tc.denom)
+		if err != nil {
+			t.Fatalf("decode 1/%d: %v", tc.denom, err)
+		}
+		if img.Bounds() != tc.want {
+			t.Fatalf("1/%d bounds = %v, want %v", tc.denom, img.Bounds(), tc.want)
+		}
+
+		// Only compare blocks that lie fully inside the source image.
+		for y := 0; (y+1)*tc.denom <= 45; y++ {
+			for x := 0; (x+1)*tc.denom <= 67; x++ {
+				sum, n := 0, 0
+				for sy := y * tc.denom; sy < (y+1)*tc.denom; sy++ {
+					for sx := x * tc.denom; sx < (x+1)*tc.denom; sx++ {
+						sum += int(full.(*image.Gray).GrayAt(sx, sy).Y)
+						n++
+					}
+				}
+				got := int(img.(*image.Gray).GrayAt(x, y).Y)
+				if diff := got - sum/n; diff < -2 || diff > 2 {
+					t.Fatalf("1/%d pixel (%d,%d) = %d, want ~%d", tc.denom, x, y, got, sum/n)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeRejectsBadDenominator(t *testing.T) {
+	if _, err := Decode(bytes.NewReader(nil), 3); err == nil {
+		t.Fatalf("expected error for denominator 3")
+	}
+}
+
+func TestDenominator(t *testing.T) {
+	cases := []struct {
+		name             string
+		w, h, minW, minH int
+		want             int
+	}{
+		{"huge source tiny tile", 6000, 4000, 400, 400, 8},
+		{"quarter fits", 2000, 1600, 400, 400, 4},
+		{"half fits", 1000, 900, 400, 400, 2},
+		{"no reduction possible", 500, 500, 400, 400, 1},
+		{"height limits", 6000, 1000, 100, 400, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Denominator(tc.w, tc.h, tc.minW, tc.minH); got != tc.want {
+				t.Fatalf("Denominator(%d, %d, %d, %d) = %d, want %d", tc.w, tc.h, tc.minW, tc.minH, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Package pngchunk adds ancillary chunks to a PNG stream while it is being
+// encoded, so metadata such as a colour profile or a text note can ride along
+// with image/png output without buffering the whole file.
+package pngchunk
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// IHDREnd is the offset just past the PNG signature and the IHDR chunk
+// (length, type, 13 bytes of data, CRC), where ancillary chunks may go.
+const IHDREnd = 8 + 4 + 4 + 13 + 4
+
+// Encode returns a complete chunk of the given type: length, type, data and
+// CRC.
+func Encode(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// Writer returns a writer that passes a PNG stream through to w, inserting
+// chunk, as built by Encode, right after the IHDR chunk.
+func Writer(w io.Writer, chunk []byte) io.Writer {
+	return Insert(w, IHDREnd, chunk)
+}
+
+// Insert returns a writer that copies a stream to w and inserts data once at
+// byte offset at. It is not PNG specific; JPEG segments after the SOI marker
+// use it too.
+func Insert(w io.Writer, at int, data []byte) io.Writer {
+	return &insertWriter{w: w, at: at, data: data}
+}
+
+// insertWriter copies a stream to w and inserts data once at byte offset at.
+type insertWriter struct {
+	w    io.Writer
+	at   int
+	data []byte
+	n    int // stream bytes written so far
+}
+
+func (iw *insertWriter) Write(p []byte) (int, error) {
+	if iw.data == nil || iw.n+len(p) < iw.at {
+		n, err := iw.w.Write(p)
+		iw.n += n
+		return n, err
+	}
+	head := iw.at - iw.n
+	n, err := iw.w.Write(p[:head])
+	iw.n += n
+	if err != nil {
+		return n, err
+	}
+	if _, err := iw.w.Write(iw.data); err != nil {
+		return n, err
+	}
+	iw.data = nil
+	m, err := iw.w.Write(p[head:])
+	iw.n += m
+	return n + m, err
+}
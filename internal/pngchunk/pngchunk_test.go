@@ -0,0 +1,52 @@
+package pngchunk
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// byteWriter forwards one byte per Write, so the insert offset lands inside
+// every possible split of the stream.
+type byteWriter struct{ w *bytes.Buffer }
+
+func (bw byteWriter) Write(p []byte) (int, error) {
+	for i := range p {
+		bw.w.WriteByte(p[i])
+	}
+	return len(p), nil
+}
+
+func TestWriterInsertsAfterIHDR(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 5, 3))
+	img.Set(2, 1, color.Gray{Y: 200})
+	chunk := Encode("tEXt", []byte("key\x00value"))
+
+	for _, split := range []bool{false, true} {
+		var buf bytes.Buffer
+		var w io.Writer = &buf
+		if split {
+			w = byteWriter{&buf}
+		}
+		if err := png.Encode(Writer(w, chunk), img); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		if !bytes.Equal(data[IHDREnd:IHDREnd+len(chunk)], chunk) {
+			t.Fatalf("split=%v: chunk not right after IHDR", split)
+		}
+		if n := bytes.Count(data, chunk); n != 1 {
+			t.Fatalf("split=%v: chunk inserted %d times", split, n)
+		}
+		got, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("split=%v: %v", split, err)
+		}
+		if g := got.(*image.Gray).GrayAt(2, 1).Y; g != 200 {
+			t.Fatalf("split=%v: pixel = %d, want 200", split, g)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package stripenc encodes images whose pixels arrive in horizontal strips,
+// top to bottom, so a canvas larger than memory never has to be held whole.
+// PNG is written row by row through one zlib stream; TIFF is uncompressed
+// with every offset computed up front, switching to BigTIFF past 4 GiB.
+package stripenc
+
+import (
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+)
+
+// Encoder receives an image strip by strip.
+type Encoder interface {
+	// WriteStrip appends the rows of img, which must be as wide as the
+	// image and continue where the previous strip ended.
+	WriteStrip(img *image.RGBA) error
+	// Close finishes the file. It fails unless every row was written.
+	Close() error
+}
+
+// idatSize is the payload at which compressed data is cut into an IDAT chunk.
+const idatSize = 64 << 10
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+type pngEncoder struct {
+	w             io.Writer
+	width, height int
+	rows          int
+	z             *zlib.Writer
+	idat          chunkWriter
+	prev, cur     []byte   // un-premultiplied rows, without the filter byte
+	filtered      [][]byte // candidate rows per filter type, with the filter byte
+}
+
+// NewPNG writes the PNG signature and header to w and returns an encoder for
+// the 8-bit RGBA pixel rows.
+func NewPNG(w io.Writer, width, height int) (Encoder, error) {
+	if err := checkSize(width, height); err != nil {
+		return nil, err
+	}
+	if width > (math.MaxInt32-1)/4 {
+		return nil, fmt.Errorf("png: width %d is too large", width)
+	}
+	e := &pngEncoder{w: w, width: width, height: height}
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	ihdr[8], ihdr[9] = 8, 6 // bit depth, colour type RGBA
+	if _, err := w.Write(pngSignature); err != nil {
+		return nil, err
+	}
+	if err := writeChunk(w, "IHDR", ihdr); err != nil {
+		return nil, err
+	}
+
+	e.idat.w = w
+	z, err := zlib.NewWriterLevel(&e.idat, zlib.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	e.z = z
+	n := 4 * width
+	e.prev, e.cur = make([]byte, n), make([]byte, n)
+	e.filtered = make([][]byte, 5)
+	for i := range e.filtered {
+		e.filtered[i] = make([]byte, 1+n)
+		e.filtered[i][0] = byte(i)
+	}
+	return e, nil
+}
+
+func (e *pngEncoder) WriteStrip(img *image.RGBA) error {
+	b := img.Bounds()
+	if b.Dx() != e.width {
+		return fmt.Errorf("png: strip is %d pixels wide, want %d", b.Dx(), e.width)
+	}
+	if e.rows+b.Dy() > e.height {
+		return fmt.Errorf("png: strip overruns the %d rows of the image", e.height)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		unpremultiply(e.cur, img.Pix[img.PixOffset(b.Min.X, y):])
+		if _, err := e.z.Write(e.filter()); err != nil {
+			return err
+		}
+		e.prev, e.cur = e.cur, e.prev
+		e.rows++
+	}
+	return nil
+}
+
+func (e *pngEncoder) Close() error {
+	if e.rows != e.height {
+		return fmt.Errorf("png: %d of %d rows written", e.rows, e.height)
+	}
+	if err := e.z.Close(); err != nil {
+		return err
+	}
+	if err := e.idat.flush(); err != nil {
+		return err
+	}
+	return writeChunk(e.w, "IEND", nil)
+}
+
+// unpremultiply converts a row of premultiplied RGBA to the straight alpha
+// PNG stores, rounding like color.NRGBAModel so output matches png.Encode.
+func unpremultiply(dst, src []byte) {
+	for i := 0; i < len(dst); i += 4 {
+		p := src[i : i+4 : i+4]
+		a := uint32(p[3])
+		switch a {
+		case 0xff:
+			copy(dst[i:i+4], p)
+		case 0:
+			dst[i], dst[i+1], dst[i+2], dst[i+3] = 0, 0, 0, 0
+		default:
+			a16 := a * 0x101
+			for c := 0; c < 3; c++ {
+				dst[i+c] = uint8(uint32(p[c]) * 0x101 * 0xffff / a16 >> 8)
+			}
+			dst[i+3] = p[3]
+		}
+	}
+}
+
+// filter applies all five PNG filters to the current row and returns the
+// one with the smallest sum of absolute differences, the heuristic the PNG
+// spec recommends and image/png uses.
+func (e *pngEncoder) filter() []byte {
+	const bpp = 4
+	cur, prev := e.cur, e.prev
+	best, bestSum := 0, math.MaxInt
+	for ft, out := range e.filtered {
+		row := out[1:]
+		sum := 0
+		for i := range cur {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left, upLeft = cur[i-bpp], prev[i-bpp]
+			}
+			up = prev[i]
+			var v byte
+			switch ft {
+			case 0:
+				v = cur[i]
+			case 1:
+				v = cur[i] - left
+			case 2:
+				v = cur[i] - up
+			case 3:
+				v = cur[i] - byte((int(left)+int(up))/2)
+			case 4:
+				v = cur[i] - paeth(left, up, upLeft)
+			}
+			row[i] = v
+			sum += abs8(v)
+			if sum >= bestSum {
+				break
+			}
+		}
+		if sum < bestSum {
+			best, bestSum = ft, sum
+		}
+	}
+	return e.filtered[best]
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs8(v byte) int {
+	return absInt(int(int8(v)))
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// chunkWriter collects compressed data and emits it as IDAT chunks.
+type chunkWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		k := min(len(p), idatSize-len(c.buf))
+		c.buf = append(c.buf, p[:k]...)
+		p = p[k:]
+		if len(c.buf) == idatSize {
+			if err := c.flush(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (c *chunkWriter) flush() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+	err := writeChunk(c.w, "IDAT", c.buf)
+	c.buf = c.buf[:0]
+	return err
+}
+
+func writeChunk(w io.Writer, typ string, data []byte) error {
+	head := make([]byte, 8, 8+len(data)+4)
+	binary.BigEndian.PutUint32(head, uint32(len(data)))
+	copy(head[4:], typ)
+	chunk := append(head, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(chunk)
+	return err
+}
+
+func checkSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > math.MaxInt32 || height > math.MaxInt32 {
+		return fmt.Errorf("invalid image size %d×%d", width, height)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package stripenc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+// testImage returns a gradient with some translucent and transparent pixels.
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := uint8(0xff)
+			switch {
+			case x%7 == 0:
+				a = 0
+			case y%5 == 0:
+				a = uint8(40 + 3*x)
+			}
+			img.Set(x, y, color.NRGBA{uint8(x * 9), uint8(y * 11), uint8(x*y + 3), a})
+		}
+	}
+	return img
+}
+
+// writeStrips feeds img to e in strips of the given heights.
+func writeStrips(t *testing.T, e Encoder, img *image.RGBA, heights ...int) {
+	t.Helper()
+	y := 0
+	for _, h := range heights {
+		strip := img.SubImage(image.Rect(0, y, img.Rect.Dx(), y+h)).(*image.RGBA)
+		if err := e.WriteStrip(strip); err != nil {
+			t.Fatal(err)
+		}
+		y += h
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPNGMatchesImagePNG(t *testing.T) {
+	img := testImage(23, 17)
+	var buf bytes.Buffer
+	e, err := NewPNG(&buf, 23, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeStrips(t, e, img, 5, 1, 11)
+
+	got, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ref bytes.Buffer
+	if err := png.Encode(&ref, img); err != nil {
+		t.Fatal(err)
+	}
+	want, err := png.Decode(&ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 17; y++ {
+		for x := 0; x < 23; x++ {
+			if g, w := got.At(x, y), want.At(x, y); g != w {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestTIFFRoundTrip(t *testing.T) {
+	img := testImage(300, 1200) // several TIFF strips
+	profile := []byte("not really a profile, but long enough")
+	var buf bytes.Buffer
+	e, err := NewTIFF(&buf, 300, 1200, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeStrips(t, e, img, 700, 500)
+
+	got, err := tiff.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba, ok := got.(*image.RGBA)
+	if !ok {
+		t.Fatalf("decoded %T, want *image.RGBA", got)
+	}
+	if !bytes.Equal(rgba.Pix, img.Pix) {
+		t.Error("decoded pixels differ from the input")
+	}
+	if !bytes.Contains(buf.Bytes()[:1024], profile) {
+		t.Error("ICC profile not embedded")
+	}
+}
+
+func TestBigTIFFLayout(t *testing.T) {
+	img := testImage(4, 3)
+	var buf bytes.Buffer
+	e, err := newTIFF(&buf, 4, 3, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeStrips(t, e, img, 3)
+
+	b, le := buf.Bytes(), binary.LittleEndian
+	if string(b[:2]) != "II" || le.Uint16(b[2:]) != 43 || le.Uint16(b[4:]) != 8 {
+		t.Fatalf("header % x", b[:8])
+	}
+	ifd := le.Uint64(b[8:])
+	n := le.Uint64(b[ifd:])
+	var offset, count uint64
+	for i := uint64(0); i < n; i++ {
+		entry := b[ifd+8+20*i:]
+		switch le.Uint16(entry) {
+		case 273:
+			offset = le.Uint64(entry[12:])
+		case 279:
+			count = le.Uint64(entry[12:])
+		}
+	}
+	if count != uint64(len(img.Pix)) {
+		t.Fatalf("strip byte count %d, want %d", count, len(img.Pix))
+	}
+	if !bytes.Equal(b[offset:offset+count], img.Pix) {
+		t.Error("strip data differs from the input")
+	}
+}
+
+func TestRowCount(t *testing.T) {
+	img := testImage(8, 4)
+	for _, tc := range []struct {
+		name string
+		new  func() (Encoder, error)
+	}{
+		{"png", func() (Encoder, error) { return NewPNG(&bytes.Buffer{}, 8, 4) }},
+		{"tiff", func() (Encoder, error) { return NewTIFF(&bytes.Buffer{}, 8, 4, nil) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := tc.new()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := e.WriteStrip(img.SubImage(image.Rect(0, 0, 8, 3)).(*image.RGBA)); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Close(); err == nil {
+				t.Error("Close succeeded with a row missing")
+			}
+			if err := e.WriteStrip(img); err == nil {
+				t.Error("WriteStrip accepted rows past the end")
+			}
+			if err := e.WriteStrip(img.SubImage(image.Rect(0, 3, 4, 4)).(*image.RGBA)); err == nil {
+				t.Error("WriteStrip accepted a narrow strip")
+			}
+		})
+	}
+}
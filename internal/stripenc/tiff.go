@@ -0,0 +1,196 @@
+package stripenc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// tiffStripSize is the target size of one TIFF strip; readers fetch strips
+// whole, so they stay small even when the image is huge.
+const tiffStripSize = 1 << 20
+
+// TIFF field types.
+const (
+	tShort     = 3
+	tLong      = 4
+	tRational  = 5
+	tUndefined = 7
+	tLong8     = 16
+)
+
+type tiffEncoder struct {
+	w             *bufio.Writer
+	width, height int
+	rows          int
+}
+
+// NewTIFF writes the header and directory of an uncompressed RGBA TIFF with
+// associated (premultiplied) alpha to w and returns an encoder for its
+// pixels. The profile, when set, is embedded as the ICC colour profile. Files
+// that would pass 4 GiB are written as BigTIFF.
+func NewTIFF(w io.Writer, width, height int, profile []byte) (Encoder, error) {
+	if err := checkSize(width, height); err != nil {
+		return nil, err
+	}
+	pixels := 4 * int64(width) * int64(height)
+	// Leave room for the directory and strip tables below the 4 GiB limit.
+	big := pixels+int64(len(profile))+64<<20 > math.MaxUint32
+	return newTIFF(w, width, height, profile, big)
+}
+
+func newTIFF(w io.Writer, width, height int, profile []byte, big bool) (Encoder, error) {
+	header := tiffHeader(width, height, profile, big)
+	bw := bufio.NewWriterSize(w, 1<<20)
+	if _, err := bw.Write(header); err != nil {
+		return nil, err
+	}
+	return &tiffEncoder{w: bw, width: width, height: height}, nil
+}
+
+func (e *tiffEncoder) WriteStrip(img *image.RGBA) error {
+	b := img.Bounds()
+	if b.Dx() != e.width {
+		return fmt.Errorf("tiff: strip is %d pixels wide, want %d", b.Dx(), e.width)
+	}
+	if e.rows+b.Dy() > e.height {
+		return fmt.Errorf("tiff: strip overruns the %d rows of the image", e.height)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		off := img.PixOffset(b.Min.X, y)
+		if _, err := e.w.Write(img.Pix[off : off+4*e.width]); err != nil {
+			return err
+		}
+		e.rows++
+	}
+	return nil
+}
+
+func (e *tiffEncoder) Close() error {
+	if e.rows != e.height {
+		return fmt.Errorf("tiff: %d of %d rows written", e.rows, e.height)
+	}
+	return e.w.Flush()
+}
+
+// tiffField is one directory entry before layout.
+type tiffField struct {
+	tag, typ uint16
+	count    uint64
+	data     []byte // little-endian values
+}
+
+// tiffHeader lays out the file header, the single image directory and the
+// values that do not fit in it. Pixel data follows directly, so the strip
+// offsets are known before any pixel is rendered.
+func tiffHeader(width, height int, profile []byte, big bool) []byte {
+	le := binary.LittleEndian
+	shorts := func(v ...uint16) []byte {
+		b := make([]byte, 0, 2*len(v))
+		for _, x := range v {
+			b = le.AppendUint16(b, x)
+		}
+		return b
+	}
+	long := func(v uint32) []byte { return le.AppendUint32(nil, v) }
+	rational := append(long(72), long(1)...)
+
+	rowBytes := 4 * uint64(width)
+	rowsPerStrip := max(1, tiffStripSize/rowBytes)
+	strips := (uint64(height) + rowsPerStrip - 1) / rowsPerStrip
+	offType, offSize := uint16(tLong), uint64(4)
+	if big {
+		offType, offSize = tLong8, 8
+	}
+
+	fields := []tiffField{
+		{256, tLong, 1, long(uint32(width))},
+		{257, tLong, 1, long(uint32(height))},
+		{258, tShort, 4, shorts(8, 8, 8, 8)},                 // BitsPerSample
+		{259, tShort, 1, shorts(1)},                          // Compression: none
+		{262, tShort, 1, shorts(2)},                          // PhotometricInterpretation: RGB
+		{273, offType, strips, make([]byte, strips*offSize)}, // StripOffsets, filled below
+		{277, tShort, 1, shorts(4)},                          // SamplesPerPixel
+		{278, tLong, 1, long(uint32(rowsPerStrip))},
+		{279, offType, strips, make([]byte, strips*offSize)}, // StripByteCounts
+		{282, tRational, 1, rational},                        // XResolution
+		{283, tRational, 1, rational},                        // YResolution
+		{284, tShort, 1, shorts(1)},                          // PlanarConfiguration: chunky
+		{296, tShort, 1, shorts(2)},                          // ResolutionUnit: inch
+		{338, tShort, 1, shorts(1)},                          // ExtraSamples: associated alpha
+	}
+	if len(profile) > 0 {
+		fields = append(fields, tiffField{34675, tUndefined, uint64(len(profile)), profile})
+	}
+
+	headerSize, countSize, entrySize, valueSize := 8, 2, 12, 4
+	if big {
+		headerSize, countSize, entrySize, valueSize = 16, 8, 20, 8
+	}
+	ifdSize := countSize + len(fields)*entrySize + valueSize // next IFD offset is valueSize wide
+
+	// Values too large for their entry go after the directory, word aligned.
+	extOffset := make([]int, len(fields))
+	end := headerSize + ifdSize
+	for i, f := range fields {
+		if len(f.data) > valueSize {
+			end += end % 2
+			extOffset[i] = end
+			end += len(f.data)
+		}
+	}
+	dataStart := uint64(end)
+
+	stripOffsets, stripCounts := fields[5].data, fields[8].data
+	for i := uint64(0); i < strips; i++ {
+		rows := min(rowsPerStrip, uint64(height)-i*rowsPerStrip)
+		off, n := dataStart+i*rowsPerStrip*rowBytes, rows*rowBytes
+		if big {
+			le.PutUint64(stripOffsets[8*i:], off)
+			le.PutUint64(stripCounts[8*i:], n)
+		} else {
+			le.PutUint32(stripOffsets[4*i:], uint32(off))
+			le.PutUint32(stripCounts[4*i:], uint32(n))
+		}
+	}
+
+	out := make([]byte, end)
+	copy(out, "II")
+	if big {
+		le.PutUint16(out[2:], 43)
+		le.PutUint16(out[4:], 8) // offset size
+		le.PutUint64(out[8:], uint64(headerSize))
+		le.PutUint64(out[headerSize:], uint64(len(fields)))
+	} else {
+		le.PutUint16(out[2:], 42)
+		le.PutUint32(out[4:], uint32(headerSize))
+		le.PutUint16(out[headerSize:], uint16(len(fields)))
+	}
+	for i, f := range fields {
+		e := out[headerSize+countSize+i*entrySize:]
+		le.PutUint16(e, f.tag)
+		le.PutUint16(e[2:], f.typ)
+		value := e[8:]
+		if big {
+			le.PutUint64(e[4:], f.count)
+			value = e[12:]
+		} else {
+			le.PutUint32(e[4:], uint32(f.count))
+		}
+		if len(f.data) <= valueSize {
+			copy(value, f.data)
+			continue
+		}
+		if big {
+			le.PutUint64(value, uint64(extOffset[i]))
+		} else {
+			le.PutUint32(value, uint32(extOffset[i]))
+		}
+		copy(out[extOffset[i]:], f.data)
+	}
+	// The next-directory offset stays zero: there is only one image.
+	return out
+}
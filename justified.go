@@ -0,0 +1,219 @@
+package yearcollage
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sync"
+)
+
+// cropWeight scales the penalty for cropping a row relative to missing the
+// target row height, so the optimizer only crops when it clearly pays off.
+const cropWeight = 0.25
+
+// justifiedLayout packs images with upright aspect ratios `ratios` into rows
+// that span exactly `width` pixels, each photo keeping its native shape. Row
+// breaks are chosen by dynamic programming (in the spirit of Knuth-Plass line
+// breaking) to minimize the total squared deviation of row heights from
+// targetHeight, rather than greedily filling each row.
+//
+// maxCrop (0..0.5) lets a row trade up to that fraction of each photo's width
+// or height for a height closer to the target. The last row is left ragged at
+// the target height when it is too short to fill the width.
+func justifiedLayout(ratios []float64, width, targetHeight int, maxCrop float64) layout {
+	n := len(ratios)
+	if n == 0 || width <= 0 || targetHeight <= 0 {
+		return layout{width: width}
+	}
+
+	W, T := float64(width), float64(targetHeight)
+	prefix := make([]float64, n+1)
+	for i, a := range ratios {
+		if a <= 0 || math.IsNaN(a) || math.IsInf(a, 0) {
+			a = 1
+		}
+		prefix[i+1] = prefix[i] + a
+	}
+
+	// fit returns the row height for a summed aspect and its cost.
+	fit := func(sum float64) (h, cost float64) {
+		lo := W / (sum * (1 + maxCrop))
+		hi := W / (sum * (1 - maxCrop))
+		h = math.Min(math.Max(T, lo), hi)
+		f := W / (h * sum)
+		dev := (h - T) / T
+		return h, dev*dev + cropWeight*(f-1)*(f-1)
+	}
+	// Rows shorter than a quarter of the target are never worth considering,
+	// which bounds the inner loop for large libraries.
+	maxSum := 4 * W / T
+
+	best := make([]float64, n+1)
+	prev := make([]int, n+1)
+	for j := 1; j <= n; j++ {
+		best[j] = math.Inf(1)
+		for i := j - 1; i >= 0; i-- {
+			sum := prefix[j] - prefix[i]
+			if i < j-1 && sum > maxSum {
+				break
+			}
+			_, cost := fit(sum)
+			if c := best[i] + cost; c < best[j] {
+				best[j], prev[j] = c, i
+			}
+		}
+	}
+
+	// The last row may stay ragged: if its photos fit at the target height,
+	// it costs nothing; otherwise it is justified like any other row.
+	lastStart, lastCost := prev[n], math.Inf(1)
+	for i := n - 1; i >= 0; i-- {
+		sum := prefix[n] - prefix[i]
+		if i < n-1 && sum > maxSum {
+			break
+		}
+		cost := 0.0
+		if sum*T > W {
+			_, cost = fit(sum)
+		}
+		if c := best[i] + cost; c < lastCost {
+			lastStart, lastCost = i, c
+		}
+	}
+
+	var starts []int
+	for i := lastStart; i > 0; i = prev[i] {
+		starts = append([]int{prev[i]}, starts...)
+	}
+	starts = append(starts, lastStart, n)
+
+	lay := layout{width: width, cells: make([]cell, n), rows: len(starts) - 1, slots: n}
+	y := 0
+	for r := 0; r+1 < len(starts); r++ {
+		i, j := starts[r], starts[r+1]
+		lay.columns = max(lay.columns, j-i)
+		sum := prefix[j] - prefix[i]
+
+		// span is the row width the photos occupy at height h.
+		h, span := T, sum*T
+		if r+2 < len(starts) || sum*T > W {
+			h, _ = fit(sum)
+			span = W
+		}
+		rowHeight := max(1, int(math.Round(h)))
+
+		// Place edges from cumulative aspect so rounding never accumulates
+		// and justified rows end exactly at the canvas edge. Every photo
+		// keeps at least one pixel, however narrow, and the last one takes
+		// up the rounding remainder.
+		end := int(math.Round(span))
+		x := 0
+		for k := i; k < j; k++ {
+			next := max(x+1, int(math.Round(span*(prefix[k+1]-prefix[i])/sum)))
+			if rest := j - 1 - k; rest > 0 {
+				next = min(next, end-rest)
+			} else {
+				next = end
+			}
+			rect := image.Rect(x, y, next, y+rowHeight)
+			lay.cells[k] = cell{rect: rect, ratio: float64(rect.Dx()) / float64(rect.Dy()), row: r, col: k - i}
+			x = next
+		}
+		y += rowHeight
+	}
+	lay.height = y
+	return lay
+}
+
+// aspectsFor probes the upright aspect ratio of every source and applies the
+// error policy: "fail" returns the first failure, "placeholder" keeps failed
+// photos at the target tile shape, and "skip" reports them for removal. The
+// headers read along the way are returned by source name for Plan to reuse.
+func (b *Builder) aspectsFor(ctx context.Context, srcs []Source) (map[string]float64, map[string]header, []Failure, error) {
+	ratios, hdrs, errs, err := b.probeAspects(ctx, srcs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	fallback := float64(b.opts.TileWidth) / float64(b.opts.rowHeight())
+
+	aspects := make(map[string]float64, len(srcs))
+	headers := make(map[string]header, len(srcs))
+	var failures []Failure
+	for i, s := range srcs {
+		if errs[i] == nil {
+			aspects[s.Name()] = ratios[i]
+			headers[s.Name()] = hdrs[i]
+			continue
+		}
+		if _, ok := s.(rejectedSource); ok {
+			// Already failed the input checks; rendering reports it.
+			aspects[s.Name()] = fallback
+			continue
+		}
+		failures = append(failures, Failure{Source: s.Name(), Err: errs[i]})
+		if b.opts.onError() == OnErrorFail {
+			return nil, nil, failures, errs[i]
+		}
+		aspects[s.Name()] = fallback
+	}
+	return aspects, headers, failures, nil
+}
+
+// probeAspects reads each image's header and EXIF orientation to find its
+// upright aspect ratio without decoding pixels. Probes run on `jobs` workers;
+// the returned error is only set when ctx was cancelled.
+func (b *Builder) probeAspects(ctx context.Context, srcs []Source) ([]float64, []header, []error, error) {
+	ratios := make([]float64, len(srcs))
+	headers := make([]header, len(srcs))
+	errs := make([]error, len(srcs))
+	b.stage(StageProbe, len(srcs))
+
+	sem := make(chan struct{}, b.opts.jobs())
+	var wg sync.WaitGroup
+	for i, s := range srcs {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ratios[i], headers[i], errs[i] = probeAspect(s)
+			b.item(StageProbe, len(srcs), s.Name(), errs[i])
+		}()
+	}
+	wg.Wait()
+	return ratios, headers, errs, ctx.Err()
+}
+
+// probeAspect returns the upright width/height ratio of a single image and
+// its header.
+func probeAspect(src Source) (float64, header, error) {
+	f, err := src.Open()
+	if err != nil {
+		return 0, header{}, fmt.Errorf("open image %q: %w", src.Name(), err)
+	}
+	defer f.Close()
+
+	orientation := imageOrientation(f)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, header{}, fmt.Errorf("rewind image %q: %w", src.Name(), err)
+	}
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, header{}, fmt.Errorf("decode config %q: %w", src.Name(), err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return 0, header{}, fmt.Errorf("image %q has no pixels", src.Name())
+	}
+
+	w, h := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	return float64(w) / float64(h), header{size: image.Pt(cfg.Width, cfg.Height), format: format}, nil
+}
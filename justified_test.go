@@ -0,0 +1,102 @@
+package yearcollage
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestJustifiedLayoutUniform(t *testing.T) {
+	// Ten squares in a 300px canvas at 100px rows: three full rows and a
+	// ragged last row holding the tenth image at the target height.
+	ratios := make([]float64, 10)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	lay := justifiedLayout(ratios, 300, 100, 0)
+
+	if lay.height != 400 {
+		t.Fatalf("height = %d, want 400", lay.height)
+	}
+	for i, c := range lay.cells {
+		want := image.Rect((i%3)*100, (i/3)*100, (i%3)*100+100, (i/3)*100+100)
+		if c.rect != want {
+			t.Fatalf("cell %d = %v, want %v", i, c.rect, want)
+		}
+	}
+}
+
+func TestJustifiedLayoutRowsFillWidth(t *testing.T) {
+	ratios := []float64{1.5, 0.66, 1.33, 1.78, 0.75, 1, 2.4, 1.5, 0.8, 1.33, 1.5, 0.66}
+	const width, target = 900, 150
+	lay := justifiedLayout(ratios, width, target, 0)
+
+	rows := map[int][]cell{}
+	for i, c := range lay.cells {
+		if c.rect.Empty() {
+			t.Fatalf("cell %d is empty", i)
+		}
+		rows[c.rect.Min.Y] = append(rows[c.rect.Min.Y], c)
+		// Without a crop allowance, every cell keeps its photo's shape up to
+		// pixel rounding.
+		if got := float64(c.rect.Dx()) / float64(c.rect.Dy()); math.Abs(got-ratios[i])/ratios[i] > 0.05 {
+			t.Fatalf("cell %d aspect = %.3f, want ~%.3f", i, got, ratios[i])
+		}
+	}
+
+	lastY := 0
+	for y := range rows {
+		lastY = max(lastY, y)
+	}
+	for y, cells := range rows {
+		right := cells[len(cells)-1].rect.Max.X
+		if y != lastY && right != width {
+			t.Fatalf("row at y=%d ends at %d, want %d", y, right, width)
+		}
+		if h := cells[0].rect.Dy(); h < target/2 || h > target*2 {
+			t.Fatalf("row at y=%d has height %d, far from target %d", y, h, target)
+		}
+	}
+}
+
+func TestJustifiedLayoutMaxCrop(t *testing.T) {
+	// A lone 2.5:1 panorama in a 300px canvas would be 120px tall; allowing a
+	// 20% crop lets the row hit the 100px target instead.
+	ratios := []float64{2.5, 2.5, 1}
+
+	loose := justifiedLayout(ratios, 300, 100, 0)
+	if h := loose.cells[0].rect.Dy(); h != 120 {
+		t.Fatalf("uncropped row height = %d, want 120", h)
+	}
+
+	cropped := justifiedLayout(ratios, 300, 100, 0.2)
+	if h := cropped.cells[0].rect.Dy(); h != 100 {
+		t.Fatalf("cropped row height = %d, want 100", h)
+	}
+	if w := cropped.cells[0].rect.Dx(); w != 300 {
+		t.Fatalf("cropped row width = %d, want 300", w)
+	}
+}
+
+func TestJustifiedLayoutKeepsNarrowPhotos(t *testing.T) {
+	// A 1:20 photo among wide ones in a low row is well under a pixel wide
+	// once the row is scaled; it must still get a cell.
+	ratios := make([]float64, 20)
+	for i := range ratios {
+		ratios[i] = 4
+	}
+	ratios[2] = 0.05
+	const width, target = 300, 8
+	lay := justifiedLayout(ratios, width, target, 0.2)
+
+	rowEnd := map[int]int{}
+	for i, c := range lay.cells {
+		if c.rect.Dx() < 1 || c.rect.Dy() < 1 {
+			t.Fatalf("cell %d = %v, want at least 1×1", i, c.rect)
+		}
+		rowEnd[c.rect.Min.Y] = max(rowEnd[c.rect.Min.Y], c.rect.Max.X)
+	}
+	if end := rowEnd[lay.cells[2].rect.Min.Y]; end != width {
+		t.Fatalf("row with the narrow photo ends at %d, want %d", end, width)
+	}
+}
@@ -0,0 +1,135 @@
+package yearcollage
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"time"
+)
+
+// Layout selects how images are arranged on the canvas.
+type Layout string
+
+// Layouts.
+const (
+	LayoutGrid      Layout = "grid"      // uniform tiles, left→right, top→bottom
+	LayoutJustified Layout = "justified" // rows of equal height keeping each photo's aspect
+	LayoutCalendar  Layout = "calendar"  // one photo per day of the busiest year
+)
+
+// layout places every image of a render onto the canvas.
+type layout struct {
+	width, height int
+	// cells holds one destination per image, in the same order as the paths.
+	// Images with an empty rectangle are not part of the collage.
+	cells []cell
+	// columns and rows span the grid; justified layouts count the most
+	// photos in one row as columns. slots is the number of cells that can
+	// hold a photo.
+	columns, rows, slots int
+	// tileWidth and tileHeight are the cell size of grid and calendar
+	// layouts; justified tiles vary, so they stay zero.
+	tileWidth, tileHeight int
+	// decorate, when set, paints backgrounds and labels before any tile is
+	// drawn. It works in canvas coordinates and must respect dst's bounds.
+	decorate func(dst draw.Image)
+	// overlay, when set, paints on top of the tiles, under the same rules.
+	overlay func(dst draw.Image)
+}
+
+// imageMeta holds per-image facts gathered before layout, keyed by path.
+// Only the maps a layout mode needs are populated.
+type imageMeta struct {
+	aspects map[string]float64
+	times   map[string]time.Time
+	// headers holds what the input-size check or the justified probe read,
+	// for Plan's memory estimate.
+	headers map[string]header
+}
+
+// cell is a single image's destination rectangle on the canvas and the aspect
+// ratio its source is cropped to before scaling into that rectangle. row and
+// col place it in the layout's grid, counted from zero.
+type cell struct {
+	rect     image.Rectangle
+	ratio    float64
+	row, col int
+}
+
+// buildLayout computes the layout for the configured mode. Justified layouts
+// need each photo's upright aspect ratio and calendars its capture time; both
+// are looked up in meta by source name.
+func (b *Builder) buildLayout(srcs []Source, meta imageMeta) (layout, error) {
+	names := make([]string, len(srcs))
+	for i, s := range srcs {
+		names[i] = s.Name()
+	}
+
+	switch b.opts.layout() {
+	case LayoutCalendar:
+		ratio := b.opts.tileAspect()
+		cellHeight := int(math.Round(float64(b.opts.TileWidth) / ratio))
+		times := make([]time.Time, len(names))
+		for i, n := range names {
+			times[i] = meta.times[n]
+		}
+		year := busiestYear(times)
+		lay := calendarLayout(names, times, year, b.opts.calendar(), b.opts.TileWidth, cellHeight, ratio)
+		b.logf("Calendar %d (%s): %d of %d days have photos", year, b.opts.calendar(), lay.filled(), time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay())
+		return lay, nil
+	case LayoutJustified:
+		ratios := make([]float64, len(names))
+		for i, n := range names {
+			ratios[i] = meta.aspects[n]
+		}
+		rowHeight := b.opts.rowHeight()
+		width := b.opts.Columns * b.opts.TileWidth
+		lay := justifiedLayout(ratios, width, rowHeight, b.opts.MaxCrop)
+		b.logf("Justified layout: width=%d, target row height=%d, max crop=%.0f%% -> %d rows, height=%d", width, rowHeight, b.opts.MaxCrop*100, lay.rows, lay.height)
+		return lay, nil
+	default:
+		g, err := b.gridFor(len(names))
+		if err != nil {
+			return layout{}, err
+		}
+		return gridLayout(g, len(names)), nil
+	}
+}
+
+// gridLayout places n images left→right, top→bottom into uniform tiles.
+func gridLayout(g grid, n int) layout {
+	lay := layout{
+		width:   g.tileWidth * g.columns,
+		height:  g.tileHeight * g.rows,
+		cells:   make([]cell, n),
+		columns: g.columns,
+		rows:    g.rows,
+		slots:   g.columns * g.rows,
+
+		tileWidth:  g.tileWidth,
+		tileHeight: g.tileHeight,
+	}
+	for idx := range lay.cells {
+		col := idx % g.columns
+		row := idx / g.columns
+		offset := image.Pt(col*g.tileWidth, row*g.tileHeight)
+		lay.cells[idx] = cell{
+			rect:  image.Rectangle{Min: offset, Max: offset.Add(image.Pt(g.tileWidth, g.tileHeight))},
+			ratio: g.tileRatio,
+			row:   row,
+			col:   col,
+		}
+	}
+	return lay
+}
+
+// filled counts the cells that are drawn.
+func (l layout) filled() int {
+	n := 0
+	for _, c := range l.cells {
+		if !c.rect.Empty() {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,140 @@
+package yearcollage
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+)
+
+// checkInputSizes applies the error policy to photos whose header declares
+// more than MaxInputPixels pixels, or cannot be read, before any of them is
+// decoded: "fail" returns the first, "skip" reports and drops them, and
+// "placeholder" keeps their slot but lets them fail when rendered.
+// The headers read are returned by source name for Plan to reuse.
+func (b *Builder) checkInputSizes(ctx context.Context, srcs []Source) ([]Source, map[string]header, []Failure, error) {
+	hdrs, errs, err := b.readHeaders(ctx, srcs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	kept := make([]Source, 0, len(srcs))
+	headers := make(map[string]header, len(srcs))
+	var failures []Failure
+	for i, s := range srcs {
+		size := hdrs[i].size
+		pixels := int64(size.X) * int64(size.Y)
+		err := errs[i]
+		if err == nil {
+			headers[s.Name()] = hdrs[i]
+		}
+		if err == nil && pixels <= b.opts.MaxInputPixels {
+			kept = append(kept, s)
+			continue
+		}
+		if err == nil {
+			err = fmt.Errorf("image %q is %d×%d, %s, more than max-input-pixels %d; raise max-input-pixels or leave it out with on-error skip",
+				s.Name(), size.X, size.Y, megapixels(pixels), b.opts.MaxInputPixels)
+		}
+		switch b.opts.onError() {
+		case OnErrorFail:
+			return nil, nil, append(failures, Failure{Source: s.Name(), Err: err}), err
+		case OnErrorPlaceholder:
+			kept = append(kept, rejectedSource{s, err})
+		default:
+			failures = append(failures, Failure{Source: s.Name(), Err: err})
+		}
+	}
+	if n := len(srcs) - len(kept); n > 0 {
+		b.logf("Skipped %d images that are unreadable or larger than max-input-pixels %d", n, b.opts.MaxInputPixels)
+	}
+	return kept, headers, failures, nil
+}
+
+// header is what an image's header declares: its stored pixel size and the
+// format name it is registered under with package image.
+type header struct {
+	size   image.Point
+	format string
+}
+
+// readHeaders reads every source's header on `jobs` workers, with an error
+// for each header that cannot be read. The returned error is only set when
+// ctx was cancelled.
+func (b *Builder) readHeaders(ctx context.Context, srcs []Source) ([]header, []error, error) {
+	headers := make([]header, len(srcs))
+	errs := make([]error, len(srcs))
+	b.stage(StageProbe, len(srcs))
+
+	sem := make(chan struct{}, b.opts.jobs())
+	var wg sync.WaitGroup
+	for i, s := range srcs {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			headers[i], errs[i] = readHeader(s)
+			b.item(StageProbe, len(srcs), s.Name(), errs[i])
+		}()
+	}
+	wg.Wait()
+	return headers, errs, ctx.Err()
+}
+
+// readHeader returns the dimensions and format declared by an image's header.
+func readHeader(src Source) (header, error) {
+	f, err := src.Open()
+	if err != nil {
+		return header{}, fmt.Errorf("open image %q: %w", src.Name(), err)
+	}
+	defer f.Close()
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return header{}, fmt.Errorf("read header of %q: %w", src.Name(), err)
+	}
+	return header{size: image.Pt(cfg.Width, cfg.Height), format: format}, nil
+}
+
+// rejectedSource stands in for a photo that is over MaxInputPixels or has
+// an unreadable header under OnErrorPlaceholder: it keeps its slot, but
+// never opens, so it is never decoded. Its failure is recorded once, when
+// its tile is rendered.
+type rejectedSource struct {
+	Source
+	err error
+}
+
+func (s rejectedSource) Open() (io.ReadSeekCloser, error) { return nil, s.err }
+
+// checkCanvasSize rejects a layout with more than MaxOutputPixels pixels
+// before its canvas is allocated, naming the options that size it.
+func (b *Builder) checkCanvasSize(lay layout) error {
+	pixels := int64(lay.width) * int64(lay.height)
+	if b.opts.MaxOutputPixels == 0 || pixels <= b.opts.MaxOutputPixels {
+		return nil
+	}
+	var cause, advice string
+	switch b.opts.layout() {
+	case LayoutJustified:
+		cause = fmt.Sprintf("columns %d × tile-width %d wide, rows about row-height %d tall", b.opts.Columns, b.opts.TileWidth, b.opts.rowHeight())
+		advice = "lower columns, tile-width or row-height, or set max-images"
+	case LayoutCalendar:
+		cause = fmt.Sprintf("day cells tile-width %d wide", b.opts.TileWidth)
+		advice = "lower tile-width"
+	default:
+		cause = fmt.Sprintf("%d images in %d columns of tile-width %d", len(lay.cells), lay.width/b.opts.TileWidth, b.opts.TileWidth)
+		advice = "lower tile-width or columns, or set max-images"
+	}
+	return fmt.Errorf("the %d×%d canvas is %s, more than max-output-pixels %d (%s); %s, or raise max-output-pixels",
+		lay.width, lay.height, megapixels(pixels), b.opts.MaxOutputPixels, cause, advice)
+}
+
+// megapixels formats a pixel count for error messages.
+func megapixels(pixels int64) string {
+	return fmt.Sprintf("%.1f MP", float64(pixels)/1e6)
+}
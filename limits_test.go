@@ -0,0 +1,114 @@
+package yearcollage
+
+import (
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderChecksInputPixels(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	sources := []Source{
+		pngSource(t, "small.png", 10, 10, red),
+		pngSource(t, "huge.png", 40, 30, blue),
+	}
+	cases := []struct {
+		policy  ErrorPolicy
+		wantErr bool       // the render fails
+		slot    color.RGBA // the second tile
+	}{
+		{OnErrorFail, true, color.RGBA{}},
+		{OnErrorSkip, false, color.RGBA{}},
+		{OnErrorPlaceholder, false, placeholderBackground},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			b, err := New(Options{TileWidth: 8, Columns: 2, OnError: tc.policy, MaxInputPixels: 1000})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			res, err := b.Render(context.Background(), sources)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), `"huge.png" is 40×30`) || !strings.Contains(err.Error(), "max-input-pixels 1000") {
+					t.Fatalf("Render error = %v, want the size of huge.png and the limit", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if len(res.Failures) != 1 || res.Failures[0].Source != "huge.png" {
+				t.Fatalf("failures = %+v, want huge.png once", res.Failures)
+			}
+			if got := res.Image.RGBAAt(9, 0); got != tc.slot {
+				t.Fatalf("second slot = %v, want %v", got, tc.slot)
+			}
+		})
+	}
+}
+
+func TestRenderChecksOutputPixels(t *testing.T) {
+	sources := []Source{
+		pngSource(t, "a.png", 10, 10, color.RGBA{A: 255}),
+		pngSource(t, "b.png", 10, 10, color.RGBA{A: 255}),
+		pngSource(t, "c.png", 10, 10, color.RGBA{A: 255}),
+	}
+	b, err := New(Options{TileWidth: 4000, Columns: 3, MaxOutputPixels: 10_000_000})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = b.Render(context.Background(), sources)
+	for _, want := range []string{"12000×4000 canvas is 48.0 MP", "max-output-pixels 10000000", "3 columns of tile-width 4000", "lower tile-width"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Render error = %v, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestRenderFailsUnreadableHeadersUnderInputLimit(t *testing.T) {
+	sources := []Source{
+		pngSource(t, "small.png", 10, 10, color.RGBA{255, 0, 0, 255}),
+		Bytes("broken.png", []byte("\x89PNG\r\n\x1a\ntruncated"), time.Time{}),
+	}
+	for _, policy := range []ErrorPolicy{OnErrorFail, OnErrorSkip, OnErrorPlaceholder} {
+		t.Run(string(policy), func(t *testing.T) {
+			b, err := New(Options{TileWidth: 8, Columns: 2, OnError: policy, MaxInputPixels: 1000})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			res, err := b.Render(context.Background(), sources)
+			if policy == OnErrorFail {
+				if err == nil || !strings.Contains(err.Error(), `read header of "broken.png"`) {
+					t.Fatalf("Render error = %v, want the unreadable header", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if len(res.Failures) != 1 || res.Failures[0].Source != "broken.png" || !strings.Contains(res.Failures[0].Err.Error(), "read header") {
+				t.Fatalf("failures = %+v, want broken.png's header once", res.Failures)
+			}
+		})
+	}
+}
+
+func TestRenderReportsOversizedPlaceholderOnce(t *testing.T) {
+	sources := []Source{
+		pngSource(t, "small.png", 10, 10, color.RGBA{255, 0, 0, 255}),
+		pngSource(t, "huge.png", 40, 30, color.RGBA{0, 0, 255, 255}),
+	}
+	b, err := New(Options{TileWidth: 8, Columns: 2, Layout: LayoutJustified, OnError: OnErrorPlaceholder, MaxInputPixels: 1000})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := b.Render(context.Background(), sources)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(res.Failures) != 1 || res.Failures[0].Source != "huge.png" {
+		t.Fatalf("failures = %+v, want huge.png once", res.Failures)
+	}
+}
@@ -0,0 +1,324 @@
+package yearcollage
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"runtime"
+	"time"
+)
+
+// Options configures a Builder. The zero value is not usable: at least
+// TileWidth and either Columns or CollageAspect must be set.
+type Options struct {
+	// TileWidth is the width of a grid tile in pixels; justified layouts
+	// use Columns×TileWidth as the canvas width.
+	TileWidth int
+	// TileAspect is the tile width/height ratio. Zero means 1:1.
+	TileAspect float64
+	// Columns is the number of grid columns.
+	Columns int
+	// CollageAspect, when set, picks the column count and tile aspect so the
+	// whole grid approaches this width/height ratio; TileAspect is ignored.
+	CollageAspect float64
+
+	Layout Layout
+	// RowHeight is the justified target row height; zero derives it from
+	// TileWidth and TileAspect.
+	RowHeight int
+	// MaxCrop (0–0.5) is the fraction of a photo the justified layout may
+	// crop to keep rows near RowHeight.
+	MaxCrop  float64
+	Calendar CalendarMode
+
+	Crop CropMode
+	Fit  FitMode
+	// Background fills the space around photos with FitContain. Nil means
+	// black.
+	Background color.Color
+	// EmbeddedThumbs renders JPEGs from their EXIF thumbnail (usually
+	// 160px wide) instead of decoding the full photo, when the thumbnail
+	// shows the whole picture and has enough pixels for the tile.
+	EmbeddedThumbs bool
+	// Resample is the filter that scales photos into tiles; empty means
+	// ResampleFast.
+	Resample Resample
+	// LinearLight scales in linear light rather than on gamma-encoded
+	// sRGB values, which keeps fine bright detail from darkening at the
+	// cost of slower tiles.
+	LinearLight bool
+	// Sharpen applies an unsharp mask to each tile after scaling.
+	Sharpen Sharpen
+
+	Sort SortMode
+	// From and To, when set, keep only photos captured at or after From and
+	// before To. The capture time is the EXIF timestamp, falling back to
+	// Source.ModTime.
+	From, To time.Time
+	// Dedupe drops duplicate photos before layout, keeping the best-quality
+	// copy. Empty means DedupeOff.
+	Dedupe DedupeMode
+	// DedupeThreshold is the largest Hamming distance between two 64-bit
+	// perceptual hashes that still counts as a duplicate. Zero matches only
+	// identical hashes; DefaultDedupeThreshold suits most libraries.
+	DedupeThreshold int
+	// MinSharpness drops photos whose Quality.Sharpness is lower; zero
+	// keeps all.
+	MinSharpness float64
+	// BurstWindow, when set, keeps only the sharpest photo of each burst:
+	// shots captured at most this far apart from the previous one.
+	BurstWindow time.Duration
+	// MaxImages, when set, keeps at most this many photos after sorting,
+	// chosen by Sample so the collage covers the whole timeline.
+	MaxImages int
+	// Sample is the strategy for MaxImages; empty means SampleEvenTime.
+	Sample SampleStrategy
+	// Seed makes SampleRandom picks reproducible.
+	Seed int64
+	// Jobs is the number of images processed in parallel; zero means
+	// GOMAXPROCS.
+	Jobs int
+	// CacheDir, when set, stores finished tiles on disk so later renders
+	// only process changed photos.
+	CacheDir string
+	OnError  ErrorPolicy
+	// MaxInputPixels, when set, rejects photos whose header declares more
+	// pixels or cannot be read, before any of them is decoded; the error
+	// policy decides what happens to them.
+	MaxInputPixels int64
+	// MaxOutputPixels, when set, fails the render before the canvas is
+	// allocated if it would have more pixels.
+	MaxOutputPixels int64
+	// MaxMemory, when set, caps the bytes of canvas held in memory. Encode
+	// renders larger PNG and TIFF collages in horizontal strips that fit and
+	// streams them to the output; for other formats, and for Render, a
+	// larger canvas is an error. Photos being decoded, at most Jobs at a
+	// time, come on top.
+	MaxMemory int64
+
+	// Progress, when set, receives an Event for every stage and image. Calls
+	// are serialized, so the callback needs no locking of its own.
+	Progress func(Event)
+	// Logf, when set, receives human-readable log lines.
+	Logf func(format string, args ...any)
+}
+
+// Validate reports the first option that makes no sense for the renderer.
+func (o Options) Validate() error {
+	if o.TileWidth <= 0 {
+		return fmt.Errorf("tile-width must be greater than zero")
+	}
+	if o.Columns <= 0 && o.CollageAspect == 0 && o.Layout != LayoutCalendar {
+		return fmt.Errorf("either columns or collage-aspect must be set")
+	}
+	if o.Columns < 0 {
+		return fmt.Errorf("columns must not be negative")
+	}
+	if o.Jobs < 0 {
+		return fmt.Errorf("jobs must not be negative")
+	}
+	if o.MaxMemory < 0 {
+		return fmt.Errorf("max-memory must not be negative")
+	}
+	if o.MaxInputPixels < 0 || o.MaxOutputPixels < 0 {
+		return fmt.Errorf("max-input-pixels and max-output-pixels must not be negative")
+	}
+	if !validRatio(o.TileAspect) || !validRatio(o.CollageAspect) {
+		return fmt.Errorf("aspect ratios must be positive")
+	}
+	switch o.Sort {
+	case SortNone, SortModTime, SortName, SortEXIF:
+	default:
+		return fmt.Errorf("invalid sort mode %q (use \"time\", \"name\", or \"exif\")", o.Sort)
+	}
+	switch o.OnError {
+	case "", OnErrorFail, OnErrorSkip, OnErrorPlaceholder:
+	default:
+		return fmt.Errorf("invalid on-error policy %q (use \"fail\", \"skip\", or \"placeholder\")", o.OnError)
+	}
+	switch o.Layout {
+	case "", LayoutGrid:
+	case LayoutJustified:
+		if o.CollageAspect != 0 {
+			return fmt.Errorf("collage-aspect is not supported with the justified layout; set columns and tile-width instead")
+		}
+		if o.Columns <= 0 {
+			return fmt.Errorf("justified layout needs columns > 0 (canvas width = columns × tile-width)")
+		}
+	case LayoutCalendar:
+		if o.CollageAspect != 0 {
+			return fmt.Errorf("collage-aspect is not supported with the calendar layout; set tile-width and tile-aspect instead")
+		}
+	default:
+		return fmt.Errorf("invalid layout %q (use \"grid\", \"justified\", or \"calendar\")", o.Layout)
+	}
+	switch o.Crop {
+	case "", CropCenter, CropTop, CropEntropy, CropSaliency:
+	default:
+		return fmt.Errorf("invalid crop mode %q (use \"center\", \"entropy\", \"saliency\", or \"top\")", o.Crop)
+	}
+	switch o.Fit {
+	case "", FitCrop, FitContain, FitBlurFill:
+	default:
+		return fmt.Errorf("invalid fit mode %q (use \"crop\", \"contain\", or \"blur-fill\")", o.Fit)
+	}
+	switch o.Resample {
+	case "", ResampleFast, ResampleNearest, ResampleBilinear, ResampleCatmullRom, ResampleLanczos:
+	default:
+		return fmt.Errorf("invalid resample filter %q (use \"fast\", \"nearest\", \"bilinear\", \"catmullrom\", or \"lanczos\")", o.Resample)
+	}
+	if math.IsNaN(o.Sharpen.Amount) || math.IsInf(o.Sharpen.Amount, 0) || math.IsNaN(o.Sharpen.Radius) || math.IsInf(o.Sharpen.Radius, 0) {
+		return fmt.Errorf("sharpen amount and radius must be finite numbers")
+	}
+	if o.Sharpen.Amount < 0 || o.Sharpen.Radius < 0 {
+		return fmt.Errorf("sharpen amount and radius must not be negative")
+	}
+	if o.Sharpen.Radius > maxSharpenRadius {
+		return fmt.Errorf("sharpen radius %g is too large (at most %g tile pixels)", o.Sharpen.Radius, float64(maxSharpenRadius))
+	}
+	if o.Sharpen.Amount > 0 && o.Sharpen.Radius == 0 {
+		return fmt.Errorf("sharpen needs a radius greater than zero")
+	}
+	switch o.Calendar {
+	case "", CalendarDays, CalendarWeeks:
+	default:
+		return fmt.Errorf("invalid calendar mode %q (use \"days\" or \"weeks\")", o.Calendar)
+	}
+	switch o.Dedupe {
+	case "", DedupeOff, DedupeExact, DedupePerceptual:
+	default:
+		return fmt.Errorf("invalid dedupe mode %q (use \"off\", \"exact\", or \"perceptual\")", o.Dedupe)
+	}
+	if o.DedupeThreshold < 0 || o.DedupeThreshold > 63 {
+		return fmt.Errorf("dedupe-threshold must be between 0 and 63")
+	}
+	if o.MinSharpness < 0 {
+		return fmt.Errorf("min-sharpness must not be negative")
+	}
+	if o.BurstWindow < 0 {
+		return fmt.Errorf("burst window must not be negative")
+	}
+	if o.MaxImages < 0 {
+		return fmt.Errorf("max-images must not be negative")
+	}
+	switch o.Sample {
+	case "", SampleEvenTime, SamplePerDay, SamplePerWeek, SamplePerMonth, SampleRandom:
+	default:
+		return fmt.Errorf("invalid sample strategy %q (use \"even-time\", \"per-day\", \"per-week\", \"per-month\", or \"random\")", o.Sample)
+	}
+	if !o.From.IsZero() && !o.To.IsZero() && !o.From.Before(o.To) {
+		return fmt.Errorf("date range %s is empty", o.dateRange())
+	}
+	if o.RowHeight < 0 {
+		return fmt.Errorf("row-height must not be negative")
+	}
+	if o.MaxCrop < 0 || o.MaxCrop > 0.5 {
+		return fmt.Errorf("max-crop must be between 0 and 0.5")
+	}
+	if int(math.Round(float64(o.TileWidth)/o.tileAspect())) <= 0 {
+		return fmt.Errorf("computed tile height is non-positive; check tile-width/tile-aspect")
+	}
+	return nil
+}
+
+// validRatio accepts zero (unset) or a finite positive ratio.
+func validRatio(r float64) bool {
+	return r == 0 || (r > 0 && !math.IsInf(r, 0))
+}
+
+// tileAspect reports the tile width/height ratio, defaulting to square.
+func (o Options) tileAspect() float64 {
+	if o.TileAspect == 0 {
+		return 1
+	}
+	return o.TileAspect
+}
+
+// layout reports the layout mode, defaulting to the uniform grid.
+func (o Options) layout() Layout {
+	if o.Layout == "" {
+		return LayoutGrid
+	}
+	return o.Layout
+}
+
+// calendar reports the calendar grid mode, defaulting to months × days.
+func (o Options) calendar() CalendarMode {
+	if o.Calendar == "" {
+		return CalendarDays
+	}
+	return o.Calendar
+}
+
+// crop reports the crop mode, defaulting to center crops.
+func (o Options) crop() CropMode {
+	if o.Crop == "" {
+		return CropCenter
+	}
+	return o.Crop
+}
+
+// fit reports the fit mode, defaulting to cropping tiles to fill.
+func (o Options) fit() FitMode {
+	if o.Fit == "" {
+		return FitCrop
+	}
+	return o.Fit
+}
+
+// resample reports the scaling filter, defaulting to fast bilinear.
+func (o Options) resample() Resample {
+	if o.Resample == "" {
+		return ResampleFast
+	}
+	return o.Resample
+}
+
+// dedupe reports the dedupe mode, defaulting to off.
+func (o Options) dedupe() DedupeMode {
+	if o.Dedupe == "" {
+		return DedupeOff
+	}
+	return o.Dedupe
+}
+
+// sample reports the MaxImages strategy, defaulting to even-time.
+func (o Options) sample() SampleStrategy {
+	if o.Sample == "" {
+		return SampleEvenTime
+	}
+	return o.Sample
+}
+
+// background reports the colour behind contained photos, defaulting to black.
+func (o Options) background() color.RGBA {
+	if o.Background == nil {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBAModel.Convert(o.Background).(color.RGBA)
+}
+
+// onError reports the error policy, defaulting to fail.
+func (o Options) onError() ErrorPolicy {
+	if o.OnError == "" {
+		return OnErrorFail
+	}
+	return o.OnError
+}
+
+// jobs reports the number of workers, defaulting to GOMAXPROCS when unset.
+func (o Options) jobs() int {
+	if o.Jobs > 0 {
+		return o.Jobs
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// rowHeight reports the justified target row height: the explicit option, or
+// the tile height implied by TileWidth and TileAspect.
+func (o Options) rowHeight() int {
+	if o.RowHeight > 0 {
+		return o.RowHeight
+	}
+	return int(math.Round(float64(o.TileWidth) / o.tileAspect()))
+}
@@ -0,0 +1,193 @@
+package yearcollage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// normalizeOrientation rotates or flips the image according to the EXIF/TIFF
+// orientation value. This mirrors the mappings defined in the EXIF spec so we
+// only need to conditionally rotate rather than re-encode metadata later.
+//
+// *image.RGBA and *image.NRGBA keep their type, *image.YCbCr becomes
+// *image.RGBA; all three are transformed straight on their Pix slices. Other
+// types are converted to *image.RGBA first.
+func normalizeOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	switch src := img.(type) {
+	case *image.RGBA:
+		dst := image.NewRGBA(orientedRect(b, orientation))
+		orientPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, b.Dx(), b.Dy(), orientation)
+		return dst
+	case *image.NRGBA:
+		dst := image.NewNRGBA(orientedRect(b, orientation))
+		orientPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, b.Dx(), b.Dy(), orientation)
+		return dst
+	case *image.YCbCr:
+		return orientYCbCr(src, orientation)
+	default:
+		rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+		return normalizeOrientation(rgba, orientation)
+	}
+}
+
+// orientedRect returns the bounds of b's content after the orientation is
+// applied, anchored at the origin. Orientations 5–8 swap the axes.
+func orientedRect(b image.Rectangle, orientation int) image.Rectangle {
+	if orientation >= 5 {
+		return image.Rect(0, 0, b.Dy(), b.Dx())
+	}
+	return image.Rect(0, 0, b.Dx(), b.Dy())
+}
+
+// pixelSteps describes where the pixels of a w×h source land in a 4-byte per
+// pixel destination with the given stride: the offset of source pixel (0, 0)
+// and how far the destination offset moves per source column and per source
+// row.
+func pixelSteps(orientation, w, h, stride int) (origin, stepX, stepY int) {
+	const px = 4
+	switch orientation {
+	case 2: // mirror horizontal
+		return (w - 1) * px, -px, stride
+	case 3: // rotate 180
+		return (h-1)*stride + (w-1)*px, -px, -stride
+	case 4: // mirror vertical
+		return (h - 1) * stride, px, -stride
+	case 5: // transpose
+		return 0, stride, px
+	case 6: // rotate 90 CW
+		return (h - 1) * px, stride, -px
+	case 7: // transverse
+		return (w-1)*stride + (h-1)*px, -stride, -px
+	case 8: // rotate 90 CCW
+		return (w - 1) * stride, -stride, px
+	default:
+		return 0, px, stride
+	}
+}
+
+// orientPix copies a w×h block of 4-byte pixels from src into dst, applying
+// the orientation. Both slices start at their block's top-left pixel.
+func orientPix(dst []uint8, dstStride int, src []uint8, srcStride, w, h, orientation int) {
+	origin, stepX, stepY := pixelSteps(orientation, w, h, dstStride)
+	for y := 0; y < h; y++ {
+		row := src[y*srcStride : y*srcStride+w*4]
+		d := origin + y*stepY
+		if stepX == 4 {
+			// Rows stay rows in the same direction (orientation 4).
+			copy(dst[d:d+len(row)], row)
+			continue
+		}
+		for x := 0; x < len(row); x += 4 {
+			s := row[x : x+4 : x+4]
+			p := dst[d : d+4 : d+4]
+			p[0], p[1], p[2], p[3] = s[0], s[1], s[2], s[3]
+			d += stepX
+		}
+	}
+}
+
+// orientYCbCr converts a YCbCr image to RGBA while applying the orientation,
+// reading the planes directly.
+func orientYCbCr(src *image.YCbCr, orientation int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(orientedRect(b, orientation))
+	origin, stepX, stepY := pixelSteps(orientation, w, h, dst.Stride)
+	for y := 0; y < h; y++ {
+		d := origin + y*stepY
+		for x := 0; x < w; x++ {
+			yi := src.YOffset(b.Min.X+x, b.Min.Y+y)
+			ci := src.COffset(b.Min.X+x, b.Min.Y+y)
+			// Same conversion as color.RGBAModel, so results match the
+			// generic path exactly.
+			r, g, bl, _ := color.YCbCr{Y: src.Y[yi], Cb: src.Cb[ci], Cr: src.Cr[ci]}.RGBA()
+			p := dst.Pix[d : d+4 : d+4]
+			p[0], p[1], p[2], p[3] = uint8(r>>8), uint8(g>>8), uint8(bl>>8), 0xff
+			d += stepX
+		}
+	}
+	return dst
+}
+
+// fitOriented fits a photo stored with the given EXIF orientation into the
+// tile. Center crops, contain and blur-fill pick the same window whichever
+// way is up, so that window is mapped back to storage coordinates, scaled
+// there, and only the tile-sized result is rotated. Top and content-aware
+// crops need the upright photo first. It also returns the window of img the
+// tile shows, in img's stored coordinates.
+func fitOriented(img image.Image, orientation int, spec tileSpec) (*image.RGBA, image.Rectangle) {
+	b := img.Bounds()
+	if orientation < 2 || orientation > 8 || b.Empty() {
+		window := tileWindow(img, spec)
+		return fitWindow(img, window, spec), window
+	}
+	contained := spec.fit == FitContain || spec.fit == FitBlurFill
+	if !contained && spec.crop != "" && spec.crop != CropCenter {
+		upright := normalizeOrientation(img, orientation)
+		window := tileWindow(upright, spec)
+		stored := storedRect(window.Sub(upright.Bounds().Min), orientation, b.Dx(), b.Dy()).Add(b.Min)
+		return fitWindow(upright, window, spec), stored
+	}
+
+	uw, uh := b.Dx(), b.Dy()
+	if orientation >= 5 {
+		uw, uh = uh, uw
+	}
+	if contained {
+		// Shrink the whole photo to its upright contain size, then let
+		// fitTile add the background around it.
+		r := containRect(uw, uh, spec.width, spec.height)
+		small := scaleStored(img, b, r.Dx(), r.Dy(), orientation, spec)
+		return fitTile(normalizeOrientation(small, orientation), spec), b
+	}
+
+	window := cropRect(image.Rect(0, 0, uw, uh), spec.ratio, CropCenter)
+	src := storedRect(window, orientation, b.Dx(), b.Dy()).Add(b.Min)
+	return normalizeOrientation(scaleStored(img, src, spec.width, spec.height, orientation, spec), orientation).(*image.RGBA), src
+}
+
+// scaleStored scales the src part of a stored image to the size that becomes
+// width×height once the orientation is applied.
+func scaleStored(img image.Image, src image.Rectangle, width, height, orientation int, spec tileSpec) *image.RGBA {
+	if orientation >= 5 {
+		width, height = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	spec.scale(dst, dst.Bounds(), img, src, xdraw.Over)
+	return dst
+}
+
+// storedRect maps a rectangle in upright coordinates back to the coordinates
+// of a w×h image stored with the given orientation.
+func storedRect(r image.Rectangle, orientation, w, h int) image.Rectangle {
+	pt := func(x, y int) image.Point {
+		switch orientation {
+		case 2:
+			return image.Pt(w-x, y)
+		case 3:
+			return image.Pt(w-x, h-y)
+		case 4:
+			return image.Pt(x, h-y)
+		case 5:
+			return image.Pt(y, x)
+		case 6:
+			return image.Pt(y, h-x)
+		case 7:
+			return image.Pt(w-y, h-x)
+		case 8:
+			return image.Pt(w-y, x)
+		default:
+			return image.Pt(x, y)
+		}
+	}
+	a, c := pt(r.Min.X, r.Min.Y), pt(r.Max.X, r.Max.Y)
+	return image.Rectangle{Min: a, Max: c}.Canon()
+}
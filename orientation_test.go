@@ -0,0 +1,273 @@
+package yearcollage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// TestNormalizeOrientation ensures each EXIF orientation code maps to the pixel
+// arrangement described in the spec so the helpers stay stable.
+func TestNormalizeOrientation(t *testing.T) {
+	cases := []struct {
+		name        string
+		orientation int
+		want        [][]int
+	}{
+		{
+			name:        "orientation 1 (identity)",
+			orientation: 1,
+			want: [][]int{
+				{0, 1},
+				{2, 3},
+				{4, 5},
+			},
+		},
+		{
+			name:        "orientation 2 (mirror horizontal)",
+			orientation: 2,
+			want: [][]int{
+				{1, 0},
+				{3, 2},
+				{5, 4},
+			},
+		},
+		{
+			name:        "orientation 3 (rotate 180)",
+			orientation: 3,
+			want: [][]int{
+				{5, 4},
+				{3, 2},
+				{1, 0},
+			},
+		},
+		{
+			name:        "orientation 4 (mirror vertical)",
+			orientation: 4,
+			want: [][]int{
+				{4, 5},
+				{2, 3},
+				{0, 1},
+			},
+		},
+		{
+			name:        "orientation 5 (mirror horizontal + rotate 270 CW)",
+			orientation: 5,
+			want: [][]int{
+				{0, 2, 4},
+				{1, 3, 5},
+			},
+		},
+		{
+			name:        "orientation 6 (rotate 90 CW)",
+			orientation: 6,
+			want: [][]int{
+				{4, 2, 0},
+				{5, 3, 1},
+			},
+		},
+		{
+			name:        "orientation 7 (mirror horizontal + rotate 90 CW)",
+			orientation: 7,
+			want: [][]int{
+				{5, 3, 1},
+				{4, 2, 0},
+			},
+		},
+		{
+			name:        "orientation 8 (rotate 90 CCW)",
+			orientation: 8,
+			want: [][]int{
+				{1, 3, 5},
+				{0, 2, 4},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := makeLabeledImage()
+			got := normalizeOrientation(img, tc.orientation)
+			if diff := compareGrid(got, tc.want); diff != "" {
+				t.Fatalf("normalizeOrientation mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func makeLabeledImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	val := 0
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 2; x++ {
+			img.Set(x, y, color.NRGBA{uint8(val), 0, 0, 255})
+			val++
+		}
+	}
+	return img
+}
+
+func compareGrid(img image.Image, want [][]int) string {
+	b := img.Bounds()
+	got := make([][]int, b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		row := make([]int, b.Dx())
+		for x := 0; x < b.Dx(); x++ {
+			r, _, _, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			row[x] = int(r >> 8)
+		}
+		got[y] = row
+	}
+	if reflect.DeepEqual(got, want) {
+		return ""
+	}
+	return fmt.Sprintf("got %v want %v", got, want)
+}
+
+// orientReference is the straightforward per-pixel transform the fast paths
+// must match.
+func orientReference(img image.Image, orientation int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(orientedRect(b, orientation))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			switch orientation {
+			case 2:
+				dx = w - 1 - x
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dy = h - 1 - y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// orientationInputs returns a patterned 7×5 image in each supported layout,
+// offset from the origin to catch Min handling.
+func orientationInputs() map[string]image.Image {
+	r := image.Rect(3, 2, 10, 7)
+	rgba := image.NewRGBA(r)
+	nrgba := image.NewNRGBA(r)
+	gray := image.NewGray(r)
+	ycc := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.NRGBA{uint8(30 * x), uint8(40 * y), uint8(7 * x * y), uint8(128 + 10*x)}
+			rgba.Set(x, y, c)
+			nrgba.SetNRGBA(x, y, c)
+			gray.Set(x, y, c)
+			ycc.Y[ycc.YOffset(x, y)] = uint8(20*x + 9*y)
+			ycc.Cb[ycc.COffset(x, y)] = uint8(60 + 15*x)
+			ycc.Cr[ycc.COffset(x, y)] = uint8(200 - 11*y)
+		}
+	}
+	return map[string]image.Image{"rgba": rgba, "nrgba": nrgba, "ycbcr": ycc, "gray": gray}
+}
+
+func TestNormalizeOrientationFastPaths(t *testing.T) {
+	for name, img := range orientationInputs() {
+		for orientation := 1; orientation <= 8; orientation++ {
+			t.Run(fmt.Sprintf("%s/%d", name, orientation), func(t *testing.T) {
+				got := normalizeOrientation(img, orientation)
+				want := orientReference(img, orientation)
+				gb, wb := got.Bounds(), want.Bounds()
+				if gb.Size() != wb.Size() {
+					t.Fatalf("size = %v, want %v", gb.Size(), wb.Size())
+				}
+				for y := 0; y < wb.Dy(); y++ {
+					for x := 0; x < wb.Dx(); x++ {
+						g := color.RGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y))
+						if w := want.RGBAAt(x, y); g != w {
+							t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, g, w)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestFitOrientedMatchesUprightFit(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 120; x++ {
+			src.SetRGBA(x, y, color.RGBA{uint8(2 * x), uint8(3 * y), 90, 255})
+		}
+	}
+	for _, fit := range []FitMode{FitCrop, FitContain} {
+		for orientation := 1; orientation <= 8; orientation++ {
+			t.Run(fmt.Sprintf("%s/%d", fit, orientation), func(t *testing.T) {
+				spec := tileSpec{width: 30, height: 20, ratio: 1.5, crop: CropCenter, fit: fit}
+				got, _ := fitOriented(src, orientation, spec)
+				want := fitTile(normalizeOrientation(src, orientation), spec)
+				if got.Bounds() != want.Bounds() {
+					t.Fatalf("bounds = %v, want %v", got.Bounds(), want.Bounds())
+				}
+				// The window is mapped exactly, so only the order of scaling
+				// and rotating differs.
+				for i := range got.Pix {
+					if d := int(got.Pix[i]) - int(want.Pix[i]); d != 0 {
+						t.Fatalf("byte %d = %d, want %d", i, got.Pix[i], want.Pix[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkNormalizeOrientation(b *testing.B) {
+	r := image.Rect(0, 0, 1600, 1200)
+	inputs := []struct {
+		name string
+		img  image.Image
+	}{
+		{"rgba", image.NewRGBA(r)},
+		{"nrgba", image.NewNRGBA(r)},
+		{"ycbcr", image.NewYCbCr(r, image.YCbCrSubsampleRatio420)},
+	}
+	for _, in := range inputs {
+		for _, orientation := range []int{3, 6} {
+			b.Run(fmt.Sprintf("%s/%d", in.name, orientation), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					normalizeOrientation(in.img, orientation)
+				}
+			})
+			// The generic At/Set loop the fast paths replace.
+			b.Run(fmt.Sprintf("%s/%d/generic", in.name, orientation), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					orientReference(in.img, orientation)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkFitOriented(b *testing.B) {
+	img := image.NewYCbCr(image.Rect(0, 0, 1600, 1200), image.YCbCrSubsampleRatio420)
+	spec := tileSpec{width: 200, height: 200, ratio: 1, crop: CropCenter, fit: FitCrop}
+	b.Run("rotate-tile", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fitOriented(img, 6, spec)
+		}
+	})
+	b.Run("rotate-photo", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fitTile(normalizeOrientation(img, 6), spec)
+		}
+	})
+}
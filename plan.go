@@ -0,0 +1,124 @@
+package yearcollage
+
+import (
+	"context"
+	"math"
+)
+
+// bytesPerPixel are rough encoded sizes of a photo collage per canvas pixel,
+// used to estimate output files. TIFF is uncompressed RGBA.
+var bytesPerPixel = map[Format]float64{
+	FormatJPEG: 0.35,
+	FormatPNG:  2,
+	FormatTIFF: 4,
+}
+
+// Plan describes the collage Encode would produce for the same sources,
+// worked out without rendering a tile.
+type Plan struct {
+	Format Format
+	// Images is the number of photos placed on the canvas after filtering,
+	// deduplication and sampling.
+	Images int
+	// Columns and Rows span the layout. Justified layouts count the most
+	// photos in one row as Columns.
+	Columns, Rows int
+	// TileWidth and TileHeight are the cell size of grid and calendar
+	// layouts; justified tiles vary, so they are zero.
+	TileWidth, TileHeight int
+	// Width and Height are the canvas size.
+	Width, Height int
+	// EmptyCells counts grid cells after the last photo, or calendar days
+	// without one.
+	EmptyCells int
+	// Strips is the number of strips Encode renders under MaxMemory; 1 means
+	// the canvas is held whole.
+	Strips int
+	// Memory estimates the peak of the pixel buffers: the canvas or its
+	// tallest strip, plus Jobs photos decoded at the size their tiles need,
+	// which for JPEGs is a DCT-scaled fraction of the full resolution.
+	Memory int64
+	// OutputSize is a rough estimate of the encoded file size.
+	OutputSize int64
+}
+
+// Plan runs everything Encode does before rendering — collecting headers,
+// filtering, sorting and laying out — and reports the result. It fails
+// where Encode would, for example when the canvas exceeds MaxOutputPixels
+// or cannot be streamed under MaxMemory.
+func (b *Builder) Plan(ctx context.Context, format Format, sources []Source) (*Plan, error) {
+	srcs, meta, _, err := b.prepare(ctx, sources)
+	if err != nil {
+		return nil, err
+	}
+	lay, err := b.buildLayout(srcs, meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.checkCanvasSize(lay); err != nil {
+		return nil, err
+	}
+
+	p := &Plan{
+		Format:     format,
+		Images:     lay.filled(),
+		Columns:    lay.columns,
+		Rows:       lay.rows,
+		TileWidth:  lay.tileWidth,
+		TileHeight: lay.tileHeight,
+		Width:      lay.width,
+		Height:     lay.height,
+		EmptyCells: lay.slots - lay.filled(),
+		Strips:     1,
+		OutputSize: int64(math.Ceil(bytesPerPixel[format] * float64(lay.width) * float64(lay.height))),
+	}
+
+	canvas := canvasBytes(lay)
+	if b.opts.MaxMemory > 0 && canvas > b.opts.MaxMemory {
+		if !format.streams() {
+			return nil, b.errCannotStream(lay, format)
+		}
+		strips, err := planStrips(lay, b.opts.MaxMemory)
+		if err != nil {
+			return nil, err
+		}
+		p.Strips, canvas = len(strips), 0
+		for _, s := range strips {
+			canvas = max(canvas, 4*int64(lay.width)*int64(s.y1-s.y0))
+		}
+	}
+
+	headers := meta.headers
+	if headers == nil {
+		// Neither the input-size check nor a justified layout read them.
+		hdrs, _, err := b.readHeaders(ctx, srcs)
+		if err != nil {
+			return nil, err
+		}
+		headers = make(map[string]header, len(srcs))
+		for i, s := range srcs {
+			headers[s.Name()] = hdrs[i]
+		}
+	}
+	var largest int64
+	for i, s := range srcs {
+		if r := lay.cells[i].rect; !r.Empty() {
+			largest = max(largest, decodeBytes(headers[s.Name()], r.Dx(), r.Dy()))
+		}
+	}
+	p.Memory = canvas + int64(min(b.opts.jobs(), len(srcs)))*largest
+	return p, nil
+}
+
+// decodeBytes estimates the pixels decodeForTile holds for a photo with
+// header h and a tileWidth×tileHeight tile. JPEGs are counted at their DCT
+// scale; the orientation is not known here, so the larger decode of either
+// way up is taken.
+func decodeBytes(h header, tileWidth, tileHeight int) int64 {
+	w, ht := h.size.X, h.size.Y
+	if h.format == "jpeg" {
+		denom := min(jpegDenominator(w, ht, 1, tileWidth, tileHeight), jpegDenominator(w, ht, 6, tileWidth, tileHeight))
+		w, ht = (w+denom-1)/denom, (ht+denom-1)/denom
+	}
+	return 4 * int64(w) * int64(ht)
+}
@@ -0,0 +1,103 @@
+package yearcollage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanGrid(t *testing.T) {
+	var sources []Source
+	for i := 0; i < 10; i++ {
+		sources = append(sources, pngSource(t, fmt.Sprintf("img-%d.png", i), 40, 30, color.RGBA{A: 255}))
+	}
+	b, err := New(Options{TileWidth: 30, TileAspect: 1.5, Columns: 4, Jobs: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p, err := b.Plan(context.Background(), FormatTIFF, sources)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	want := Plan{
+		Format: FormatTIFF, Images: 10, Columns: 4, Rows: 3, TileWidth: 30, TileHeight: 20,
+		Width: 120, Height: 60, EmptyCells: 2, Strips: 1,
+		Memory:     120*60*4 + 2*40*30*4,
+		OutputSize: 120 * 60 * 4,
+	}
+	if *p != want {
+		t.Fatalf("plan = %+v, want %+v", *p, want)
+	}
+
+	// Under MaxMemory the canvas is planned in strips of whole tile rows.
+	if b, err = New(Options{TileWidth: 30, TileAspect: 1.5, Columns: 4, Jobs: 2, MaxMemory: 120 * 4 * 45}); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if p, err = b.Plan(context.Background(), FormatPNG, sources); err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if p.Strips != 2 || p.Memory != 120*40*4+2*40*30*4 {
+		t.Fatalf("streamed plan = %d strips, %d bytes; want 2 strips of 40 rows", p.Strips, p.Memory)
+	}
+	if _, err := b.Plan(context.Background(), FormatJPEG, sources); err == nil || !strings.Contains(err.Error(), "cannot be written in strips") {
+		t.Fatalf("JPEG plan error = %v, want the streaming refusal", err)
+	}
+}
+
+func TestPlanJustifiedHasNoTileSize(t *testing.T) {
+	sources := []Source{
+		pngSource(t, "wide.png", 60, 20, color.RGBA{A: 255}),
+		pngSource(t, "tall.png", 20, 60, color.RGBA{A: 255}),
+	}
+	b, err := New(Options{TileWidth: 30, Columns: 4, Layout: LayoutJustified})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p, err := b.Plan(context.Background(), FormatPNG, sources)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if p.TileWidth != 0 || p.TileHeight != 0 {
+		t.Fatalf("justified tile size = %d×%d, want 0×0", p.TileWidth, p.TileHeight)
+	}
+}
+
+func TestPlanReusesProbeAndScalesJPEGs(t *testing.T) {
+	var sources []Source
+	for i := 0; i < 4; i++ {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 400, 300)), nil); err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, Bytes(fmt.Sprintf("img-%d.jpg", i), buf.Bytes(), time.Time{}))
+	}
+	probes := 0
+	b, err := New(Options{
+		TileWidth: 30, TileAspect: 1.5, Columns: 2, Jobs: 2, MaxInputPixels: 1e6,
+		Progress: func(e Event) {
+			if e.Kind == EventStage && e.Stage == StageProbe {
+				probes++
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p, err := b.Plan(context.Background(), FormatPNG, sources)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if probes != 1 {
+		t.Fatalf("plan ran %d probe stages, want 1", probes)
+	}
+	// The 30×20 tiles need 1/8 of each 400×300 JPEG: 50×38 pixels.
+	if want := int64(60*40*4 + 2*50*38*4); p.Memory != want {
+		t.Fatalf("memory = %d, want %d", p.Memory, want)
+	}
+}
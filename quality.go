@@ -0,0 +1,271 @@
+package yearcollage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// qualitySize is the long edge, in pixels, larger photos are scaled down to
+// before analysis so scores compare across cameras and resolutions. Smaller
+// ones are measured as they are: upscaling would blur them and cost them
+// sharpness.
+const qualitySize = 512
+
+// Exposure thresholds on the 0–255 luminance scale.
+const (
+	shadowLevel    = 8
+	highlightLevel = 247
+)
+
+// Quality describes the sharpness and exposure of one photo.
+type Quality struct {
+	// Sharpness is the variance of the Laplacian of the luminance. Blurry
+	// photos have few edges and score low; crisp ones reach the hundreds.
+	Sharpness float64
+	// Brightness is the mean luminance from 0 (black) to 1 (white).
+	Brightness float64
+	// Shadows and Highlights are the fractions of nearly black and nearly
+	// white, clipped pixels.
+	Shadows, Highlights float64
+}
+
+// Rejection records a source dropped by the quality filters and why.
+type Rejection struct {
+	Source string // the source's Name
+	Reason string
+}
+
+// analyzed reports whether any option needs the quality analysis.
+func (o Options) analyzed() bool {
+	return o.MinSharpness > 0 || o.BurstWindow > 0
+}
+
+// filterQuality analyses every source, drops those below MinSharpness and
+// keeps only the sharpest shot of each burst. Bursts are runs of photos
+// captured at most BurstWindow apart, using the capture times keyed by name.
+// Sources that cannot be analysed are kept so the error policy handles them.
+func (b *Builder) filterQuality(ctx context.Context, srcs []Source, times map[string]time.Time) ([]Source, []Rejection, map[string]Quality, error) {
+	qualities, err := b.analyze(ctx, srcs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	b.logQuality(qualities)
+
+	reasons := make(map[string]string)
+	var blurry, bursts int
+	if limit := b.opts.MinSharpness; limit > 0 {
+		for _, s := range srcs {
+			if q, ok := qualities[s.Name()]; ok && q.Sharpness < limit {
+				reasons[s.Name()] = fmt.Sprintf("blurry: sharpness %.1f below %.1f", q.Sharpness, limit)
+				blurry++
+			}
+		}
+	}
+	if b.opts.BurstWindow > 0 {
+		for _, burst := range b.bursts(srcs, times, qualities, reasons) {
+			best := slices.MaxFunc(burst, func(a, c Source) int {
+				return cmp.Compare(qualities[a.Name()].Sharpness, qualities[c.Name()].Sharpness)
+			})
+			for _, s := range burst {
+				if s.Name() == best.Name() {
+					continue
+				}
+				reasons[s.Name()] = fmt.Sprintf("burst: %s is sharper (%.1f vs %.1f)",
+					best.Name(), qualities[best.Name()].Sharpness, qualities[s.Name()].Sharpness)
+				bursts++
+			}
+		}
+	}
+
+	var rejected []Rejection
+	kept := make([]Source, 0, len(srcs))
+	for _, s := range srcs {
+		if reason, ok := reasons[s.Name()]; ok {
+			rejected = append(rejected, Rejection{Source: s.Name(), Reason: reason})
+			continue
+		}
+		kept = append(kept, s)
+	}
+
+	b.logf("Quality filter: dropped %d blurry and %d burst shots of %d images", blurry, bursts, len(srcs))
+	for _, r := range rejected {
+		b.logf("  %s: %s", r.Source, r.Reason)
+	}
+	return kept, rejected, qualities, nil
+}
+
+// bursts groups the analysed, not yet rejected sources with a capture time
+// into runs where each shot follows the previous one within BurstWindow.
+// Only runs of two or more are returned.
+func (b *Builder) bursts(srcs []Source, times map[string]time.Time, qualities map[string]Quality, rejected map[string]string) [][]Source {
+	var timed []Source
+	for _, s := range srcs {
+		_, analysed := qualities[s.Name()]
+		_, dropped := rejected[s.Name()]
+		if analysed && !dropped && !times[s.Name()].IsZero() {
+			timed = append(timed, s)
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return times[timed[i].Name()].Before(times[timed[j].Name()])
+	})
+
+	var groups [][]Source
+	for i := 0; i < len(timed); {
+		j := i + 1
+		for j < len(timed) && times[timed[j].Name()].Sub(times[timed[j-1].Name()]) <= b.opts.BurstWindow {
+			j++
+		}
+		if j-i > 1 {
+			groups = append(groups, timed[i:j])
+		}
+		i = j
+	}
+	return groups
+}
+
+// logQuality summarises the scores so MinSharpness can be tuned.
+func (b *Builder) logQuality(qualities map[string]Quality) {
+	if len(qualities) == 0 {
+		return
+	}
+	sharpness := make([]float64, 0, len(qualities))
+	var dark, bright int
+	for _, q := range qualities {
+		sharpness = append(sharpness, q.Sharpness)
+		if q.Brightness < 0.15 || q.Shadows > 0.5 {
+			dark++
+		}
+		if q.Brightness > 0.85 || q.Highlights > 0.5 {
+			bright++
+		}
+	}
+	slices.Sort(sharpness)
+	b.logf("Sharpness: min %.1f, median %.1f, max %.1f; %d dark and %d bright photos",
+		sharpness[0], sharpness[len(sharpness)/2], sharpness[len(sharpness)-1], dark, bright)
+}
+
+// analyze measures every source in parallel. The map holds only the sources
+// that could be decoded.
+func (b *Builder) analyze(ctx context.Context, srcs []Source) (map[string]Quality, error) {
+	qualities := make([]Quality, len(srcs))
+	errs := make([]error, len(srcs))
+	b.stage(StageAnalyze, len(srcs))
+
+	sem := make(chan struct{}, b.opts.jobs())
+	var wg sync.WaitGroup
+	for i, s := range srcs {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			qualities[i], errs[i] = analyzeSource(s)
+			b.item(StageAnalyze, len(srcs), s.Name(), errs[i])
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]Quality, len(srcs))
+	for i, s := range srcs {
+		if errs[i] == nil {
+			byName[s.Name()] = qualities[i]
+		}
+	}
+	return byName, nil
+}
+
+// analyzeSource decodes one photo at analysis size and measures it.
+func analyzeSource(src Source) (Quality, error) {
+	f, err := src.Open()
+	if err != nil {
+		return Quality{}, fmt.Errorf("open image %q: %w", src.Name(), err)
+	}
+	defer f.Close()
+
+	// Both measures ignore rotation, so the EXIF orientation is not needed.
+	img, err := decodeForTile(f, 1, qualitySize, qualitySize)
+	if err != nil {
+		return Quality{}, fmt.Errorf("decode image %q: %w", src.Name(), err)
+	}
+	return measureQuality(img), nil
+}
+
+// measureQuality scales img down to qualitySize on its long edge, when it
+// is larger, and measures the luminance.
+func measureQuality(img image.Image) Quality {
+	b := img.Bounds()
+	if b.Empty() {
+		return Quality{}
+	}
+	var gray *image.Gray
+	if max(b.Dx(), b.Dy()) <= qualitySize {
+		gray = image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(gray, gray.Bounds(), img, b.Min, draw.Src)
+	} else {
+		w, h := qualitySize, qualitySize
+		if b.Dx() >= b.Dy() {
+			h = max(1, b.Dy()*qualitySize/b.Dx())
+		} else {
+			w = max(1, b.Dx()*qualitySize/b.Dy())
+		}
+		gray = image.NewGray(image.Rect(0, 0, w, h))
+		draw.BiLinear.Scale(gray, gray.Bounds(), img, b, draw.Src, nil)
+	}
+
+	var q Quality
+	var sum float64
+	var shadows, highlights int
+	for _, v := range gray.Pix {
+		sum += float64(v)
+		if v <= shadowLevel {
+			shadows++
+		}
+		if v >= highlightLevel {
+			highlights++
+		}
+	}
+	n := float64(len(gray.Pix))
+	q.Brightness = sum / n / 255
+	q.Shadows = float64(shadows) / n
+	q.Highlights = float64(highlights) / n
+	q.Sharpness = laplacianVariance(gray)
+	return q
+}
+
+// laplacianVariance convolves g with the 4-neighbour Laplacian kernel and
+// returns the variance of the response over the interior pixels.
+func laplacianVariance(g *image.Gray) float64 {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+	if w < 3 || h < 3 {
+		return 0
+	}
+	var sum, sumSq float64
+	for y := 1; y < h-1; y++ {
+		row := g.Pix[y*g.Stride:]
+		up := g.Pix[(y-1)*g.Stride:]
+		down := g.Pix[(y+1)*g.Stride:]
+		for x := 1; x < w-1; x++ {
+			l := 4*float64(row[x]) - float64(row[x-1]) - float64(row[x+1]) - float64(up[x]) - float64(down[x])
+			sum += l
+			sumSq += l * l
+		}
+	}
+	n := float64((w - 2) * (h - 2))
+	mean := sum / n
+	return sumSq/n - mean*mean
+}
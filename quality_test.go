@@ -0,0 +1,100 @@
+package yearcollage
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func checker(fx, fy float64) uint8 { return uint8((int(fx*24)+int(fy*24))%2) * 255 }
+func soft(fx, fy float64) uint8    { return uint8(127 + 100*math.Sin(fx*6)*math.Cos(fy*4)) }
+
+func TestMeasureQuality(t *testing.T) {
+	sharp := decodePNG(t, patternPNG(t, 600, 400, checker))
+	blurry := decodePNG(t, patternPNG(t, 600, 400, soft))
+
+	qs, qb := measureQuality(sharp), measureQuality(blurry)
+	if qs.Sharpness <= 10*qb.Sharpness {
+		t.Fatalf("sharpness: checkerboard %.1f, soft gradient %.1f; want a clear gap", qs.Sharpness, qb.Sharpness)
+	}
+	// Half the checkerboard is black and half white.
+	if math.Abs(qs.Brightness-0.5) > 0.05 || qs.Shadows < 0.3 || qs.Highlights < 0.3 {
+		t.Fatalf("checkerboard exposure = %+v", qs)
+	}
+	if qb.Shadows != 0 || qb.Highlights != 0 {
+		t.Fatalf("soft gradient should not clip: %+v", qb)
+	}
+}
+
+func TestMeasureQualityKeepsSmallPhotos(t *testing.T) {
+	// A one-pixel checkerboard is as sharp as it gets; blowing it up to
+	// qualitySize would smear it.
+	small := image.NewGray(image.Rect(0, 0, 120, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 120; x++ {
+			small.SetGray(x, y, color.Gray{Y: uint8(255 * ((x + y) % 2))})
+		}
+	}
+	if got, want := measureQuality(small).Sharpness, laplacianVariance(small); got != want {
+		t.Fatalf("small photo sharpness = %.1f, want %.1f as measured unscaled", got, want)
+	}
+}
+
+func TestRenderQualityFilters(t *testing.T) {
+	at := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.Local)
+	sharp, blurry := patternPNG(t, 120, 80, checker), patternPNG(t, 120, 80, soft)
+	sources := []Source{
+		Bytes("a.png", blurry, at),
+		Bytes("b.png", sharp, at.Add(time.Second)),
+		Bytes("c.png", blurry, at.Add(2*time.Second)),
+		Bytes("d.png", blurry, at.Add(time.Hour)),
+	}
+
+	cases := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"min sharpness", Options{MinSharpness: 500}, []string{"a.png", "c.png", "d.png"}},
+		// d.png is an hour later and so not part of the burst.
+		{"burst", Options{BurstWindow: 1500 * time.Millisecond}, []string{"a.png", "c.png"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.TileWidth, tc.opts.Columns, tc.opts.Sort = 10, 1, SortName
+			b, err := New(tc.opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			res, err := b.Render(context.Background(), sources)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			var got []string
+			for _, r := range res.Rejected {
+				got = append(got, r.Source)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("rejected = %v (%+v), want %v", got, res.Rejected, tc.want)
+			}
+			if len(res.Quality) != len(sources) {
+				t.Fatalf("quality for %d sources, want %d", len(res.Quality), len(sources))
+			}
+		})
+	}
+}
+
+func decodePNG(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	return img
+}
@@ -0,0 +1,414 @@
+package yearcollage
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // first frame only
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+
+	"github.com/luceast/yearcollage/internal/cache"
+	_ "github.com/luceast/yearcollage/internal/heif"
+)
+
+// grid describes the uniform tile layout for a given number of images.
+type grid struct {
+	columns, rows         int
+	tileWidth, tileHeight int
+	tileRatio             float64
+}
+
+// gridFor derives columns, rows and tile size for n images from the options.
+func (b *Builder) gridFor(n int) (grid, error) {
+	columns := b.opts.Columns
+	var tileRatio float64
+
+	if b.opts.CollageAspect != 0 {
+		// When a collage aspect is provided we compute a column count that best
+		// matches the overall shape and derive the tile aspect from it.
+		columns = pickColumnsForCollage(n, b.opts.CollageAspect)
+		if columns <= 0 {
+			return grid{}, fmt.Errorf("computed columns is non-positive")
+		}
+		rows := (n + columns - 1) / columns
+		tileRatio = b.opts.CollageAspect * float64(rows) / float64(columns)
+		b.logf("Collage aspect %.4f -> columns=%d, rows=%d, tile-aspect=%.4f (tile aspect ignored)", b.opts.CollageAspect, columns, rows, tileRatio)
+	} else {
+		tileRatio = b.opts.tileAspect()
+		b.logf("Tile aspect %.4f", tileRatio)
+	}
+
+	tileWidth := b.opts.TileWidth
+	// Scale tile height from width so we always respect the intended ratio,
+	// even when tileRatio came from collage-aspect inference.
+	tileHeight := int(math.Round(float64(tileWidth) / tileRatio))
+	if tileHeight <= 0 {
+		return grid{}, fmt.Errorf("computed tile height is non-positive; check tile/collage aspect")
+	}
+
+	return grid{
+		columns:    columns,
+		rows:       (n + columns - 1) / columns,
+		tileWidth:  tileWidth,
+		tileHeight: tileHeight,
+		tileRatio:  tileRatio,
+	}, nil
+}
+
+// renderTiles processes the images at the given indices on a bounded pool of
+// workers and draws each tile into its layout cell, which must lie inside
+// canvas. Slots are fixed by index, so the result is identical to a serial
+// render regardless of scheduling; at most `jobs` decoded images are held in
+// memory at once. The caller announces StageRender.
+//
+// It returns a Tile record for every photo drawn, in index order. Under the
+// "fail" policy the first failure (by index) aborts the render and is
+// returned as err. Under "skip" and "placeholder" every image is attempted and
+// the failures are returned in index order; placeholder slots get a neutral
+// tile, skipped slots stay empty for the caller to re-flow. Cancelling ctx stops
+// handing out work and returns ctx.Err().
+func (b *Builder) renderTiles(ctx context.Context, canvas *image.RGBA, srcs []Source, lay layout, r *renderer, todo []int) ([]Tile, []Failure, error) {
+	jobs := min(b.opts.jobs(), len(todo))
+	failFast := r.onError == OnErrorFail
+
+	indices := make(chan int)
+	errs := make([]error, len(srcs))
+	placed := make([]*Tile, len(srcs))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indices {
+				c := lay.cells[idx]
+				if failed.Load() || ctx.Err() != nil {
+					continue
+				}
+				if c.rect.Empty() {
+					b.item(StageRender, len(srcs), srcs[idx].Name(), nil)
+					continue
+				}
+				src := srcs[idx]
+				spec := r.spec(c)
+				tile, info, err := r.tile(src, spec)
+				b.item(StageRender, len(srcs), src.Name(), err)
+				if err == nil {
+					t, ok := r.times[src.Name()]
+					if !ok {
+						t = b.captureTime(src)
+					}
+					placed[idx] = &Tile{
+						Source:      src.Name(),
+						Time:        t,
+						Row:         c.row,
+						Column:      c.col,
+						Rect:        c.rect,
+						Crop:        info.crop,
+						Orientation: info.orientation,
+					}
+				} else {
+					errs[idx] = err
+					if failFast {
+						failed.Store(true)
+						continue
+					}
+					if r.onError != OnErrorPlaceholder {
+						continue
+					}
+					tile = placeholderTile(spec.width, spec.height)
+				}
+
+				// Cells never overlap, so concurrent draws touch disjoint pixels.
+				draw.Draw(canvas, c.rect, tile, image.Point{}, draw.Src)
+			}
+		}()
+	}
+
+feed:
+	for _, idx := range todo {
+		if failed.Load() {
+			break
+		}
+		select {
+		case indices <- idx:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	var tiles []Tile
+	for _, t := range placed {
+		if t != nil {
+			tiles = append(tiles, *t)
+		}
+	}
+	var failures []Failure
+	for idx, err := range errs {
+		if err == nil {
+			continue
+		}
+		failures = append(failures, Failure{Source: srcs[idx].Name(), Err: err})
+		if failFast {
+			return tiles, failures, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return tiles, failures, err
+	}
+	return tiles, failures, nil
+}
+
+// tileSpec captures every render parameter that influences a tile's pixels.
+// Anything added here must also feed cacheParams so cached tiles stay valid.
+type tileSpec struct {
+	width, height int
+	ratio         float64
+	crop          CropMode
+	fit           FitMode
+	background    color.RGBA
+	thumbs        bool // may use the EXIF thumbnail when it is large enough
+	resample      Resample
+	linear        bool // scale in linear light
+	sharpen       Sharpen
+}
+
+// cacheParams lists the spec fields in a stable form for cache keys. The
+// default filter keeps its original name; "color=srgb" marks tiles whose
+// embedded ICC profile has been applied, so tiles cached before colour
+// management are re-rendered.
+func (s tileSpec) cacheParams() []string {
+	resample := "approx-bilinear"
+	if s.resample != "" && s.resample != ResampleFast {
+		resample = string(s.resample)
+	}
+	params := []string{
+		"v1",
+		fmt.Sprintf("size=%dx%d", s.width, s.height),
+		fmt.Sprintf("ratio=%.6f", s.ratio),
+		"crop=" + string(s.crop),
+		"fit=" + string(s.fit),
+		fmt.Sprintf("background=%02x%02x%02x", s.background.R, s.background.G, s.background.B),
+		"resample=" + resample,
+		"color=srgb",
+	}
+	if s.thumbs {
+		params = append(params, "thumbs")
+	}
+	if s.linear {
+		params = append(params, "linear")
+	}
+	if s.sharpen.enabled() {
+		params = append(params, fmt.Sprintf("sharpen=%.3f,%.3f", s.sharpen.Amount, s.sharpen.Radius))
+	}
+	return params
+}
+
+// renderer turns sources into finished tiles, consulting the on-disk cache
+// when one is configured. It is safe for concurrent use.
+type renderer struct {
+	cache    *cache.Cache
+	crop     CropMode
+	fit      FitMode
+	bg       color.RGBA
+	thumbs   bool
+	resample Resample
+	linear   bool
+	sharpen  Sharpen
+	onError  ErrorPolicy
+	// times holds the capture times read before layout, by name, so tile
+	// records only read the ones that are missing.
+	times  map[string]time.Time
+	logf   func(format string, args ...any)
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// spec returns the tile parameters for a layout cell.
+func (r *renderer) spec(c cell) tileSpec {
+	return tileSpec{
+		width:      c.rect.Dx(),
+		height:     c.rect.Dy(),
+		ratio:      c.ratio,
+		crop:       r.crop,
+		fit:        r.fit,
+		background: r.bg,
+		thumbs:     r.thumbs,
+		resample:   r.resample,
+		linear:     r.linear,
+		sharpen:    r.sharpen,
+	}
+}
+
+// tile returns the rendered tile for src, from cache when possible, and how
+// it was cut from the source. Cached tiles without that info are rendered
+// again.
+func (r *renderer) tile(src Source, spec tileSpec) (*image.RGBA, tileInfo, error) {
+	if o, ok := src.(rejectedSource); ok {
+		return nil, tileInfo{}, o.err
+	}
+	if r.cache == nil {
+		return processTile(src, spec)
+	}
+
+	hash, err := r.sourceHash(src)
+	if err != nil {
+		return nil, tileInfo{}, fmt.Errorf("hash image %q: %w", src.Name(), err)
+	}
+	key := cache.Key(hash, spec.cacheParams()...)
+	if img, note, ok := r.cache.Get(key); ok && img.Bounds().Dx() == spec.width && img.Bounds().Dy() == spec.height {
+		if info, ok := parseTileInfo(note); ok {
+			r.hits.Add(1)
+			return img, info, nil
+		}
+	}
+
+	r.misses.Add(1)
+	img, info, err := processTile(src, spec)
+	if err != nil {
+		return nil, tileInfo{}, err
+	}
+	if err := r.cache.Put(key, img, info.note()); err != nil {
+		// A cache write failure only costs time on the next run.
+		r.logf("warn: cache tile %q: %v", src.Name(), err)
+	}
+	return img, info, nil
+}
+
+// sourceHash returns the content hash of src for cache keys. Files keep
+// the hash recorded in the cache while their size and modification time
+// stay the same, so unchanged photos are not read again.
+func (r *renderer) sourceHash(src Source) (string, error) {
+	f, ok := src.(fileSource)
+	if !ok {
+		return sourceHash(src)
+	}
+	path, err := filepath.Abs(string(f))
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := r.cache.SourceHash(path, info.Size(), info.ModTime()); ok {
+		return hash, nil
+	}
+	hash, err := sourceHash(src)
+	if err != nil {
+		return "", err
+	}
+	if err := r.cache.PutSourceHash(path, info.Size(), info.ModTime(), hash); err != nil {
+		r.logf("warn: cache hash of %q: %v", src.Name(), err)
+	}
+	return hash, nil
+}
+
+// sourceHash returns the content hash of a source for cache keys.
+func sourceHash(src Source) (string, error) {
+	f, err := src.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return cache.Hash(f)
+}
+
+// processTile opens, orients, converts to sRGB and fits a single photo to
+// the tile size, then sharpens it when asked. The returned info maps the crop
+// window back to the full-size source.
+func processTile(src Source, spec tileSpec) (*image.RGBA, tileInfo, error) {
+	f, err := src.Open()
+	if err != nil {
+		return nil, tileInfo{}, fmt.Errorf("open image %q: %w", src.Name(), err)
+	}
+
+	// Read the orientation, colour profile and full size before decoding so
+	// we can rewind and reuse the same file handle for the actual pixel data.
+	x := readExif(f)
+	orientation := exifOrientation(x)
+	toSRGB := colorTransform(f)
+	var size image.Point
+	if _, err := f.Seek(0, io.SeekStart); err == nil {
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			size = image.Pt(cfg.Width, cfg.Height)
+		}
+	}
+	finish := func(img image.Image) (*image.RGBA, tileInfo, error) {
+		tile, window := fitOriented(inSRGB(toSRGB, img), orientation, spec)
+		unsharpMask(tile, spec.sharpen)
+		return tile, tileInfo{crop: sourceWindow(window, img.Bounds(), size), orientation: orientation}, nil
+	}
+	if spec.thumbs {
+		if thumb, ok := embeddedThumbnail(x, f, orientation, spec); ok {
+			_ = f.Close()
+			return finish(thumb)
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, tileInfo{}, fmt.Errorf("rewind image %q: %w", src.Name(), err)
+	}
+
+	img, err := decodeForTile(f, orientation, spec.width, spec.height)
+	_ = f.Close()
+	if err != nil {
+		return nil, tileInfo{}, fmt.Errorf("decode image %q: %w", src.Name(), err)
+	}
+	return finish(img)
+}
+
+// pickColumnsForCollage picks a column count for a target collage aspect.
+// It prefers grids that keep the inferred tile aspect near 1:1 to minimize cropping.
+func pickColumnsForCollage(numImages int, targetCollageRatio float64) int {
+	if numImages <= 0 {
+		return 0
+	}
+
+	ideal := math.Sqrt(float64(numImages) * targetCollageRatio)
+	best := clampInt(int(math.Round(ideal)), 1, numImages)
+	bestScore := math.Abs(tileAspectFromGrid(numImages, best, targetCollageRatio) - 1.0)
+
+	for delta := -3; delta <= 3; delta++ {
+		c := clampInt(int(math.Round(ideal))+delta, 1, numImages)
+		score := math.Abs(tileAspectFromGrid(numImages, c, targetCollageRatio) - 1.0)
+		if score < bestScore || (score == bestScore && c < best) {
+			best = c
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+// tileAspectFromGrid derives the tile aspect ratio implied by a collage grid.
+func tileAspectFromGrid(numImages, columns int, collageRatio float64) float64 {
+	rows := (numImages + columns - 1) / columns
+	return collageRatio * float64(rows) / float64(columns)
+}
+
+// clampInt bounds v to [min, max].
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
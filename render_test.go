@@ -0,0 +1,62 @@
+package yearcollage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luceast/yearcollage/internal/cache"
+)
+
+func TestRendererHashesOnlyChangedFiles(t *testing.T) {
+	c, err := cache.Open(filepath.Join(t.TempDir(), "tiles"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	r := &renderer{cache: c, logf: t.Logf}
+	path := filepath.Join(t.TempDir(), "a.jpg")
+	stamp := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	write := func(data string, mod time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		h, err := r.sourceHash(File(path))
+		if err != nil {
+			t.Fatalf("sourceHash: %v", err)
+		}
+		return h
+	}
+
+	write("first", stamp)
+	first := hash()
+	// Same size and time: the recorded hash stands, the file is not read.
+	write("other", stamp)
+	if got := hash(); got != first {
+		t.Fatalf("unchanged stat re-hashed: %s, want %s", got, first)
+	}
+	write("other", stamp.Add(time.Second))
+	if got := hash(); got == first {
+		t.Fatalf("new modification time kept the old hash")
+	}
+	write("longer", stamp)
+	if got, want := hash(), mustHash(t, "longer"); got != want {
+		t.Fatalf("new size: hash = %s, want %s", got, want)
+	}
+}
+
+func mustHash(t *testing.T, data string) string {
+	t.Helper()
+	h, err := sourceHash(Bytes("x", []byte(data), time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
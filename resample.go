@@ -0,0 +1,161 @@
+package yearcollage
+
+import (
+	"image"
+	"math"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// Resample selects the filter used to scale photos into tiles.
+type Resample string
+
+// Resampling filters, from fastest to sharpest.
+const (
+	ResampleFast       Resample = "fast"       // approximate bilinear; quick but aliases on strong downscales
+	ResampleNearest    Resample = "nearest"    // nearest neighbour, for pixel art
+	ResampleBilinear   Resample = "bilinear"   // bilinear, widened when shrinking so every source pixel counts
+	ResampleCatmullRom Resample = "catmullrom" // bicubic Catmull-Rom; crisp with little ringing
+	ResampleLanczos    Resample = "lanczos"    // Lanczos-3; sharpest, with slight ringing at hard edges
+)
+
+// lanczos is the Lanczos-3 windowed sinc, which x/image/draw does not ship.
+var lanczos = &draw.Kernel{Support: 3, At: func(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+	if t >= 3 {
+		return 0
+	}
+	x := math.Pi * t
+	return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+}}
+
+// interpolator returns the x/image/draw scaler for the filter.
+func (r Resample) interpolator() draw.Interpolator {
+	switch r {
+	case ResampleNearest:
+		return draw.NearestNeighbor
+	case ResampleBilinear:
+		return draw.BiLinear
+	case ResampleCatmullRom:
+		return draw.CatmullRom
+	case ResampleLanczos:
+		return lanczos
+	default:
+		return draw.ApproxBiLinear
+	}
+}
+
+// scale draws the sr part of src into the dr part of dst with the spec's
+// filter. In linear light the pixels are decoded from sRGB to linear 16-bit
+// values first, so averaging bright and dark detail keeps its brightness
+// instead of darkening as it does on gamma-encoded values.
+func (s tileSpec) scale(dst *image.RGBA, dr image.Rectangle, src image.Image, sr image.Rectangle, op draw.Op) {
+	interp := s.resample.interpolator()
+	if !s.linear {
+		interp.Scale(dst, dr, src, sr, op, nil)
+		return
+	}
+	lin := toLinear(src, sr)
+	scaled := image.NewRGBA64(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	interp.Scale(scaled, scaled.Bounds(), lin, lin.Bounds(), draw.Src, nil)
+	draw.Draw(dst, dr, fromLinear(scaled), image.Point{}, op)
+}
+
+// linearTable maps 8-bit sRGB values to 16-bit linear light.
+var linearTable = sync.OnceValue(func() *[256]uint16 {
+	var t [256]uint16
+	for i := range t {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			c /= 12.92
+		} else {
+			c = math.Pow((c+0.055)/1.055, 2.4)
+		}
+		t[i] = uint16(math.Round(c * 0xffff))
+	}
+	return &t
+})
+
+// srgbTable maps 16-bit linear light back to 8-bit sRGB.
+var srgbTable = sync.OnceValue(func() []uint8 {
+	t := make([]uint8, 1<<16)
+	for i := range t {
+		l := float64(i) / 0xffff
+		if l <= 0.0031308 {
+			l *= 12.92
+		} else {
+			l = 1.055*math.Pow(l, 1/2.4) - 0.055
+		}
+		t[i] = uint8(math.Round(l * 255))
+	}
+	return t
+})
+
+// toLinear copies the sr part of src into a linear-light image anchored at
+// the origin. The transfer curve applies to straight colour, so translucent
+// pixels are un-premultiplied around the lookup.
+func toLinear(src image.Image, sr image.Rectangle) *image.RGBA64 {
+	rgba, ok := src.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, sr.Dx(), sr.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, sr.Min, draw.Src)
+		sr = rgba.Bounds()
+	}
+	lut := linearTable()
+	dst := image.NewRGBA64(image.Rect(0, 0, sr.Dx(), sr.Dy()))
+	for y := 0; y < sr.Dy(); y++ {
+		s := rgba.Pix[rgba.PixOffset(sr.Min.X, sr.Min.Y+y):]
+		d := dst.Pix[y*dst.Stride:]
+		for x := 0; x < sr.Dx(); x++ {
+			p := s[x*4 : x*4+4 : x*4+4]
+			q := d[x*8 : x*8+8 : x*8+8]
+			a := uint32(p[3])
+			for c := 0; c < 3; c++ {
+				v := uint32(p[c])
+				switch a {
+				case 0xff:
+					v = uint32(lut[v])
+				case 0:
+					v = 0
+				default:
+					v = uint32(lut[min(v*0xff/a, 0xff)]) * a / 0xff
+				}
+				q[2*c], q[2*c+1] = uint8(v>>8), uint8(v)
+			}
+			q[6], q[7] = p[3], p[3]
+		}
+	}
+	return dst
+}
+
+// fromLinear converts a linear-light image back to 8-bit sRGB.
+func fromLinear(src *image.RGBA64) *image.RGBA {
+	lut := srgbTable()
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		s := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+		d := dst.Pix[y*dst.Stride:]
+		for x := 0; x < b.Dx(); x++ {
+			p := s[x*8 : x*8+8 : x*8+8]
+			q := d[x*4 : x*4+4 : x*4+4]
+			a := uint32(p[6])<<8 | uint32(p[7])
+			for c := 0; c < 3; c++ {
+				v := uint32(p[2*c])<<8 | uint32(p[2*c+1])
+				switch a {
+				case 0xffff:
+					q[c] = lut[v]
+				case 0:
+					q[c] = 0
+				default:
+					q[c] = uint8(uint32(lut[min(v*0xffff/a, 0xffff)]) * a / 0xffff)
+				}
+			}
+			q[3] = uint8(a >> 8)
+		}
+	}
+	return dst
+}
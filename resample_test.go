@@ -0,0 +1,151 @@
+package yearcollage
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestLanczosKernel(t *testing.T) {
+	if got := lanczos.At(0); got != 1 {
+		t.Fatalf("At(0) = %v, want 1", got)
+	}
+	for _, x := range []float64{1, 2} {
+		if got := lanczos.At(x); math.Abs(got) > 1e-12 {
+			t.Fatalf("At(%v) = %v, want 0 at the sinc zeros", x, got)
+		}
+	}
+	if got := lanczos.At(1.5); got >= 0 {
+		t.Fatalf("At(1.5) = %v, want the negative lobe", got)
+	}
+	if got := lanczos.At(3); got != 0 {
+		t.Fatalf("At(3) = %v, want 0 outside the support", got)
+	}
+}
+
+// stripes returns w×h vertical black and white stripes of the given width.
+func stripes(w, h, width int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		c := color.RGBA{A: 0xff}
+		if x/width%2 == 0 {
+			c = color.RGBA{0xff, 0xff, 0xff, 0xff}
+		}
+		fillRect(img, image.Rect(x, 0, x+1, h), c)
+	}
+	return img
+}
+
+// grayRange returns the darkest and brightest red values of img.
+func grayRange(img *image.RGBA) (lo, hi uint8) {
+	lo, hi = 0xff, 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		lo, hi = min(lo, img.Pix[i]), max(hi, img.Pix[i])
+	}
+	return lo, hi
+}
+
+func TestResampleDownscaleAliasing(t *testing.T) {
+	// Stripes far finer than the tile pixels must average to grey; point
+	// samplers pick up whole stripes and produce a moiré of black and white.
+	src := stripes(600, 60, 3)
+	cases := []struct {
+		resample Resample
+		aliased  bool
+	}{
+		{ResampleFast, true},
+		{ResampleNearest, true},
+		{ResampleBilinear, false},
+		{ResampleCatmullRom, false},
+		{ResampleLanczos, false},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.resample), func(t *testing.T) {
+			spec := tileSpec{width: 60, height: 6, ratio: 10, crop: CropCenter, fit: FitCrop, resample: tc.resample}
+			lo, hi := grayRange(fitTile(src, spec))
+			spread := int(hi) - int(lo)
+			if tc.aliased && spread < 200 {
+				t.Fatalf("spread = %d (%d–%d), want the aliasing this filter is known for", spread, lo, hi)
+			}
+			if !tc.aliased && spread > 100 {
+				t.Fatalf("spread = %d (%d–%d), want an even grey", spread, lo, hi)
+			}
+		})
+	}
+}
+
+func TestLinearLightKeepsBrightness(t *testing.T) {
+	// A 1px checkerboard is half the light of white, which is sRGB 188, not
+	// the 128 averaging the encoded values gives.
+	src := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			if (x+y)%2 == 0 {
+				src.SetRGBA(x, y, color.RGBA{0xff, 0xff, 0xff, 0xff})
+			} else {
+				src.SetRGBA(x, y, color.RGBA{A: 0xff})
+			}
+		}
+	}
+	spec := tileSpec{width: 20, height: 20, ratio: 1, crop: CropCenter, fit: FitCrop, resample: ResampleBilinear}
+	if got := fitTile(src, spec).RGBAAt(10, 10).R; got < 120 || got > 136 {
+		t.Fatalf("gamma-space grey = %d, want about 128", got)
+	}
+	spec.linear = true
+	if got := fitTile(src, spec).RGBAAt(10, 10).R; got < 182 || got > 194 {
+		t.Fatalf("linear-light grey = %d, want about 188", got)
+	}
+}
+
+func TestLinearRoundTrip(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 256, 2))
+	for x := 0; x < 256; x++ {
+		v := uint8(x)
+		src.SetRGBA(x, 0, color.RGBA{v, v, v, 0xff})
+		// Premultiplied: colour never exceeds alpha.
+		src.SetRGBA(x, 1, color.RGBA{v / 2, v / 3, 0, v})
+	}
+	got := fromLinear(toLinear(src, src.Bounds()))
+	for x := 0; x < 256; x++ {
+		if a, b := src.RGBAAt(x, 0), got.RGBAAt(x, 0); a != b {
+			t.Fatalf("opaque %d: round trip = %v, want %v", x, b, a)
+		}
+		a, b := src.RGBAAt(x, 1), got.RGBAAt(x, 1)
+		if b.A != a.A || absDiff(a.R, b.R) > 1 || absDiff(a.G, b.G) > 1 || b.B != 0 {
+			t.Fatalf("translucent %d: round trip = %v, want %v", x, b, a)
+		}
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestCacheParamsResample(t *testing.T) {
+	spec := tileSpec{width: 10, height: 10, ratio: 1}
+	if !slices.Contains(spec.cacheParams(), "resample=approx-bilinear") {
+		t.Fatalf("default params = %v, want the original resample key", spec.cacheParams())
+	}
+	spec.resample = ResampleFast
+	if !slices.Contains(spec.cacheParams(), "resample=approx-bilinear") {
+		t.Fatalf("fast params = %v, want the original resample key", spec.cacheParams())
+	}
+
+	base := slices.Clone(spec.cacheParams())
+	for _, change := range []func(*tileSpec){
+		func(s *tileSpec) { s.resample = ResampleLanczos },
+		func(s *tileSpec) { s.linear = true },
+		func(s *tileSpec) { s.sharpen = Sharpen{Amount: 1, Radius: 1} },
+	} {
+		s := spec
+		change(&s)
+		if slices.Equal(s.cacheParams(), base) {
+			t.Fatalf("params %v did not change", s.cacheParams())
+		}
+	}
+}
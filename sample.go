@@ -0,0 +1,153 @@
+package yearcollage
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"time"
+)
+
+// SampleStrategy decides which photos are kept when there are more than
+// Options.MaxImages.
+type SampleStrategy string
+
+// Sample strategies.
+const (
+	SampleEvenTime SampleStrategy = "even-time" // spread picks evenly over the capture timeline
+	SamplePerDay   SampleStrategy = "per-day"   // an equal share for every day with photos
+	SamplePerWeek  SampleStrategy = "per-week"  // an equal share for every ISO week with photos
+	SamplePerMonth SampleStrategy = "per-month" // an equal share for every month with photos
+	SampleRandom   SampleStrategy = "random"    // a uniform random pick from Seed
+)
+
+// timed reports whether the strategy needs capture times.
+func (s SampleStrategy) timed() bool {
+	return s != SampleRandom
+}
+
+// sample keeps at most MaxImages of the sorted sources, chosen by the sample
+// strategy from the capture times keyed by name. The result keeps the sorted
+// order.
+func (b *Builder) sample(srcs []Source, times map[string]time.Time) []Source {
+	n := b.opts.MaxImages
+	if n <= 0 || len(srcs) <= n {
+		return srcs
+	}
+
+	strategy := b.opts.sample()
+	var picked []int
+	switch strategy {
+	case SampleRandom:
+		rng := rand.New(rand.NewPCG(uint64(b.opts.Seed), uint64(b.opts.Seed)))
+		picked = rng.Perm(len(srcs))[:n]
+	default:
+		// Photos without a capture time have no place on the timeline.
+		var idx []int
+		for i, s := range srcs {
+			if !times[s.Name()].IsZero() {
+				idx = append(idx, i)
+			}
+		}
+		if skipped := len(srcs) - len(idx); skipped > 0 {
+			b.logf("Sampling ignores %d images without a capture time", skipped)
+		}
+		sort.SliceStable(idx, func(i, j int) bool {
+			return times[srcs[idx[i]].Name()].Before(times[srcs[idx[j]].Name()])
+		})
+		at := func(i int) time.Time { return times[srcs[i].Name()] }
+		if strategy == SampleEvenTime {
+			picked = evenTime(idx, at, n)
+		} else {
+			picked = perPeriod(idx, at, n, strategy)
+		}
+	}
+
+	slices.Sort(picked)
+	out := make([]Source, len(picked))
+	for i, p := range picked {
+		out[i] = srcs[p]
+	}
+	b.logf("Sampled %d of %d images (%s)", len(out), len(srcs), strategy)
+	return out
+}
+
+// evenTime picks n of the time-ordered indices: the timeline is cut into n
+// equal slots and each slot takes the unpicked photo nearest its middle, so
+// busy weeks cannot crowd out quiet ones.
+func evenTime(idx []int, at func(int) time.Time, n int) []int {
+	if len(idx) <= n {
+		return slices.Clone(idx)
+	}
+	first, last := at(idx[0]), at(idx[len(idx)-1])
+	span := last.Sub(first)
+	used := make([]bool, len(idx))
+	picked := make([]int, 0, n)
+	for k := 0; k < n; k++ {
+		target := first.Add(time.Duration((float64(k) + 0.5) / float64(n) * float64(span)))
+		j := sort.Search(len(idx), func(i int) bool { return !at(idx[i]).Before(target) })
+		// Walk outwards to the nearest photo not taken by an earlier slot.
+		lo, hi := j-1, j
+		for lo >= 0 && used[lo] {
+			lo--
+		}
+		for hi < len(idx) && used[hi] {
+			hi++
+		}
+		pick := hi
+		if hi >= len(idx) || (lo >= 0 && target.Sub(at(idx[lo])) <= at(idx[hi]).Sub(target)) {
+			pick = lo
+		}
+		used[pick] = true
+		picked = append(picked, idx[pick])
+	}
+	return picked
+}
+
+// perPeriod picks n of the time-ordered indices so every day, week or month
+// with photos gets the same quota, or all its photos when it has fewer.
+// Within a period the picks are spread evenly over its photos.
+func perPeriod(idx []int, at func(int) time.Time, n int, strategy SampleStrategy) []int {
+	var periods [][]int
+	var lastKey [2]int
+	for i, p := range idx {
+		key := periodKey(at(p), strategy)
+		if i == 0 || key != lastKey {
+			periods = append(periods, nil)
+			lastKey = key
+		}
+		periods[len(periods)-1] = append(periods[len(periods)-1], p)
+	}
+
+	// Hand out the quota one photo per period per round, so leftovers from
+	// sparse periods go to the busy ones.
+	quota := make([]int, len(periods))
+	for total := 0; total < n && total < len(idx); {
+		for i, p := range periods {
+			if total < n && quota[i] < len(p) {
+				quota[i]++
+				total++
+			}
+		}
+	}
+
+	var picked []int
+	for i, p := range periods {
+		for k := 0; k < quota[i]; k++ {
+			picked = append(picked, p[(2*k+1)*len(p)/(2*quota[i])])
+		}
+	}
+	return picked
+}
+
+// periodKey identifies the day, ISO week or month t falls into.
+func periodKey(t time.Time, strategy SampleStrategy) [2]int {
+	switch strategy {
+	case SamplePerDay:
+		return [2]int{t.Year(), t.YearDay()}
+	case SamplePerWeek:
+		year, week := t.ISOWeek()
+		return [2]int{year, week}
+	default:
+		return [2]int{t.Year(), int(t.Month())}
+	}
+}
@@ -0,0 +1,97 @@
+package yearcollage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sampleFixture returns a burst of 50 photos on New Year's morning followed by
+// one photo on the 15th of every other month, with their capture times.
+func sampleFixture() ([]Source, map[string]time.Time) {
+	var srcs []Source
+	times := make(map[string]time.Time)
+	add := func(name string, at time.Time) {
+		srcs = append(srcs, Bytes(name, nil, at))
+		times[name] = at
+	}
+	for i := 0; i < 50; i++ {
+		add(fmt.Sprintf("jan-%02d", i), time.Date(2025, time.January, 1, 10, i, 0, 0, time.UTC))
+	}
+	for m := time.February; m <= time.December; m++ {
+		add(m.String(), time.Date(2025, m, 15, 12, 0, 0, 0, time.UTC))
+	}
+	return srcs, times
+}
+
+func sampledNames(srcs []Source) []string {
+	names := make([]string, len(srcs))
+	for i, s := range srcs {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+func TestSampleSpreadsOverTheYear(t *testing.T) {
+	srcs, times := sampleFixture()
+	months := sampledNames(srcs[50:])
+
+	cases := []struct {
+		strategy SampleStrategy
+		n        int
+		january  int // picks from the New Year burst
+	}{
+		{SampleEvenTime, 12, 1},
+		{SamplePerMonth, 12, 1},
+		// Months with a single photo pass their leftover share to January.
+		{SamplePerMonth, 20, 9},
+		{SamplePerDay, 14, 3},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%s/%d", tc.strategy, tc.n), func(t *testing.T) {
+			b := &Builder{opts: Options{MaxImages: tc.n, Sample: tc.strategy}}
+			got := sampledNames(b.sample(srcs, times))
+			if len(got) != tc.n {
+				t.Fatalf("sampled %d images, want %d", len(got), tc.n)
+			}
+			if !reflect.DeepEqual(got[tc.january:], months) {
+				t.Fatalf("sampled %v, want %d January photos followed by every month", got, tc.january)
+			}
+		})
+	}
+}
+
+func TestSampleRandomIsReproducible(t *testing.T) {
+	srcs, times := sampleFixture()
+	pick := func(seed int64) []string {
+		b := &Builder{opts: Options{MaxImages: 10, Sample: SampleRandom, Seed: seed}}
+		return sampledNames(b.sample(srcs, times))
+	}
+
+	first := pick(7)
+	if !reflect.DeepEqual(first, pick(7)) {
+		t.Fatal("the same seed gave different picks")
+	}
+	if reflect.DeepEqual(first, pick(8)) {
+		t.Fatal("different seeds gave the same picks")
+	}
+	// Picks keep the sorted input order.
+	order := make(map[string]int)
+	for i, s := range srcs {
+		order[s.Name()] = i
+	}
+	for i := 1; i < len(first); i++ {
+		if order[first[i-1]] >= order[first[i]] {
+			t.Fatalf("picks %v are out of order", first)
+		}
+	}
+}
+
+func TestSampleKeepsSmallSets(t *testing.T) {
+	srcs, times := sampleFixture()
+	b := &Builder{opts: Options{MaxImages: 100}}
+	if got := b.sample(srcs, times); len(got) != len(srcs) {
+		t.Fatalf("sampled %d of %d images under the limit", len(got), len(srcs))
+	}
+}
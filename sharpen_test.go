@@ -0,0 +1,95 @@
+package yearcollage
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestUnsharpMask(t *testing.T) {
+	dark := color.RGBA{100, 100, 100, 0xff}
+	light := color.RGBA{200, 200, 200, 0xff}
+	step := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 20, 4))
+		fillRect(img, image.Rect(0, 0, 10, 4), dark)
+		fillRect(img, image.Rect(10, 0, 20, 4), light)
+		return img
+	}
+
+	img := step()
+	unsharpMask(img, Sharpen{Amount: 1, Radius: 1})
+	if got := img.RGBAAt(9, 2); got.R >= dark.R {
+		t.Fatalf("dark side of the edge = %v, want darker than %v", got, dark)
+	}
+	if got := img.RGBAAt(10, 2); got.R <= light.R {
+		t.Fatalf("light side of the edge = %v, want lighter than %v", got, light)
+	}
+	if got := img.RGBAAt(0, 2); got != dark {
+		t.Fatalf("flat area = %v, want unchanged %v", got, dark)
+	}
+	if got := img.RGBAAt(19, 2); got != light {
+		t.Fatalf("flat area = %v, want unchanged %v", got, light)
+	}
+
+	img = step()
+	unsharpMask(img, Sharpen{Radius: 1})
+	if got := img.RGBAAt(9, 2); got != dark {
+		t.Fatalf("amount 0: edge = %v, want unchanged %v", got, dark)
+	}
+}
+
+func TestGaussianKernel(t *testing.T) {
+	for _, sigma := range []float64{0.3, 1, 2.5} {
+		k := gaussianKernel(sigma)
+		if len(k)%2 != 1 {
+			t.Fatalf("sigma %v: %d weights, want an odd count", sigma, len(k))
+		}
+		var sum float32
+		for i, w := range k {
+			sum += w
+			if w != k[len(k)-1-i] {
+				t.Fatalf("sigma %v: weights %v are not symmetric", sigma, k)
+			}
+		}
+		if sum < 0.999 || sum > 1.001 {
+			t.Fatalf("sigma %v: weights sum to %v, want 1", sigma, sum)
+		}
+	}
+}
+
+func TestUnsharpMaskKeepsPremultipliedAlpha(t *testing.T) {
+	// A half-transparent step: sharpening must not push colour above alpha.
+	img := image.NewRGBA(image.Rect(0, 0, 20, 4))
+	fillRect(img, image.Rect(0, 0, 10, 4), color.RGBA{20, 20, 20, 0x80})
+	fillRect(img, image.Rect(10, 0, 20, 4), color.RGBA{0x80, 0x80, 0x80, 0x80})
+	unsharpMask(img, Sharpen{Amount: 2, Radius: 1})
+	for i := 0; i < len(img.Pix); i += 4 {
+		p := img.Pix[i : i+4]
+		if p[0] > p[3] || p[1] > p[3] || p[2] > p[3] {
+			t.Fatalf("pixel %v has a channel above its alpha", p)
+		}
+	}
+}
+
+func TestValidateSharpen(t *testing.T) {
+	cases := []struct {
+		sharpen Sharpen
+		wantErr bool
+	}{
+		{Sharpen{Amount: 0.8, Radius: 1}, false},
+		{Sharpen{Amount: 1, Radius: maxSharpenRadius}, false},
+		{Sharpen{Amount: 1, Radius: 1e9}, true},
+		{Sharpen{Amount: 1, Radius: math.NaN()}, true},
+		{Sharpen{Amount: math.NaN(), Radius: 1}, true},
+		{Sharpen{Amount: 1, Radius: math.Inf(1)}, true},
+		{Sharpen{Amount: math.Inf(1), Radius: 1}, true},
+		{Sharpen{Amount: -1, Radius: 1}, true},
+	}
+	for _, tc := range cases {
+		err := Options{TileWidth: 10, Columns: 1, Sharpen: tc.sharpen}.Validate()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tc.sharpen, err, tc.wantErr)
+		}
+	}
+}
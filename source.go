@@ -0,0 +1,251 @@
+package yearcollage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+
+	"github.com/luceast/yearcollage/internal/collect"
+)
+
+// SortMode orders the sources before layout.
+type SortMode string
+
+// Sort modes. The zero value keeps the order the sources were passed in.
+const (
+	SortNone    SortMode = ""
+	SortModTime SortMode = "time" // file modification time, oldest first
+	SortName    SortMode = "name" // Source.Name, alphabetical
+	SortEXIF    SortMode = "exif" // EXIF capture time, falling back to mtime
+)
+
+// Source is one input photo. Sources are opened several times during a
+// render (header probes, EXIF, pixels), so Open must return a fresh reader
+// positioned at the start each time.
+type Source interface {
+	// Name identifies the source in logs, errors and failures; SortName
+	// orders by it. Names should be unique within a render.
+	Name() string
+	// Open returns the encoded image.
+	Open() (io.ReadSeekCloser, error)
+	// ModTime reports when the photo was last modified, or the zero time
+	// when unknown.
+	ModTime() time.Time
+}
+
+// File returns a Source reading the image file at path.
+func File(path string) Source {
+	return fileSource(path)
+}
+
+type fileSource string
+
+func (f fileSource) Name() string                     { return string(f) }
+func (f fileSource) Open() (io.ReadSeekCloser, error) { return os.Open(string(f)) }
+
+func (f fileSource) ModTime() time.Time {
+	info, err := os.Stat(string(f))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Bytes returns a Source serving an encoded image held in memory.
+func Bytes(name string, data []byte, modTime time.Time) Source {
+	return bytesSource{name: name, data: data, modTime: modTime}
+}
+
+type bytesSource struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+func (b bytesSource) Name() string       { return b.name }
+func (b bytesSource) ModTime() time.Time { return b.modTime }
+
+func (b bytesSource) Open() (io.ReadSeekCloser, error) {
+	return nopCloser{bytes.NewReader(b.data)}, nil
+}
+
+type nopCloser struct{ io.ReadSeeker }
+
+func (nopCloser) Close() error { return nil }
+
+// Filter selects files during Dir with gitignore-style patterns relative to
+// the root: without a slash a pattern matches a file or directory name at any
+// depth, with a slash it matches the path from the root, and ** spans any
+// number of directories. Directories matching Exclude are not descended into.
+type Filter struct {
+	Include []string // when set, keep only files matching one of these
+	Exclude []string // drop files and directories matching any of these
+}
+
+// IgnoreFile is the per-directory file of gitignore-style patterns that Dir
+// always honours, e.g. to keep thumbnails or @eaDir folders out of a collage.
+const IgnoreFile = collect.IgnoreFile
+
+// Dir walks root recursively and returns a File source for every supported
+// image that passes filter and any IgnoreFile, in walk order. Cancelling ctx
+// stops the walk.
+func Dir(ctx context.Context, root string, filter Filter) ([]Source, error) {
+	paths, err := collect.Images(ctx, root, collect.Filter{Include: filter.Include, Exclude: filter.Exclude})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("collect images: %w", err)
+	}
+	sources := make([]Source, len(paths))
+	for i, p := range paths {
+		sources[i] = File(p)
+	}
+	return sources, nil
+}
+
+// sortSources orders sources according to the sort mode. EXIF order uses the
+// captured times, keyed by name. Ties keep the input order, except EXIF ties
+// which fall back to the name.
+func (b *Builder) sortSources(ctx context.Context, srcs []Source, captured map[string]time.Time) error {
+	switch b.opts.Sort {
+	case SortModTime:
+		times := make([]time.Time, len(srcs))
+		for i, s := range srcs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			times[i] = s.ModTime()
+		}
+		sortByTime(srcs, times, false)
+	case SortName:
+		sort.SliceStable(srcs, func(i, j int) bool { return srcs[i].Name() < srcs[j].Name() })
+	case SortEXIF:
+		times := make([]time.Time, len(srcs))
+		for i, s := range srcs {
+			times[i] = captured[s.Name()]
+		}
+		sortByTime(srcs, times, true)
+	}
+	return nil
+}
+
+// sortByTime sorts srcs and their parallel times oldest first, optionally
+// breaking ties by name.
+func sortByTime(srcs []Source, times []time.Time, byName bool) {
+	idx := make([]int, len(srcs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		ta, tb := times[idx[a]], times[idx[b]]
+		if byName && ta.Equal(tb) {
+			return srcs[idx[a]].Name() < srcs[idx[b]].Name()
+		}
+		return ta.Before(tb)
+	})
+	sorted := make([]Source, len(srcs))
+	for i, k := range idx {
+		sorted[i] = srcs[k]
+	}
+	copy(srcs, sorted)
+}
+
+// imageOrientation extracts the EXIF orientation flag and returns a value between
+// 1 and 8 (per the TIFF/EXIF spec). When the file has no EXIF block or the tag
+// is missing we default to 1 (top-left).
+func imageOrientation(rs io.ReadSeeker) int {
+	return exifOrientation(readExif(rs))
+}
+
+// readExif parses the EXIF block from the start of rs, or returns nil when
+// there is none.
+func readExif(rs io.ReadSeeker) *exif.Exif {
+	if rs == nil {
+		return nil
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil
+	}
+	x, err := exif.Decode(rs)
+	if err != nil {
+		return nil
+	}
+	return x
+}
+
+// exifOrientation reads the orientation tag of parsed EXIF data, defaulting
+// to 1.
+func exifOrientation(x *exif.Exif) int {
+	if x == nil {
+		return 1
+	}
+	field, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 1
+	}
+	val, err := field.Int(0)
+	if err != nil {
+		return 1
+	}
+	orientation := int(val)
+	if orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return orientation
+}
+
+// captureTime extracts the best-effort EXIF timestamp, falling back to modtime.
+func (b *Builder) captureTime(src Source) time.Time {
+	f, err := src.Open()
+	if err != nil {
+		b.logf("warn: open for exif %q: %v", src.Name(), err)
+		return src.ModTime()
+	}
+	defer f.Close()
+
+	x, err := exif.Decode(f)
+	if err != nil {
+		return src.ModTime()
+	}
+
+	if tm, err := x.DateTime(); err == nil {
+		return tm
+	}
+	for _, tag := range []exif.FieldName{exif.DateTimeOriginal, exif.DateTimeDigitized} {
+		if field, err := x.Get(tag); err == nil {
+			if s, err := field.StringVal(); err == nil {
+				if tm, ok := parseExifTimeString(s); ok {
+					return tm
+				}
+			}
+		}
+	}
+	return src.ModTime()
+}
+
+// parseExifTimeString handles a handful of timestamp formats commonly seen in EXIF.
+func parseExifTimeString(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	layouts := []string{
+		"2006:01:02 15:04:05",
+		time.RFC3339,
+		time.RFC3339Nano,
+	}
+	for _, layout := range layouts {
+		if tm, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return tm, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,176 @@
+package yearcollage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"slices"
+
+	"github.com/luceast/yearcollage/internal/bytesize"
+)
+
+// strip is a horizontal band of the canvas rendered and encoded at once.
+type strip struct {
+	y0, y1 int
+	// cells indexes the layout cells drawn in this strip.
+	cells []int
+}
+
+// planStrips cuts the canvas into strips of at most budget bytes. Cuts never
+// pass through a cell, so every tile is drawn whole into one strip. Cells
+// without pixels are assigned to the first strip so they are still reported.
+func planStrips(lay layout, budget int64) ([]strip, error) {
+	rowBytes := 4 * int64(lay.width)
+	maxRows := int(min(budget/rowBytes, int64(lay.height)))
+	if maxRows == 0 {
+		return nil, fmt.Errorf("max-memory %s cannot hold one %d-pixel row of the canvas", bytesize.Format(budget), lay.width)
+	}
+
+	// Merge the vertical extents of the cells into bands that must not be
+	// split.
+	var bands [][2]int
+	for _, c := range lay.cells {
+		if !c.rect.Empty() {
+			bands = append(bands, [2]int{c.rect.Min.Y, c.rect.Max.Y})
+		}
+	}
+	slices.SortFunc(bands, func(a, b [2]int) int { return a[0] - b[0] })
+	merged := bands[:0]
+	for _, band := range bands {
+		if n := len(merged); n > 0 && band[0] < merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], band[1])
+			continue
+		}
+		merged = append(merged, band)
+	}
+
+	var strips []strip
+	for y := 0; y < lay.height; {
+		end := min(y+maxRows, lay.height)
+		for _, band := range merged {
+			if band[0] < end && end < band[1] {
+				end = band[0]
+				break
+			}
+		}
+		if end <= y {
+			return nil, fmt.Errorf("a %d×%d band of tiles needs more than max-memory %s; raise max-memory or lower tile-width",
+				lay.width, bandHeight(merged, y), bytesize.Format(budget))
+		}
+		strips = append(strips, strip{y0: y, y1: end})
+		y = end
+	}
+
+	for i, c := range lay.cells {
+		s := 0
+		if !c.rect.Empty() {
+			s, _ = slices.BinarySearchFunc(strips, c.rect.Min.Y, func(s strip, y int) int {
+				if s.y1 <= y {
+					return -1
+				}
+				if s.y0 > y {
+					return 1
+				}
+				return 0
+			})
+		}
+		strips[s].cells = append(strips[s].cells, i)
+	}
+	return strips, nil
+}
+
+// bandHeight is the height of the band starting at y.
+func bandHeight(bands [][2]int, y int) int {
+	for _, band := range bands {
+		if band[0] == y {
+			return band[1] - band[0]
+		}
+	}
+	return 0
+}
+
+// stream renders lay strip by strip and writes each strip to w as soon as it
+// is done, so only one strip of the canvas is held in memory. Strips that
+// are written cannot be re-flowed, so under OnErrorSkip the headers are read
+// first and unreadable photos dropped; photos that fail later leave an empty
+// cell.
+func (b *Builder) stream(ctx context.Context, w io.Writer, format Format, srcs []Source, meta imageMeta, res *Result, lay layout) error {
+	if b.opts.onError() == OnErrorSkip && b.opts.layout() != LayoutJustified {
+		_, _, errs, err := b.probeAspects(ctx, srcs)
+		if err != nil {
+			return err
+		}
+		var failed []Failure
+		for i, err := range errs {
+			if err != nil {
+				failed = append(failed, Failure{Source: srcs[i].Name(), Err: err})
+			}
+		}
+		if len(failed) > 0 {
+			res.Failures = append(res.Failures, failed...)
+			if srcs = withoutFailed(srcs, failed); len(srcs) == 0 {
+				return errors.New("all images failed to render")
+			}
+			b.logf("Skipped %d unreadable images; re-flowing %d images", len(failed), len(srcs))
+			if lay, err = b.buildLayout(srcs, meta); err != nil {
+				return err
+			}
+		}
+	}
+
+	strips, err := planStrips(lay, b.opts.MaxMemory)
+	if err != nil {
+		return err
+	}
+	enc, err := format.streamEncoder(w, lay.width, lay.height)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", format, err)
+	}
+	res.Bounds = image.Rect(0, 0, lay.width, lay.height)
+	res.Columns, res.Rows = lay.columns, lay.rows
+	res.TileWidth, res.TileHeight = lay.tileWidth, lay.tileHeight
+
+	tallest := 0
+	for _, s := range strips {
+		tallest = max(tallest, s.y1-s.y0)
+	}
+	stride := 4 * lay.width
+	pix := make([]uint8, stride*tallest)
+
+	r := b.newRenderer(meta)
+	b.stage(StageRender, len(srcs))
+	skipped := 0
+	for _, s := range strips {
+		canvas := &image.RGBA{Pix: pix[:stride*(s.y1-s.y0)], Stride: stride, Rect: image.Rect(0, s.y0, lay.width, s.y1)}
+		clear(canvas.Pix)
+		if lay.decorate != nil {
+			lay.decorate(canvas)
+		}
+		tiles, failed, err := b.renderTiles(ctx, canvas, srcs, lay, r, s.cells)
+		res.Tiles = append(res.Tiles, tiles...)
+		res.Failures = append(res.Failures, failed...)
+		if err != nil {
+			b.logCache(r)
+			return err
+		}
+		skipped += len(failed)
+		if lay.overlay != nil {
+			lay.overlay(canvas)
+		}
+		if err := enc.WriteStrip(canvas); err != nil {
+			return fmt.Errorf("encode %s: %w", format, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("encode %s: %w", format, err)
+	}
+	sortTiles(res.Tiles)
+	b.logCache(r)
+	if skipped > 0 && b.opts.onError() == OnErrorSkip {
+		b.logf("warn: %d images failed after streaming started; their cells stay empty", skipped)
+	}
+	b.logf("Streamed the %d×%d canvas in %d strips of at most %d rows", lay.width, lay.height, len(strips), tallest)
+	return nil
+}
@@ -0,0 +1,175 @@
+package yearcollage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/tiff"
+)
+
+func TestEncodeStreamsLargeCanvas(t *testing.T) {
+	var sources []Source
+	for i := 0; i < 7; i++ {
+		sources = append(sources, pngSource(t, fmt.Sprintf("img-%d.png", i), 30, 20, color.RGBA{uint8(30 * i), 200, uint8(255 - 30*i), 255}))
+	}
+	opts := Options{TileWidth: 12, TileAspect: 1.5, Columns: 3, Sort: SortName, Jobs: 2}
+
+	b, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	want, err := b.Render(context.Background(), sources)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	// One 8-row tile band per strip: 36 columns × 4 bytes × 8 rows.
+	opts.MaxMemory = 36 * 4 * 10
+	var logs []string
+	opts.Logf = func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	b, err = New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, format := range []Format{FormatPNG, FormatTIFF} {
+		var buf bytes.Buffer
+		res, err := b.Encode(context.Background(), &buf, format, sources)
+		if err != nil {
+			t.Fatalf("Encode %s: %v", format, err)
+		}
+		if res.Image != nil || res.Bounds != want.Image.Rect {
+			t.Fatalf("%s: result image %v, bounds %v; want a streamed %v canvas", format, res.Image != nil, res.Bounds, want.Image.Rect)
+		}
+		var got image.Image
+		if format == FormatTIFF {
+			got, err = tiff.Decode(bytes.NewReader(buf.Bytes()))
+		} else {
+			got = decodePNG(t, buf.Bytes())
+		}
+		if err != nil {
+			t.Fatalf("decode %s: %v", format, err)
+		}
+		for y := 0; y < want.Bounds.Dy(); y++ {
+			for x := 0; x < want.Bounds.Dx(); x++ {
+				if g, w := color.RGBAModel.Convert(got.At(x, y)), want.Image.At(x, y); g != w {
+					t.Fatalf("%s pixel (%d,%d) = %v, want %v", format, x, y, g, w)
+				}
+			}
+		}
+	}
+	if last := logs[len(logs)-1]; !strings.Contains(last, "in 3 strips") {
+		t.Fatalf("last log = %q, want three strips", last)
+	}
+}
+
+func TestEncodeRefusesToStreamJPEG(t *testing.T) {
+	sources := []Source{pngSource(t, "a.png", 10, 10, color.RGBA{A: 255}), pngSource(t, "b.png", 10, 10, color.RGBA{A: 255})}
+	b, err := New(Options{TileWidth: 10, Columns: 2, MaxMemory: 400})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var buf bytes.Buffer
+	_, err = b.Encode(context.Background(), &buf, FormatJPEG, sources)
+	if err == nil || !strings.Contains(err.Error(), "JPEG output cannot be written in strips") {
+		t.Fatalf("Encode error = %v, want a refusal to stream JPEG", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("wrote %d bytes before refusing", buf.Len())
+	}
+	if _, err := b.Render(context.Background(), sources); err == nil || !strings.Contains(err.Error(), "max-memory") {
+		t.Fatalf("Render error = %v, want the max-memory limit", err)
+	}
+}
+
+func TestPlanStrips(t *testing.T) {
+	rows := func(ys ...int) layout {
+		lay := layout{width: 10}
+		for i := 0; i+1 < len(ys); i++ {
+			lay.cells = append(lay.cells, cell{rect: image.Rect(0, ys[i], 10, ys[i+1])})
+		}
+		lay.height = ys[len(ys)-1]
+		return lay
+	}
+	cases := []struct {
+		name   string
+		lay    layout
+		budget int64
+		want   []strip
+		err    string
+	}{
+		{"fits whole", rows(0, 5, 10), 1000, []strip{{0, 10, []int{0, 1}}}, ""},
+		{"one band each", rows(0, 5, 10, 15), 40 * 7, []strip{{0, 5, []int{0}}, {5, 10, []int{1}}, {10, 15, []int{2}}}, ""},
+		{"two bands", rows(0, 5, 10, 15), 40 * 10, []strip{{0, 10, []int{0, 1}}, {10, 15, []int{2}}}, ""},
+		{
+			"gap is cut anywhere",
+			layout{width: 10, height: 20, cells: []cell{{rect: image.Rect(0, 12, 10, 20)}, {}}},
+			40 * 8,
+			[]strip{{0, 8, []int{1}}, {8, 12, nil}, {12, 20, []int{0}}},
+			"",
+		},
+		{"band too tall", rows(0, 5, 10), 40 * 4, nil, "a 10×5 band of tiles"},
+		{"row too wide", rows(0, 5), 39, nil, "cannot hold one 10-pixel row"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := planStrips(tc.lay, tc.budget)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("error = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("strips = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeStreamsCalendar(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, time.March, d, 12, 0, 0, 0, time.UTC) }
+	var sources []Source
+	for i := 1; i <= 3; i++ {
+		sources = append(sources, Bytes(fmt.Sprintf("d%d.png", i), pngBytes(t, 20, 20, color.RGBA{200, 0, 0, 255}), day(i)))
+	}
+	// Days cells are big enough for weekday labels, which strips must
+	// draw over the tiles just like the full canvas.
+	for _, mode := range []CalendarMode{CalendarWeeks, CalendarDays} {
+		t.Run(string(mode), func(t *testing.T) {
+			opts := Options{TileWidth: 40, Layout: LayoutCalendar, Calendar: mode}
+			b, err := New(opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			want, err := b.Render(context.Background(), sources)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			opts.MaxMemory = canvasBytes(layout{width: want.Bounds.Dx(), height: want.Bounds.Dy()}) / 3
+			if b, err = New(opts); err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			var buf bytes.Buffer
+			if _, err := b.Encode(context.Background(), &buf, FormatPNG, sources); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			got := decodePNG(t, buf.Bytes())
+			for y := 0; y < want.Bounds.Dy(); y++ {
+				for x := 0; x < want.Bounds.Dx(); x++ {
+					if g, w := color.RGBAModel.Convert(got.At(x, y)), want.Image.At(x, y); g != w {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
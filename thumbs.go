@@ -0,0 +1,92 @@
+package yearcollage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// thumbAspectTolerance is how far, relative to the photo's aspect ratio, an
+// EXIF thumbnail may deviate and still count as the same picture. Many
+// cameras store 4:3 thumbnails with black bars for 3:2 photos; those fail.
+const thumbAspectTolerance = 0.02
+
+// embeddedThumbnail returns the EXIF IFD1 thumbnail of the image in rs when
+// it shows the whole photo and has enough pixels for the tile. ok is false
+// otherwise, and the caller decodes the full image instead. The thumbnail is
+// stored unrotated, like the main image.
+func embeddedThumbnail(x *exif.Exif, rs io.ReadSeeker, orientation int, spec tileSpec) (image.Image, bool) {
+	if x == nil {
+		return nil, false
+	}
+	data, err := exifThumbnail(x)
+	if err != nil {
+		return nil, false
+	}
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil || thumb.Width <= 0 || thumb.Height <= 0 {
+		return nil, false
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, false
+	}
+	main, _, err := image.DecodeConfig(rs)
+	if err != nil || main.Width <= 0 || main.Height <= 0 {
+		return nil, false
+	}
+
+	mainRatio := float64(main.Width) / float64(main.Height)
+	thumbRatio := float64(thumb.Width) / float64(thumb.Height)
+	if math.Abs(thumbRatio-mainRatio)/mainRatio > thumbAspectTolerance {
+		return nil, false
+	}
+	if !coversTile(thumb.Width, thumb.Height, orientation, spec) {
+		return nil, false
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	return img, true
+}
+
+// exifThumbnail returns the IFD1 JPEG thumbnail. goexif slices the raw EXIF
+// block with the stored offsets unchecked, so corrupt offsets are turned
+// into an error instead of a panic.
+func exifThumbnail(x *exif.Exif) (data []byte, err error) {
+	defer func() {
+		if recover() != nil {
+			data, err = nil, errors.New("exif thumbnail offset out of range")
+		}
+	}()
+	return x.JpegThumbnail()
+}
+
+// coversTile reports whether a width×height image, stored with the given
+// EXIF orientation, has at least one source pixel per tile pixel once
+// cropped or fitted into the tile.
+func coversTile(width, height, orientation int, spec tileSpec) bool {
+	if orientation >= 5 && orientation <= 8 {
+		width, height = height, width
+	}
+	if spec.fit != FitCrop && spec.fit != "" {
+		r := containRect(width, height, spec.width, spec.height)
+		return width >= r.Dx() && height >= r.Dy()
+	}
+
+	// The crop keeps the full extent of one axis.
+	target := float64(spec.width) / float64(spec.height)
+	cropW, cropH := float64(width), float64(height)
+	if cropW/cropH > target {
+		cropW = cropH * target
+	} else {
+		cropH = cropW / target
+	}
+	return cropW >= float64(spec.width)-0.5 && cropH >= float64(spec.height)-0.5
+}
@@ -0,0 +1,91 @@
+package yearcollage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+// jpegWithThumb encodes a solid w×h JPEG whose EXIF block carries a solid
+// tw×th thumbnail in another colour.
+func jpegWithThumb(t *testing.T, w, h int, c color.RGBA, tw, th int, tc color.RGBA) []byte {
+	t.Helper()
+	encode := func(w, h int, c color.RGBA) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		fillRect(img, img.Bounds(), c)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+			t.Fatalf("encode jpeg: %v", err)
+		}
+		return buf.Bytes()
+	}
+	main, thumb := encode(w, h, c), encode(tw, th, tc)
+
+	// Little-endian TIFF: an empty IFD0 linking to an IFD1 that points at
+	// the thumbnail bytes right after it.
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00")
+	tiff = le.AppendUint32(tiff, 8)
+	tiff = le.AppendUint16(tiff, 0)  // IFD0 entries
+	tiff = le.AppendUint32(tiff, 14) // IFD1 offset
+	tiff = le.AppendUint16(tiff, 2)  // IFD1 entries
+	for _, e := range [][2]uint32{{0x0201, 14 + 30}, {0x0202, uint32(len(thumb))}} {
+		tiff = le.AppendUint16(tiff, uint16(e[0]))
+		tiff = le.AppendUint16(tiff, 4) // LONG
+		tiff = le.AppendUint32(tiff, 1)
+		tiff = le.AppendUint32(tiff, e[1])
+	}
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, thumb...)
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	out := []byte{0xff, 0xd8, 0xff, 0xe1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(app1)+2))
+	out = append(out, app1...)
+	return append(out, main[2:]...)
+}
+
+func TestProcessTileUsesEmbeddedThumbnail(t *testing.T) {
+	red, blue := color.RGBA{220, 20, 20, 255}, color.RGBA{20, 20, 220, 255}
+	cases := []struct {
+		name     string
+		thumbW   int
+		thumbH   int
+		tile     int
+		fit      FitMode
+		wantBlue bool
+	}{
+		{"thumbnail covers tile", 64, 48, 32, FitCrop, true},
+		{"thumbnail covers contained tile", 64, 48, 60, FitContain, true},
+		{"thumbnail too small", 64, 48, 100, FitCrop, false},
+		{"letterboxed thumbnail", 64, 64, 32, FitCrop, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := Bytes("photo.jpg", jpegWithThumb(t, 400, 300, red, tc.thumbW, tc.thumbH, blue), time.Time{})
+			spec := tileSpec{width: tc.tile, height: tc.tile, ratio: 1, crop: CropCenter, fit: tc.fit, thumbs: true}
+			tile, _, err := processTile(src, spec)
+			if err != nil {
+				t.Fatalf("processTile: %v", err)
+			}
+			c := tile.RGBAAt(tc.tile/2, tc.tile/2)
+			if gotBlue := c.B > c.R; gotBlue != tc.wantBlue {
+				t.Fatalf("centre pixel %v, want thumbnail used = %v", c, tc.wantBlue)
+			}
+		})
+	}
+
+	// Without the option the full image is always decoded.
+	src := Bytes("photo.jpg", jpegWithThumb(t, 400, 300, red, 64, 48, blue), time.Time{})
+	tile, _, err := processTile(src, tileSpec{width: 32, height: 32, ratio: 1, crop: CropCenter, fit: FitCrop})
+	if err != nil {
+		t.Fatalf("processTile: %v", err)
+	}
+	if c := tile.RGBAAt(16, 16); c.R < c.B {
+		t.Fatalf("centre pixel %v, want the full red image", c)
+	}
+}
@@ -0,0 +1,74 @@
+package yearcollage
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"time"
+)
+
+// Tile records where one photo was drawn on the canvas and which part of it
+// shows there.
+type Tile struct {
+	Source string // the source's Name
+	// Time is the capture time: the EXIF timestamp, or the modification
+	// time when the photo has none.
+	Time time.Time
+	// Row and Column place the tile in the layout, counted from zero. In
+	// justified layouts Column is the position within the row.
+	Row, Column int
+	// Rect is the tile's destination on the canvas.
+	Rect image.Rectangle
+	// Crop is the part of the photo shown in the tile, in pixels of the
+	// full-size image as stored, before Orientation is applied. Contained
+	// and blur-filled photos show all of it.
+	Crop image.Rectangle
+	// Orientation is the EXIF orientation (1–8) applied to the photo; 1
+	// means it was drawn as stored.
+	Orientation int
+}
+
+// tileInfo is what rendering a tile reveals about its source beyond the
+// pixels. It travels with cached tiles as their note.
+type tileInfo struct {
+	crop        image.Rectangle
+	orientation int
+}
+
+// note encodes the info for the tile cache.
+func (t tileInfo) note() string {
+	return fmt.Sprintf("crop=%d,%d,%d,%d orientation=%d", t.crop.Min.X, t.crop.Min.Y, t.crop.Max.X, t.crop.Max.Y, t.orientation)
+}
+
+// parseTileInfo reads a note written by tileInfo.note. Entries cached before
+// tiles carried one report false.
+func parseTileInfo(note string) (tileInfo, bool) {
+	var t tileInfo
+	n, err := fmt.Sscanf(note, "crop=%d,%d,%d,%d orientation=%d", &t.crop.Min.X, &t.crop.Min.Y, &t.crop.Max.X, &t.crop.Max.Y, &t.orientation)
+	return t, err == nil && n == 5
+}
+
+// sourceWindow maps a window of a decoded image with the given bounds to the
+// pixels of the full-size source, which is larger when the JPEG was decoded
+// at a reduced DCT scale or the EXIF thumbnail stood in for it. A zero size
+// means the full size is unknown and the decoded one is used.
+func sourceWindow(window, decoded image.Rectangle, size image.Point) image.Rectangle {
+	window = window.Sub(decoded.Min)
+	if decoded.Empty() || size.X <= 0 || size.Y <= 0 || size == decoded.Size() {
+		return window
+	}
+	sx := float64(size.X) / float64(decoded.Dx())
+	sy := float64(size.Y) / float64(decoded.Dy())
+	scale := func(v int, s float64) int { return int(math.Round(float64(v) * s)) }
+	r := image.Rect(scale(window.Min.X, sx), scale(window.Min.Y, sy), scale(window.Max.X, sx), scale(window.Max.Y, sy))
+	return r.Intersect(image.Rectangle{Max: size})
+}
+
+// sortTiles puts tiles in reading order: by row, then by column.
+func sortTiles(tiles []Tile) {
+	slices.SortFunc(tiles, func(a, b Tile) int {
+		return cmp.Or(cmp.Compare(a.Row, b.Row), cmp.Compare(a.Column, b.Column))
+	})
+}
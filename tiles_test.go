@@ -0,0 +1,108 @@
+package yearcollage
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenderRecordsTiles(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	// A JPEG large enough to be decoded at 1/8 scale, so its crop window has
+	// to be mapped back to full-size pixels.
+	big := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	fillRect(big, big.Bounds(), blue)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, big, nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	taken := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	sources := []Source{
+		pngSource(t, "a.png", 30, 10, red),
+		Bytes("b.jpg", buf.Bytes(), taken),
+		pngSource(t, "c.png", 10, 40, red),
+	}
+	want := []Tile{
+		{Source: "a.png", Row: 0, Column: 0, Rect: image.Rect(0, 0, 20, 20), Crop: image.Rect(10, 0, 20, 10), Orientation: 1},
+		{Source: "b.jpg", Time: taken, Row: 0, Column: 1, Rect: image.Rect(20, 0, 40, 20), Crop: image.Rect(200, 0, 600, 400), Orientation: 1},
+		{Source: "c.png", Row: 1, Column: 0, Rect: image.Rect(0, 20, 20, 40), Crop: image.Rect(0, 15, 10, 25), Orientation: 1},
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts Options
+	}{
+		{"grid", Options{TileWidth: 20, Columns: 2}},
+		{"cached", Options{TileWidth: 20, Columns: 2, CacheDir: filepath.Join(t.TempDir(), "tiles")}},
+		{"streamed", Options{TileWidth: 20, Columns: 2, MaxMemory: 4 * 40 * 20}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := New(tc.opts)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			// Cached tiles must report the same records on the second run.
+			for run := 0; run < 2; run++ {
+				var out bytes.Buffer
+				res, err := b.Encode(context.Background(), &out, FormatPNG, sources)
+				if err != nil {
+					t.Fatalf("Encode: %v", err)
+				}
+				if res.Columns != 2 || res.Rows != 2 || res.TileWidth != 20 || res.TileHeight != 20 {
+					t.Fatalf("layout = %d×%d of %d×%d tiles, want 2×2 of 20×20", res.Columns, res.Rows, res.TileWidth, res.TileHeight)
+				}
+				if len(res.Tiles) != len(want) {
+					t.Fatalf("got %d tiles, want %d", len(res.Tiles), len(want))
+				}
+				for i, got := range res.Tiles {
+					if !got.Time.Equal(want[i].Time) {
+						t.Fatalf("run %d: tile %d time = %v, want %v", run, i, got.Time, want[i].Time)
+					}
+					got.Time = want[i].Time
+					if got != want[i] {
+						t.Fatalf("run %d: tile %d = %+v, want %+v", run, i, got, want[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFitOrientedWindow(t *testing.T) {
+	// Stored 120×80; orientations 6 and 8 stand it upright as 80×120.
+	src := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	cases := []struct {
+		orientation int
+		crop        CropMode
+		fit         FitMode
+		want        image.Rectangle
+	}{
+		{1, CropCenter, FitCrop, image.Rect(20, 0, 100, 80)},
+		{6, CropCenter, FitCrop, image.Rect(20, 0, 100, 80)},
+		// The upright top is the stored left edge for 6 and the right for 8.
+		{6, CropTop, FitCrop, image.Rect(0, 0, 80, 80)},
+		{8, CropTop, FitCrop, image.Rect(40, 0, 120, 80)},
+		{6, CropCenter, FitContain, image.Rect(0, 0, 120, 80)},
+	}
+	for _, tc := range cases {
+		spec := tileSpec{width: 10, height: 10, ratio: 1, crop: tc.crop, fit: tc.fit}
+		if _, got := fitOriented(src, tc.orientation, spec); got != tc.want {
+			t.Errorf("orientation %d, %s/%s: window = %v, want %v", tc.orientation, tc.fit, tc.crop, got, tc.want)
+		}
+	}
+}
+
+func TestTileInfoNote(t *testing.T) {
+	info := tileInfo{crop: image.Rect(3, 4, 50, 60), orientation: 6}
+	if got, ok := parseTileInfo(info.note()); !ok || got != info {
+		t.Fatalf("parseTileInfo(%q) = %+v, %v; want %+v", info.note(), got, ok, info)
+	}
+	if _, ok := parseTileInfo(""); ok {
+		t.Fatalf("parseTileInfo accepted an empty note")
+	}
+}